@@ -0,0 +1,91 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GPUQuotaSpec limits the GPU nodes the workspaces of a set of namespaces can use.
+type GPUQuotaSpec struct {
+	// The namespaces whose workspaces are limited, e.g., by a team label or by the kubernetes.io/metadata.name label
+	// for a single namespace. The workspaces of all namespaces are limited when empty.
+	//+optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// The maximum number of GPUs used by the workspaces of the namespaces. No limit when unset.
+	//+optional
+	//+kubebuilder:validation:Minimum:=0
+	MaxGPUs *int32 `json:"maxGPUs,omitempty"`
+	// The maximum number of GPU nodes used by the workspaces of the namespaces. No limit when unset.
+	//+optional
+	//+kubebuilder:validation:Minimum:=0
+	MaxNodes *int32 `json:"maxNodes,omitempty"`
+	// The instance types the workspaces of the namespaces can use. All instance types are allowed when empty.
+	//+optional
+	AllowedInstanceTypes []string `json:"allowedInstanceTypes,omitempty"`
+}
+
+// GPUQuotaUsage is the GPU capacity requested by workspaces.
+type GPUQuotaUsage struct {
+	// The number of GPUs.
+	GPUs int32 `json:"gpus"`
+	// The number of GPU nodes.
+	Nodes int32 `json:"nodes"`
+}
+
+// GPUQuotaStatus defines the observed usage of a GPUQuota.
+type GPUQuotaStatus struct {
+	// The GPU capacity requested by the workspaces of the namespaces that have nodes or provisioning machines.
+	// +optional
+	Used GPUQuotaUsage `json:"used,omitempty"`
+	// The number of workspaces of the namespaces that have nodes or provisioning machines.
+	// +optional
+	Workspaces int32 `json:"workspaces,omitempty"`
+	// Conditions of the GPUQuota, e.g., whether the usage exceeds the limits.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// GPUQuota is the Schema for the gpuquotas API
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=gpuquotas,scope=Cluster,categories=workspace,shortName={gq,gqs}
+// +kubebuilder:printcolumn:name="MaxGPUs",type="integer",JSONPath=".spec.maxGPUs",description=""
+// +kubebuilder:printcolumn:name="UsedGPUs",type="integer",JSONPath=".status.used.gpus",description=""
+// +kubebuilder:printcolumn:name="MaxNodes",type="integer",JSONPath=".spec.maxNodes",description=""
+// +kubebuilder:printcolumn:name="UsedNodes",type="integer",JSONPath=".status.used.nodes",description=""
+// +kubebuilder:printcolumn:name="Exceeded",type="string",JSONPath=".status.conditions[?(@.type==\"GPUQuotaExceeded\")].status",description=""
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description=""
+type GPUQuota struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GPUQuotaSpec   `json:"spec,omitempty"`
+	Status GPUQuotaStatus `json:"status,omitempty"`
+}
+
+// GPUQuotaList contains a list of GPUQuota
+// +kubebuilder:object:root=true
+type GPUQuotaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GPUQuota `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GPUQuota{}, &GPUQuotaList{})
+}
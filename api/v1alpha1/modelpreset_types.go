@@ -0,0 +1,138 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ModelPresetSpec defines how a preset model is deployed.
+type ModelPresetSpec struct {
+	// The container image that serves the model.
+	Image string `json:"image"`
+	// The command used to launch torchrun, e.g., "cd /workspace/llama/llama-2-7b-chat && torchrun".
+	BaseCommand string `json:"baseCommand"`
+	// The python entrypoint passed to torchrun, e.g., web_example_chat_completion.py.
+	InferenceFile string `json:"inferenceFile"`
+	// The torchrun arguments placed before the inference file, e.g., nproc_per_node.
+	//+optional
+	TorchRunParams map[string]string `json:"torchRunParams,omitempty"`
+	// The arguments passed to the inference file, e.g., max_seq_len.
+	//+optional
+	ModelRunParams map[string]string `json:"modelRunParams,omitempty"`
+	// The number of GPUs required by one replica.
+	//+kubebuilder:validation:Minimum:=1
+	GPUCountPerReplica int `json:"gpuCountPerReplica"`
+	// Whether one replica of the model can be sharded across several nodes. When the workspace has more than
+	// one node, gpuCountPerReplica is split evenly across the nodes and the model runs as a StatefulSet.
+	//+optional
+	DistributedInference bool `json:"distributedInference,omitempty"`
+	// The instance type used when a workspace using this preset does not specify one and no instance type
+	// of the catalog fits the preset.
+	//+optional
+	DefaultInstanceType string `json:"defaultInstanceType,omitempty"`
+	// The minimum memory of a single GPU required to run the model.
+	//+optional
+	MinGPUMemory *resource.Quantity `json:"minGPUMemory,omitempty"`
+	// Whether the model needs a memory backed volume mounted at /dev/shm.
+	//+optional
+	SharedMemory bool `json:"sharedMemory,omitempty"`
+	// The ephemeral storage requested by one replica.
+	//+optional
+	DiskStorageRequirement *resource.Quantity `json:"diskStorageRequirement,omitempty"`
+	// The volumes that are always mounted to the pod running the model.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	//+optional
+	Volumes []v1.Volume `json:"volumes,omitempty"`
+	// The mount points of Volumes in the inference container.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	//+optional
+	VolumeMounts []v1.VolumeMount `json:"volumeMounts,omitempty"`
+	// How the model is fine-tuned. Leave it unset if the model does not support training.
+	//+optional
+	Training *ModelPresetTrainingSpec `json:"training,omitempty"`
+	// The liveness probe of the inference container.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	//+optional
+	LivenessProbe *v1.Probe `json:"livenessProbe,omitempty"`
+	// The readiness probe of the inference container.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	//+optional
+	ReadinessProbe *v1.Probe `json:"readinessProbe,omitempty"`
+}
+
+// ModelPresetTrainingSpec defines how a preset model is fine-tuned.
+type ModelPresetTrainingSpec struct {
+	// The container image that fine-tunes the model. The inference image is used if empty.
+	//+optional
+	Image string `json:"image,omitempty"`
+	// The command used to launch torchrun, e.g., "cd /workspace/llama/llama-2-7b-chat && torchrun".
+	BaseCommand string `json:"baseCommand"`
+	// The python entrypoint passed to torchrun, e.g., finetuning.py.
+	TrainingFile string `json:"trainingFile"`
+	// The torchrun arguments placed before the training file. The rendezvous arguments are set by kdm.
+	//+optional
+	TorchRunParams map[string]string `json:"torchRunParams,omitempty"`
+	// The arguments passed to the training file. The dataset is mounted at /mnt/data and
+	// the output checkpoint must be written to /mnt/output.
+	//+optional
+	TrainingParams map[string]string `json:"trainingParams,omitempty"`
+	// The number of GPUs used on each training node.
+	//+kubebuilder:validation:Minimum:=1
+	GPUCountPerNode int `json:"gpuCountPerNode"`
+}
+
+// ModelPresetStatus defines the observed state of ModelPreset
+type ModelPresetStatus struct {
+	// Conditions of the ModelPreset, e.g., whether the spec is valid.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ModelPreset is the Schema for the modelpresets API
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=modelpresets,scope=Cluster,categories=workspace,shortName={mp,mps}
+// +kubebuilder:printcolumn:name="Image",type="string",JSONPath=".spec.image",description=""
+// +kubebuilder:printcolumn:name="GPUs",type="integer",JSONPath=".spec.gpuCountPerReplica",description=""
+// +kubebuilder:printcolumn:name="Valid",type="string",JSONPath=".status.conditions[?(@.type==\"ModelPresetValid\")].status",description=""
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description=""
+type ModelPreset struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ModelPresetSpec   `json:"spec,omitempty"`
+	Status ModelPresetStatus `json:"status,omitempty"`
+}
+
+// ModelPresetList contains a list of ModelPreset
+// +kubebuilder:object:root=true
+type ModelPresetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ModelPreset `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ModelPreset{}, &ModelPresetList{})
+}
@@ -16,12 +16,25 @@ const (
 	// WorkspaceConditionTypeResourceStatus is the state when Resource has been created.
 	WorkspaceConditionTypeResourceStatus = ConditionType("ResourceStatus")
 
+	// WorkspaceConditionTypeQueued is the state when the workspace waits for GPU capacity to create its machines.
+	WorkspaceConditionTypeQueued = ConditionType("Queued")
+
+	// WorkspaceConditionTypePreempted is the state when nodes of the workspace have been reassigned to a workspace
+	// with a higher priority.
+	WorkspaceConditionTypePreempted = ConditionType("Preempted")
+
+	// WorkspaceConditionTypeSuspended is the state when the workspace has been suspended and its GPU machines released.
+	WorkspaceConditionTypeSuspended = ConditionType("Suspended")
+
 	// WorkspaceConditionTypeResourceDeleted is the state when Resource has been deleted.
 	WorkspaceConditionTypeResourceDeleted = ConditionType("ResourceDeleted")
 
 	// WorkspaceConditionTypeInferenceStatus is the state when Inference has been created.
 	WorkspaceConditionTypeInferenceStatus = ConditionType("InferenceStatus")
 
+	// WorkspaceConditionTypeTrainingStatus is the state of the training job.
+	WorkspaceConditionTypeTrainingStatus = ConditionType("TrainingStatus")
+
 	// WorkspaceConditionTypeInferenceDeleted is the state when Inference has been deleted.
 	WorkspaceConditionTypeInferenceDeleted = ConditionType("InferenceDeleted")
 
@@ -31,3 +44,11 @@ const (
 	//WorkspaceConditionTypeReady is the Workspace state that summarize all operations' state.
 	WorkspaceConditionTypeReady ConditionType = ConditionType("WorkspaceReady")
 )
+
+const (
+	// ModelPresetConditionTypeValid is the state when the ModelPreset spec has been validated.
+	ModelPresetConditionTypeValid = ConditionType("ModelPresetValid")
+
+	// GPUQuotaConditionTypeExceeded is the state when the workspaces of a GPUQuota request more than its limits.
+	GPUQuotaConditionTypeExceeded = ConditionType("GPUQuotaExceeded")
+)
@@ -10,9 +10,23 @@ const (
 	// AnnotationServiceType determines whether kdm creates ClusterIP or LoadBalancer type service.
 	AnnotationServiceType = KDMPrefix + "service-type"
 
+	// AnnotationSpecHash records the hash of the pod template an inference workload was generated with.
+	AnnotationSpecHash = KDMPrefix + "spec-hash"
+
 	// LabelWorkspaceName is the label for workspace name.
 	LabelWorkspaceName = KDMPrefix + "workspace-name"
 
+	// LabelWorkspaceNamespace is the label for workspace namespace. Machines are cluster scoped, so it is needed
+	// together with LabelWorkspaceName to find the workspace a machine belongs to.
+	LabelWorkspaceNamespace = KDMPrefix + "workspace-namespace"
+
+	// LabelWorkspaceInference is the label for the name of the workspace an inference pod serves. It tells the
+	// inference pods apart from the training pods, which also have the workspace name label.
+	LabelWorkspaceInference = KDMPrefix + "workspace-inference"
+
+	// LabelWorkspaceActivator is the label for the name of the workspace an activator pod holds the requests of.
+	LabelWorkspaceActivator = KDMPrefix + "workspace-activator"
+
 	ServiceTypeClusterIP    = "cluster-ip"
 	ServiceTypeLoadBalancer = "load-balancer"
 )
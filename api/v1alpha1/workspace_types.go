@@ -29,17 +29,40 @@ const (
 )
 
 type ResourceSpec struct {
-	// The number of required GPU nodes.
+	// The number of required GPU nodes. Preset models that fit on one node and custom templates
+	// run one inference replica per node.
 	//+optional
 	//+kubebuilder:default:=1
 	Count *int `json:"count,omitempty"`
 
-	// The required instance type of the GPU node.
+	// The required instance type of the GPU node. When empty and a preset is used, the cheapest instance type
+	// of the catalog that fits the preset is selected by the controller in status.selectedInstanceType.
+	//+optional
 	InstanceType string `json:"instanceType,omitempty"`
 
-	// The required label for the GPU node.
+	// The instance types tried in order when the machines of the previous ones cannot be launched, e.g., when
+	// the cloud has no capacity left. Nodes of any of them are accepted. The instance type is retried after a while.
+	//+optional
+	FallbackInstanceTypes []string `json:"fallbackInstanceTypes,omitempty"`
+
+	// The name of the PriorityClass the priority of the workspace is taken from. The priority applies when GPU
+	// capacity is short: queued workspaces with a higher priority are admitted first, workspaces with the same
+	// priority in the order they were queued, and a workspace that cannot get nodes preempts the nodes of workspaces
+	// with a lower priority. Workspaces without priorityClassName have no priority.
+	//+optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// The required label for the GPU node. It is defaulted to the workspace name label by the defaulting webhook,
+	// except for workspaces created with generateName, which must set it.
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:XValidation:rule="has(self.matchLabels) && size(self.matchLabels) > 0",message="matchLabels must have at least one label"
+	LabelSelector *metav1.LabelSelector `json:"labelSelector"`
+
+	// What happens to the GPU machines provisioned for the workspace when it is deleted. Delete releases them,
+	// Retain keeps the nodes with the workspace labels so that they can be reused by another workspace.
 	//+optional
-	LabelSelector *metav1.LabelSelector `json:"labelSelector,omitempty"`
+	//+kubebuilder:default:=Delete
+	ReclaimPolicy ReclaimPolicy `json:"reclaimPolicy,omitempty"`
 
 	// The existing GPU nodes with the required labels and the required instanceType.
 	// This field is used when the number of qualified existing nodes is larger than the required count.
@@ -48,6 +71,17 @@ type ResourceSpec struct {
 	PreferredNodes []string `json:"preferredNodes,omitempty"`
 }
 
+// ReclaimPolicy describes what happens to the GPU machines of a workspace when it is deleted.
+// +kubebuilder:validation:Enum=Delete;Retain
+type ReclaimPolicy string
+
+const (
+	// ReclaimPolicyDelete deletes the machines provisioned for the workspace.
+	ReclaimPolicyDelete ReclaimPolicy = "Delete"
+	// ReclaimPolicyRetain keeps the machines provisioned for the workspace.
+	ReclaimPolicyRetain ReclaimPolicy = "Retain"
+)
+
 type PresetModelName string
 
 type PresetModelSpec struct {
@@ -67,11 +101,147 @@ type InferenceSpec struct {
 	// Leave this filed unset if preset model is used.
 	// +kubebuilder:pruning:PreserveUnknownFields
 	// +kubebuilder:validation:Schemaless
-	Template v1.PodTemplateSpec `json:"template,omitempty"`
+	Template *v1.PodTemplateSpec `json:"template,omitempty"`
 }
 
 type TrainingSpec struct {
-	// Job pytorchJob `json:"job,omitempty"`
+	// The preset base model to be fine-tuned. The preset must support training.
+	// The custom volumes in the preset are mounted in the training pods at /mnt/volumes/<volume name>.
+	Preset PresetModelSpec `json:"preset,omitempty"`
+	// The volume that contains the training dataset. It is mounted at /mnt/data in the training pods.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	DataVolume *v1.Volume `json:"dataVolume,omitempty"`
+	// The volume where the output checkpoint is written. It is mounted at /mnt/output in the training pods.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	OutputVolume *v1.Volume `json:"outputVolume,omitempty"`
+	// The arguments passed to the training file. They override the ones in the preset.
+	//+optional
+	TrainingParams map[string]string `json:"trainingParams,omitempty"`
+}
+
+// WorkspaceSpec holds the fields controlling whether and how much the workspace runs.
+type WorkspaceSpec struct {
+	// The number of inference replicas, each running on its own GPU node. It is set by kubectl scale or a
+	// HorizontalPodAutoscaler and takes precedence over resource.count. It cannot be used with distributed presets.
+	//+optional
+	//+kubebuilder:validation:Minimum:=0
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Suspend stops the workspace without deleting it: the inference workload is scaled to zero, a running training
+	// job is deleted and the GPU machines are deleted whatever the reclaim policy, while the workspace and its service
+	// are kept. The workspace is provisioned again when it is resumed, and a deleted training job is run again.
+	//+optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// Schedule starts and stops the workspace at fixed times by setting suspend. Suspend can still be changed by hand,
+	// it is set again at the next scheduled start or stop.
+	//+optional
+	Schedule *WorkspaceSchedule `json:"schedule,omitempty"`
+
+	// TTLSecondsAfterReady deletes the workspace the given number of seconds after it first became ready.
+	//+optional
+	//+kubebuilder:validation:Minimum:=0
+	TTLSecondsAfterReady *int32 `json:"ttlSecondsAfterReady,omitempty"`
+
+	// IdleTimeout stops the workspace once its inference has served no request for the given duration, e.g., 2h.
+	// The requests are counted with requestMetrics.
+	//+optional
+	IdleTimeout *metav1.Duration `json:"idleTimeout,omitempty"`
+
+	// IdleAction is what happens to an idle workspace: Suspend suspends it, Delete deletes it.
+	//+optional
+	//+kubebuilder:default:=Suspend
+	IdleAction IdleAction `json:"idleAction,omitempty"`
+
+	// RequestMetrics is where the counter of the requests served by the workspace is scraped from to detect that it
+	// is idle. The metrics endpoints of the inference pods are scraped by default.
+	//+optional
+	RequestMetrics *RequestMetricsSpec `json:"requestMetrics,omitempty"`
+
+	// ScaleToZero routes the requests to the workspace to an activator while it has no ready inference replica,
+	// e.g., once it has been suspended for being idle. The activator holds the requests, resumes the workspace and
+	// forwards the requests once the inference is ready.
+	//+optional
+	ScaleToZero *ScaleToZeroSpec `json:"scaleToZero,omitempty"`
+}
+
+// ScaleToZeroSpec describes how the requests to a workspace at zero replicas are held.
+type ScaleToZeroSpec struct {
+	// How long the requests are held while the workspace is resumed. Provisioning a GPU node and loading the model
+	// can take several minutes.
+	//+optional
+	//+kubebuilder:default:="15m"
+	ActivationTimeout *metav1.Duration `json:"activationTimeout,omitempty"`
+}
+
+// IdleAction describes what happens to a workspace that has served no request for its idle timeout.
+// +kubebuilder:validation:Enum=Suspend;Delete
+type IdleAction string
+
+const (
+	// IdleActionSuspend suspends the idle workspace.
+	IdleActionSuspend IdleAction = "Suspend"
+	// IdleActionDelete deletes the idle workspace.
+	IdleActionDelete IdleAction = "Delete"
+)
+
+// RequestMetricsSpec describes a counter of served requests in the Prometheus text format.
+type RequestMetricsSpec struct {
+	// The name of the counter. The samples matching matchLabels are summed.
+	//+optional
+	//+kubebuilder:default:=http_requests_total
+	Name string `json:"name,omitempty"`
+	// The labels of the samples that are counted, e.g., the service of the workspace in the metrics of a gateway.
+	//+optional
+	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+	// The port of the metrics endpoint of the inference pods, or of the service.
+	//+optional
+	//+kubebuilder:default:=5000
+	Port int32 `json:"port,omitempty"`
+	// The path of the metrics endpoint of the inference pods, or of the service.
+	//+optional
+	//+kubebuilder:default:=/metrics
+	Path string `json:"path,omitempty"`
+	// The name of a service in the namespace of the workspace, e.g., of a gateway in front of it, whose metrics are
+	// scraped instead of the pods.
+	//+optional
+	//+kubebuilder:validation:MaxLength=63
+	//+kubebuilder:validation:Pattern=`^[a-z]([-a-z0-9]*[a-z0-9])?$`
+	Service string `json:"service,omitempty"`
+}
+
+// WorkspaceSchedule describes when a workspace runs with cron expressions, e.g., start "0 8 * * 1-5" and
+// stop "0 20 * * 1-5" for weekdays from 8:00 to 20:00.
+type WorkspaceSchedule struct {
+	// The cron expression of the times the workspace is started, in the standard five field format
+	// minute, hour, day of month, month and day of week.
+	Start string `json:"start"`
+	// The cron expression of the times the workspace is stopped.
+	Stop string `json:"stop"`
+	// The IANA time zone of the cron expressions, e.g., Europe/Berlin. UTC when empty.
+	//+optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// ScheduledActionType is what the schedule of a workspace does at a scheduled time.
+// +kubebuilder:validation:Enum=Start;Stop
+type ScheduledActionType string
+
+const (
+	// ScheduledActionStart resumes the workspace.
+	ScheduledActionStart ScheduledActionType = "Start"
+	// ScheduledActionStop suspends the workspace.
+	ScheduledActionStop ScheduledActionType = "Stop"
+)
+
+// ScheduledAction is a start or stop of the workspace by its schedule.
+type ScheduledAction struct {
+	// Whether the workspace is started or stopped.
+	Action ScheduledActionType `json:"action"`
+	// When the workspace is started or stopped.
+	Time metav1.Time `json:"time"`
 }
 
 // WorkspaceStatus defines the observed state of Workspace
@@ -80,28 +250,110 @@ type WorkspaceStatus struct {
 	// +optional
 	WorkerNodes []string `json:"workerNodes,omitempty"`
 
+	// The names of the machines that have been created for the workspace and are not ready yet.
+	// +optional
+	ProvisioningMachines []string `json:"provisioningMachines,omitempty"`
+
+	// The instance type selected by the controller when resource.instanceType is empty: the cheapest instance type
+	// of the catalog that fits the preset of the workspace. It takes the place of resource.instanceType and is kept
+	// while the workspace exists.
+	// +optional
+	SelectedInstanceType string `json:"selectedInstanceType,omitempty"`
+
+	// The instance type new machines are provisioned with: the first one of resource.instanceType, or
+	// selectedInstanceType, and resource.fallbackInstanceTypes that has not failed to launch recently.
+	// +optional
+	InstanceType string `json:"instanceType,omitempty"`
+
+	// The priority the workspace is queued and preempted with: the value of the PriorityClass of
+	// resource.priorityClassName.
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+
+	// The instance types whose machines failed to launch, with the time of their last failure.
+	// +optional
+	InstanceTypeFailures []InstanceTypeFailure `json:"instanceTypeFailures,omitempty"`
+
+	// The position of the workspace in the queue of workspaces waiting for GPU capacity, starting at 1.
+	// Zero means the workspace is not queued.
+	// +optional
+	QueuePosition int32 `json:"queuePosition,omitempty"`
+
+	// The last start or stop of the workspace by its schedule.
+	// +optional
+	LastScheduledAction *ScheduledAction `json:"lastScheduledAction,omitempty"`
+
+	// The next start or stop of the workspace by its schedule.
+	// +optional
+	NextScheduledAction *ScheduledAction `json:"nextScheduledAction,omitempty"`
+
+	// When the workspace first became ready.
+	// +optional
+	FirstReadyTime *metav1.Time `json:"firstReadyTime,omitempty"`
+
+	// The last observed value of the request counter of the workspace.
+	// +optional
+	RequestCount int64 `json:"requestCount,omitempty"`
+
+	// When the workspace was last seen serving requests, or when it started to be watched for requests.
+	// +optional
+	LastRequestTime *metav1.Time `json:"lastRequestTime,omitempty"`
+
+	// The number of inference replicas the workspace is scaled to.
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// The number of inference replicas that are ready to serve requests.
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// The label selector of the inference pods in string form, used by the scale subresource.
+	// +optional
+	Selector string `json:"selector,omitempty"`
+
 	// Phase defines current condition of the Workspace.
 	// +optional
 	Conditions []metav1.Condition `json:"condition,omitempty"`
 }
 
+// InstanceTypeFailure records the last time machines of an instance type failed to launch.
+type InstanceTypeFailure struct {
+	// The instance type that failed.
+	InstanceType string `json:"instanceType"`
+	// Why the machine failed to launch.
+	// +optional
+	Message string `json:"message,omitempty"`
+	// When the last machine of the instance type failed to launch.
+	LastFailureTime metav1.Time `json:"lastFailureTime"`
+}
+
 // Workspace is the Schema for the workspaces API
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas,selectorpath=.status.selector
 // +kubebuilder:resource:path=workspaces,scope=Namespaced,categories=workspace,shortName={wk,wks}
 // +kubebuilder:storageversion
-// +kubebuilder:printcolumn:name="Instance",type="string",JSONPath=".resource.instanceType",description=""
+// +kubebuilder:printcolumn:name="Instance",type="string",JSONPath=".status.instanceType",description=""
 // +kubebuilder:printcolumn:name="ResourceReady",type="string",JSONPath=".status.condition[?(@.type==\"ResourceStatus\")].status",description=""
+// +kubebuilder:printcolumn:name="Replicas",type="string",JSONPath=".status.readyReplicas",description="",priority=1
+// +kubebuilder:printcolumn:name="QueuePosition",type="integer",JSONPath=".status.queuePosition",description="",priority=1
+// +kubebuilder:printcolumn:name="Suspended",type="boolean",JSONPath=".spec.suspend",description="",priority=1
+// +kubebuilder:printcolumn:name="NextAction",type="string",JSONPath=".status.nextScheduledAction.action",description="",priority=1
+// +kubebuilder:printcolumn:name="NextActionTime",type="date",JSONPath=".status.nextScheduledAction.time",description="",priority=1
+// +kubebuilder:printcolumn:name="Priority",type="integer",JSONPath=".status.priority",description="",priority=1
 // +kubebuilder:printcolumn:name="InferenceReady",type="string",JSONPath=".status.condition[?(@.type==\"InferenceStatus\")].status",description=""
+// +kubebuilder:printcolumn:name="TrainingStatus",type="string",JSONPath=".status.condition[?(@.type==\"TrainingStatus\")].reason",description="",priority=1
 // +kubebuilder:printcolumn:name="WorkspaceStatus",type="string",JSONPath=".status.condition[?(@.type==\"WorkspaceReady\")].status",description=""
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description=""
 type Workspace struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Resource  ResourceSpec    `json:"resource,omitempty"`
+	//+kubebuilder:validation:Required
+	Resource  ResourceSpec    `json:"resource"`
 	Inference InferenceSpec   `json:"inference,omitempty"`
 	Training  TrainingSpec    `json:"training,omitempty"`
+	Spec      WorkspaceSpec   `json:"spec,omitempty"`
 	Status    WorkspaceStatus `json:"status,omitempty"`
 }
 
@@ -27,11 +27,147 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GPUQuota) DeepCopyInto(out *GPUQuota) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPUQuota.
+func (in *GPUQuota) DeepCopy() *GPUQuota {
+	if in == nil {
+		return nil
+	}
+	out := new(GPUQuota)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GPUQuota) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GPUQuotaList) DeepCopyInto(out *GPUQuotaList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]GPUQuota, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPUQuotaList.
+func (in *GPUQuotaList) DeepCopy() *GPUQuotaList {
+	if in == nil {
+		return nil
+	}
+	out := new(GPUQuotaList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GPUQuotaList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GPUQuotaSpec) DeepCopyInto(out *GPUQuotaSpec) {
+	*out = *in
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxGPUs != nil {
+		in, out := &in.MaxGPUs, &out.MaxGPUs
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxNodes != nil {
+		in, out := &in.MaxNodes, &out.MaxNodes
+		*out = new(int32)
+		**out = **in
+	}
+	if in.AllowedInstanceTypes != nil {
+		in, out := &in.AllowedInstanceTypes, &out.AllowedInstanceTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPUQuotaSpec.
+func (in *GPUQuotaSpec) DeepCopy() *GPUQuotaSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GPUQuotaSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GPUQuotaStatus) DeepCopyInto(out *GPUQuotaStatus) {
+	*out = *in
+	out.Used = in.Used
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPUQuotaStatus.
+func (in *GPUQuotaStatus) DeepCopy() *GPUQuotaStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GPUQuotaStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GPUQuotaUsage) DeepCopyInto(out *GPUQuotaUsage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPUQuotaUsage.
+func (in *GPUQuotaUsage) DeepCopy() *GPUQuotaUsage {
+	if in == nil {
+		return nil
+	}
+	out := new(GPUQuotaUsage)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *InferenceSpec) DeepCopyInto(out *InferenceSpec) {
 	*out = *in
 	in.Preset.DeepCopyInto(&out.Preset)
-	in.Template.DeepCopyInto(&out.Template)
+	if in.Template != nil {
+		in, out := &in.Template, &out.Template
+		*out = new(corev1.PodTemplateSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InferenceSpec.
@@ -44,6 +180,200 @@ func (in *InferenceSpec) DeepCopy() *InferenceSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InstanceTypeFailure) DeepCopyInto(out *InstanceTypeFailure) {
+	*out = *in
+	in.LastFailureTime.DeepCopyInto(&out.LastFailureTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstanceTypeFailure.
+func (in *InstanceTypeFailure) DeepCopy() *InstanceTypeFailure {
+	if in == nil {
+		return nil
+	}
+	out := new(InstanceTypeFailure)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ModelPreset) DeepCopyInto(out *ModelPreset) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModelPreset.
+func (in *ModelPreset) DeepCopy() *ModelPreset {
+	if in == nil {
+		return nil
+	}
+	out := new(ModelPreset)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ModelPreset) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ModelPresetList) DeepCopyInto(out *ModelPresetList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ModelPreset, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModelPresetList.
+func (in *ModelPresetList) DeepCopy() *ModelPresetList {
+	if in == nil {
+		return nil
+	}
+	out := new(ModelPresetList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ModelPresetList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ModelPresetSpec) DeepCopyInto(out *ModelPresetSpec) {
+	*out = *in
+	if in.TorchRunParams != nil {
+		in, out := &in.TorchRunParams, &out.TorchRunParams
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ModelRunParams != nil {
+		in, out := &in.ModelRunParams, &out.ModelRunParams
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.MinGPUMemory != nil {
+		in, out := &in.MinGPUMemory, &out.MinGPUMemory
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.DiskStorageRequirement != nil {
+		in, out := &in.DiskStorageRequirement, &out.DiskStorageRequirement
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]corev1.Volume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.VolumeMounts != nil {
+		in, out := &in.VolumeMounts, &out.VolumeMounts
+		*out = make([]corev1.VolumeMount, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Training != nil {
+		in, out := &in.Training, &out.Training
+		*out = new(ModelPresetTrainingSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LivenessProbe != nil {
+		in, out := &in.LivenessProbe, &out.LivenessProbe
+		*out = new(corev1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ReadinessProbe != nil {
+		in, out := &in.ReadinessProbe, &out.ReadinessProbe
+		*out = new(corev1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModelPresetSpec.
+func (in *ModelPresetSpec) DeepCopy() *ModelPresetSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ModelPresetSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ModelPresetStatus) DeepCopyInto(out *ModelPresetStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModelPresetStatus.
+func (in *ModelPresetStatus) DeepCopy() *ModelPresetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ModelPresetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ModelPresetTrainingSpec) DeepCopyInto(out *ModelPresetTrainingSpec) {
+	*out = *in
+	if in.TorchRunParams != nil {
+		in, out := &in.TorchRunParams, &out.TorchRunParams
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.TrainingParams != nil {
+		in, out := &in.TrainingParams, &out.TrainingParams
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModelPresetTrainingSpec.
+func (in *ModelPresetTrainingSpec) DeepCopy() *ModelPresetTrainingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ModelPresetTrainingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PresetModelSpec) DeepCopyInto(out *PresetModelSpec) {
 	*out = *in
@@ -66,6 +396,28 @@ func (in *PresetModelSpec) DeepCopy() *PresetModelSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RequestMetricsSpec) DeepCopyInto(out *RequestMetricsSpec) {
+	*out = *in
+	if in.MatchLabels != nil {
+		in, out := &in.MatchLabels, &out.MatchLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RequestMetricsSpec.
+func (in *RequestMetricsSpec) DeepCopy() *RequestMetricsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RequestMetricsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceSpec) DeepCopyInto(out *ResourceSpec) {
 	*out = *in
@@ -74,6 +426,11 @@ func (in *ResourceSpec) DeepCopyInto(out *ResourceSpec) {
 		*out = new(int)
 		**out = **in
 	}
+	if in.FallbackInstanceTypes != nil {
+		in, out := &in.FallbackInstanceTypes, &out.FallbackInstanceTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.LabelSelector != nil {
 		in, out := &in.LabelSelector, &out.LabelSelector
 		*out = new(v1.LabelSelector)
@@ -96,9 +453,63 @@ func (in *ResourceSpec) DeepCopy() *ResourceSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScaleToZeroSpec) DeepCopyInto(out *ScaleToZeroSpec) {
+	*out = *in
+	if in.ActivationTimeout != nil {
+		in, out := &in.ActivationTimeout, &out.ActivationTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScaleToZeroSpec.
+func (in *ScaleToZeroSpec) DeepCopy() *ScaleToZeroSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ScaleToZeroSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduledAction) DeepCopyInto(out *ScheduledAction) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduledAction.
+func (in *ScheduledAction) DeepCopy() *ScheduledAction {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduledAction)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TrainingSpec) DeepCopyInto(out *TrainingSpec) {
 	*out = *in
+	in.Preset.DeepCopyInto(&out.Preset)
+	if in.DataVolume != nil {
+		in, out := &in.DataVolume, &out.DataVolume
+		*out = new(corev1.Volume)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OutputVolume != nil {
+		in, out := &in.OutputVolume, &out.OutputVolume
+		*out = new(corev1.Volume)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TrainingParams != nil {
+		in, out := &in.TrainingParams, &out.TrainingParams
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrainingSpec.
@@ -118,7 +529,8 @@ func (in *Workspace) DeepCopyInto(out *Workspace) {
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Resource.DeepCopyInto(&out.Resource)
 	in.Inference.DeepCopyInto(&out.Inference)
-	out.Training = in.Training
+	in.Training.DeepCopyInto(&out.Training)
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -172,6 +584,66 @@ func (in *WorkspaceList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkspaceSchedule) DeepCopyInto(out *WorkspaceSchedule) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkspaceSchedule.
+func (in *WorkspaceSchedule) DeepCopy() *WorkspaceSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkspaceSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkspaceSpec) DeepCopyInto(out *WorkspaceSpec) {
+	*out = *in
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Schedule != nil {
+		in, out := &in.Schedule, &out.Schedule
+		*out = new(WorkspaceSchedule)
+		**out = **in
+	}
+	if in.TTLSecondsAfterReady != nil {
+		in, out := &in.TTLSecondsAfterReady, &out.TTLSecondsAfterReady
+		*out = new(int32)
+		**out = **in
+	}
+	if in.IdleTimeout != nil {
+		in, out := &in.IdleTimeout, &out.IdleTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.RequestMetrics != nil {
+		in, out := &in.RequestMetrics, &out.RequestMetrics
+		*out = new(RequestMetricsSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ScaleToZero != nil {
+		in, out := &in.ScaleToZero, &out.ScaleToZero
+		*out = new(ScaleToZeroSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkspaceSpec.
+func (in *WorkspaceSpec) DeepCopy() *WorkspaceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkspaceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WorkspaceStatus) DeepCopyInto(out *WorkspaceStatus) {
 	*out = *in
@@ -180,6 +652,36 @@ func (in *WorkspaceStatus) DeepCopyInto(out *WorkspaceStatus) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.ProvisioningMachines != nil {
+		in, out := &in.ProvisioningMachines, &out.ProvisioningMachines
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.InstanceTypeFailures != nil {
+		in, out := &in.InstanceTypeFailures, &out.InstanceTypeFailures
+		*out = make([]InstanceTypeFailure, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastScheduledAction != nil {
+		in, out := &in.LastScheduledAction, &out.LastScheduledAction
+		*out = new(ScheduledAction)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NextScheduledAction != nil {
+		in, out := &in.NextScheduledAction, &out.NextScheduledAction
+		*out = new(ScheduledAction)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FirstReadyTime != nil {
+		in, out := &in.FirstReadyTime, &out.FirstReadyTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastRequestTime != nil {
+		in, out := &in.LastRequestTime, &out.LastRequestTime
+		*out = (*in).DeepCopy()
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]v1.Condition, len(*in))
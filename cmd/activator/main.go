@@ -0,0 +1,85 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kdm/pkg/activator"
+	"k8s.io/klog/v2"
+)
+
+var exitWithErrorFunc = func() {
+	klog.Flush()
+	os.Exit(1)
+}
+
+func main() {
+	a := &activator.Activator{
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+	var port int
+	flag.StringVar(&a.Workspace, "workspace", "", "The name of the workspace the requests are held for.")
+	flag.StringVar(&a.Namespace, "namespace", "", "The namespace of the workspace.")
+	flag.IntVar(&port, "port", 5000, "The port the requests are received on, the target port of the workspace service.")
+	flag.StringVar(&a.ActivationURL, "activation-url", "",
+		"The URL of the activation server of the controller, which resumes the workspace.")
+	flag.DurationVar(&a.ActivationTimeout, "activation-timeout", 15*time.Minute,
+		"How long the requests are held while the workspace is resumed.")
+	flag.StringVar(&a.TokenFile, "token-file", "",
+		"The service account token file the activator authenticates to the activation server with.")
+	flag.DurationVar(&a.PollInterval, "poll-interval", 2*time.Second,
+		"How often the controller is asked whether the workspace is ready.")
+	klog.InitFlags(nil)
+	flag.Parse()
+
+	if a.Workspace == "" || a.Namespace == "" || a.ActivationURL == "" || a.TokenFile == "" {
+		klog.ErrorS(errors.New("missing flags"), "--workspace, --namespace, --activation-url and --token-file are required")
+		exitWithErrorFunc()
+	}
+
+	// Requests are held for up to the activation timeout, the write timeout must not cut them.
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           a,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			klog.ErrorS(err, "failed to shut the activator down")
+		}
+	}()
+
+	klog.InfoS("starting activator", "workspace", klog.KRef(a.Namespace, a.Workspace), "port", port)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		klog.ErrorS(err, "problem running activator")
+		exitWithErrorFunc()
+	}
+}
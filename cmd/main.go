@@ -17,12 +17,20 @@ limitations under the License.
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/kdm/pkg/activator"
 	"github.com/kdm/pkg/controllers"
+	"github.com/kdm/pkg/machine"
+	"github.com/kdm/pkg/sku"
+	"github.com/kdm/pkg/webhooks"
 	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
@@ -63,11 +71,42 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var nodeProvisioner string
+	var provisionerOpts machine.ProvisionerOptions
+	var instanceTypeCatalog string
+	var maxProvisioningMachines int
+	var activatorImage, activationURL, activationAddr string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&nodeProvisioner, "node-provisioner", machine.ProvisionerKindMachine,
+		"The backend provisioning the GPU nodes of workspaces, one of machine, nodeclaim, clusterapi, byo or fake. "+
+			"With byo no nodes are provisioned, workspaces run on the existing nodes that match their label selector. "+
+			"With fake, fake GPU nodes are created for local development and tests.")
+	flag.StringVar(&provisionerOpts.KarpenterProvisionerName, "karpenter-provisioner-name", machine.DefaultProvisionerName,
+		"The Karpenter provisioner the machines are created for.")
+	flag.StringVar(&provisionerOpts.KarpenterNodePoolName, "karpenter-nodepool-name", machine.DefaultNodePoolName,
+		"The Karpenter node pool the node claims are created for.")
+	flag.StringVar(&provisionerOpts.KarpenterNodeClassName, "karpenter-nodeclass-name", machine.DefaultNodeClassName,
+		"The Karpenter node class referenced by the node claims.")
+	flag.StringVar(&provisionerOpts.ClusterAPINamespace, "clusterapi-namespace", "default",
+		"The namespace of the Cluster API MachineDeployment templates.")
+	flag.DurationVar(&provisionerOpts.FakeNodeDelay, "fake-node-delay", machine.DefaultFakeNodeDelay,
+		"How long the fake provisioner takes to create a node.")
+	flag.StringVar(&instanceTypeCatalog, "instance-type-catalog", "",
+		"The namespace/name of the ConfigMap overriding and extending the built-in instance type catalog. "+
+			"The instance types are read from its "+sku.CatalogConfigMapKey+" key.")
+	flag.IntVar(&maxProvisioningMachines, "max-provisioning-machines", 0,
+		"The number of machines that can be provisioned at the same time in the cluster. Workspaces needing more "+
+			"are queued and admitted by priority, then in the order they were queued. Zero means no limit.")
+	flag.StringVar(&activatorImage, "activator-image", "",
+		"The image of the activators holding the requests to the workspaces with scaleToZero while they are resumed. "+
+			"scaleToZero is not available when it is empty.")
+	flag.StringVar(&activationURL, "activation-url", "",
+		"The URL the activators reach the activation server of the controller at.")
+	flag.StringVar(&activationAddr, "activation-bind-address", ":8082", "The address the activation server binds to.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -102,13 +141,60 @@ func main() {
 		exitWithErrorFunc()
 	}
 
+	instanceTypes, err := newCatalogSource(instanceTypeCatalog, mgr.GetAPIReader())
+	if err != nil {
+		klog.ErrorS(err, "invalid instance type catalog")
+		exitWithErrorFunc()
+	}
+	provisionerOpts.InstanceTypes = instanceTypes
+	provisioner, err := machine.NewNodeProvisioner(nodeProvisioner, provisionerOpts, mgr.GetClient())
+	if err != nil {
+		klog.ErrorS(err, "unable to create node provisioner")
+		exitWithErrorFunc()
+	}
+	if activatorImage != "" {
+		if activationURL == "" {
+			klog.ErrorS(errors.New("missing --activation-url"), "the activators need the URL of the activation server")
+			exitWithErrorFunc()
+		}
+		if err := mgr.Add(&activator.ActivationServer{Client: mgr.GetClient(), Addr: activationAddr}); err != nil {
+			klog.ErrorS(err, "unable to add activation server")
+			exitWithErrorFunc()
+		}
+	}
 	if err = (&controllers.WorkspaceReconciler{
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		NodeProvisioner:         provisioner,
+		InstanceTypes:           instanceTypes,
+		MaxProvisioningMachines: maxProvisioningMachines,
+		ActivatorImage:          activatorImage,
+		ActivationURL:           activationURL,
+	}).SetupWithManager(mgr); err != nil {
+		klog.ErrorS(err, "unable to create controller", "controller", "Workspace")
+		exitWithErrorFunc()
+	}
+	if err = (&controllers.GPUQuotaReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		InstanceTypes: instanceTypes,
+	}).SetupWithManager(mgr); err != nil {
+		klog.ErrorS(err, "unable to create controller", "controller", "GPUQuota")
+		exitWithErrorFunc()
+	}
+	if err = (&controllers.ModelPresetReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		klog.ErrorS(err, "unable to create controller", "controller", "Workspace")
+		klog.ErrorS(err, "unable to create controller", "controller", "ModelPreset")
 		exitWithErrorFunc()
 	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = webhooks.SetupWorkspaceWebhooksWithManager(mgr, instanceTypes); err != nil {
+			klog.ErrorS(err, "unable to create webhook", "webhook", "Workspace")
+			exitWithErrorFunc()
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -127,12 +213,33 @@ func main() {
 	}
 
 	workspaceController := &controllers.WorkspaceReconciler{
-		Client:   mgr.GetClient(),
-		Log:      log.Log.WithName("controllers").WithName("Workspace"),
-		Scheme:   mgr.GetScheme(),
-		Recorder: mgr.GetEventRecorderFor("KDM-Workspace-controller"),
+		Client:                  mgr.GetClient(),
+		Log:                     log.Log.WithName("controllers").WithName("Workspace"),
+		Scheme:                  mgr.GetScheme(),
+		Recorder:                mgr.GetEventRecorderFor("KDM-Workspace-controller"),
+		NodeProvisioner:         provisioner,
+		InstanceTypes:           instanceTypes,
+		MaxProvisioningMachines: maxProvisioningMachines,
+		ActivatorImage:          activatorImage,
+		ActivationURL:           activationURL,
 	}
 	if err := workspaceController.SetupWithManager(mgr); err != nil {
 		// TODO Handle error
 	}
 }
+
+// newCatalogSource returns the source of the instance type catalog stored in the namespace/name ConfigMap.
+// The ConfigMap is read without a cache, it is only read when instance types are selected or validated.
+func newCatalogSource(configMap string, reader client.Reader) (*sku.CatalogSource, error) {
+	if configMap == "" {
+		return &sku.CatalogSource{}, nil
+	}
+	namespace, name, found := strings.Cut(configMap, "/")
+	if !found || namespace == "" || name == "" {
+		return nil, fmt.Errorf("instance type catalog %q is not of the form namespace/name", configMap)
+	}
+	return &sku.CatalogSource{
+		Reader:    reader,
+		ConfigMap: client.ObjectKey{Namespace: namespace, Name: name},
+	}, nil
+}
@@ -0,0 +1,175 @@
+package activator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/inference"
+	"github.com/kdm/pkg/k8sresources"
+	"github.com/samber/lo"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// ActivatePath is the path prefix of the activation requests, followed by the namespace and the name of the workspace.
+const ActivatePath = "/activate/"
+
+// ActivationResponse is the response to an activation request.
+type ActivationResponse struct {
+	// Ready is true once the inference of the workspace serves requests.
+	Ready bool `json:"ready"`
+	// Endpoints are the host:port addresses of the ready inference pods.
+	Endpoints []string `json:"endpoints,omitempty"`
+	// Message tells what the workspace is waiting for.
+	Message string `json:"message,omitempty"`
+}
+
+// ActivationServer serves the activation requests of the activators in the controller manager. An activation
+// request resumes the suspended workspace and returns its ready inference endpoints. Only workspaces with
+// scaleToZero can be activated, and only by their own activator: the requests carry a token of the service account
+// of the activator, which is checked with a TokenReview.
+type ActivationServer struct {
+	Client client.Client
+	// Addr is the address the server binds to.
+	Addr string
+}
+
+var _ manager.LeaderElectionRunnable = &ActivationServer{}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. All replicas of the controller serve activations.
+func (s *ActivationServer) NeedLeaderElection() bool {
+	return false
+}
+
+// Start implements manager.Runnable.
+func (s *ActivationServer) Start(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc(ActivatePath, s.serveActivation)
+	server := &http.Server{
+		Addr:              s.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			klog.ErrorS(err, "failed to shut the activation server down")
+		}
+	}()
+
+	klog.InfoS("starting activation server", "addr", s.Addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *ActivationServer) serveActivation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	namespace, name, found := strings.Cut(strings.TrimPrefix(r.URL.Path, ActivatePath), "/")
+	if !found || namespace == "" || name == "" || strings.Contains(name, "/") {
+		http.Error(w, fmt.Sprintf("path must be %s<namespace>/<name>", ActivatePath), http.StatusBadRequest)
+		return
+	}
+
+	key := client.ObjectKey{Namespace: namespace, Name: name}
+	if status, err := s.authenticate(r, key); err != nil {
+		klog.ErrorS(err, "rejecting activation request", "workspace", klog.KRef(namespace, name))
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	response, err := s.activate(r.Context(), key)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if apierrors.IsNotFound(err) {
+			status = http.StatusNotFound
+		} else if apierrors.IsForbidden(err) || apierrors.IsInvalid(err) {
+			// The workspace does not fit in the GPU quotas of its namespace.
+			status = http.StatusForbidden
+		}
+		klog.ErrorS(err, "failed to activate workspace", "workspace", klog.KRef(namespace, name))
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		klog.ErrorS(err, "failed to write the activation response", "workspace", klog.KRef(namespace, name))
+	}
+}
+
+// authenticate checks that the request carries a token of the service account of the activator of the workspace,
+// issued for the activation audience. It returns the HTTP status of the rejection otherwise.
+func (s *ActivationServer) authenticate(r *http.Request, key client.ObjectKey) (int, error) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || token == "" {
+		return http.StatusUnauthorized, errors.New("missing bearer token")
+	}
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token:     token,
+			Audiences: []string{k8sresources.ActivationTokenAudience},
+		},
+	}
+	if err := s.Client.Create(r.Context(), review); err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("failed to review token: %w", err)
+	}
+	if !review.Status.Authenticated {
+		return http.StatusUnauthorized, fmt.Errorf("invalid token: %s", review.Status.Error)
+	}
+	if !lo.Contains(review.Status.Audiences, k8sresources.ActivationTokenAudience) {
+		return http.StatusUnauthorized, errors.New("token is not issued for the activation audience")
+	}
+	wObj := &kdmv1alpha1.Workspace{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}}
+	username := fmt.Sprintf("system:serviceaccount:%s:%s", key.Namespace, k8sresources.ActivatorName(wObj))
+	if review.Status.User.Username != username {
+		return http.StatusForbidden, fmt.Errorf("%s is not the activator of the workspace", review.Status.User.Username)
+	}
+	return http.StatusOK, nil
+}
+
+// activate resumes the workspace if it is suspended, and returns its ready inference endpoints.
+func (s *ActivationServer) activate(ctx context.Context, key client.ObjectKey) (*ActivationResponse, error) {
+	wObj := &kdmv1alpha1.Workspace{}
+	if err := s.Client.Get(ctx, key, wObj); err != nil {
+		return nil, err
+	}
+	if wObj.Spec.ScaleToZero == nil || !wObj.DeletionTimestamp.IsZero() {
+		return nil, apierrors.NewNotFound(kdmv1alpha1.GroupVersion.WithResource("workspaces").GroupResource(), key.Name)
+	}
+
+	if wObj.Spec.Suspend {
+		klog.InfoS("activating workspace", "workspace", klog.KObj(wObj))
+		patch := client.MergeFrom(wObj.DeepCopy())
+		wObj.Spec.Suspend = false
+		if err := s.Client.Patch(ctx, wObj, patch); err != nil {
+			return nil, err
+		}
+		return &ActivationResponse{Message: "workspace is being resumed"}, nil
+	}
+
+	endpoints, err := inference.GetReadyEndpoints(ctx, wObj, s.Client)
+	if err != nil {
+		return nil, err
+	}
+	if len(endpoints) == 0 {
+		return &ActivationResponse{Message: "waiting for the inference to be ready"}, nil
+	}
+	return &ActivationResponse{Ready: true, Endpoints: endpoints}, nil
+}
@@ -0,0 +1,101 @@
+package activator
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kdm/pkg/k8sresources"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// newTestActivationServer returns an activation server whose TokenReviews return the statuses of the tokens.
+// Unknown tokens fail the review.
+func newTestActivationServer(t *testing.T, statuses map[string]authenticationv1.TokenReviewStatus) *ActivationServer {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add the client-go types to the scheme: %v", err)
+	}
+	kubeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				review, ok := obj.(*authenticationv1.TokenReview)
+				if !ok {
+					return c.Create(ctx, obj, opts...)
+				}
+				status, ok := statuses[review.Spec.Token]
+				if !ok {
+					return errors.New("token review failed")
+				}
+				review.Status = status
+				return nil
+			},
+		}).
+		Build()
+	return &ActivationServer{Client: kubeClient}
+}
+
+func TestAuthenticate(t *testing.T) {
+	key := client.ObjectKey{Namespace: "default", Name: "workspace"}
+	authenticated := func(username string, audiences ...string) authenticationv1.TokenReviewStatus {
+		return authenticationv1.TokenReviewStatus{
+			Authenticated: true,
+			Audiences:     audiences,
+			User:          authenticationv1.UserInfo{Username: username},
+		}
+	}
+	s := newTestActivationServer(t, map[string]authenticationv1.TokenReviewStatus{
+		"activator": authenticated("system:serviceaccount:default:workspace-activator",
+			k8sresources.ActivationTokenAudience),
+		"invalid": {Authenticated: false, Error: "token expired"},
+		"other-audience": authenticated("system:serviceaccount:default:workspace-activator",
+			"https://kubernetes.default.svc"),
+		"other-activator": authenticated("system:serviceaccount:default:other-activator",
+			k8sresources.ActivationTokenAudience),
+		"other-namespace": authenticated("system:serviceaccount:other:workspace-activator",
+			k8sresources.ActivationTokenAudience),
+		"user": authenticated("admin", k8sresources.ActivationTokenAudience),
+	})
+
+	testCases := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{name: "activator of the workspace", authorization: "Bearer activator", want: http.StatusOK},
+		{name: "missing header", authorization: "", want: http.StatusUnauthorized},
+		{name: "not a bearer token", authorization: "Basic activator", want: http.StatusUnauthorized},
+		{name: "empty bearer token", authorization: "Bearer ", want: http.StatusUnauthorized},
+		{name: "unauthenticated token", authorization: "Bearer invalid", want: http.StatusUnauthorized},
+		{name: "token of another audience", authorization: "Bearer other-audience", want: http.StatusUnauthorized},
+		{name: "activator of another workspace", authorization: "Bearer other-activator", want: http.StatusForbidden},
+		{name: "activator of another namespace", authorization: "Bearer other-namespace", want: http.StatusForbidden},
+		{name: "not a service account", authorization: "Bearer user", want: http.StatusForbidden},
+		{name: "failed token review", authorization: "Bearer unknown", want: http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, ActivatePath+key.Namespace+"/"+key.Name, nil)
+			if tc.authorization != "" {
+				r.Header.Set("Authorization", tc.authorization)
+			}
+			got, err := s.authenticate(r, key)
+			if got != tc.want {
+				t.Errorf("authenticate() = %d, %v, want %d", got, err, tc.want)
+			}
+			if (err == nil) != (tc.want == http.StatusOK) {
+				t.Errorf("authenticate() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package activator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// endpointsTTL is how long the ready endpoints of the workspace are reused before they are requested again. Until
+// the workspace service selects the inference pods again, the requests keep coming to the activator.
+const endpointsTTL = 10 * time.Second
+
+// Activator holds the requests to a workspace at zero replicas. The first request asks the controller to resume
+// the workspace, then all the requests are held until its inference is ready and forwarded to the inference pods.
+type Activator struct {
+	Workspace, Namespace string
+	// ActivationURL is the URL of the activation server of the controller.
+	ActivationURL string
+	// ActivationTimeout is how long the requests are held.
+	ActivationTimeout time.Duration
+	// PollInterval is how often the controller is asked whether the workspace is ready.
+	PollInterval time.Duration
+	HTTPClient   *http.Client
+	// TokenFile is the projected service account token the activator authenticates to the activation server with.
+	// It is read for each request, since the kubelet renews it.
+	TokenFile string
+
+	mu sync.Mutex
+	// endpoints are the ready endpoints of the workspace, requested at endpointsTime.
+	endpoints     []string
+	endpointsTime time.Time
+	// activation is the activation in progress that the requests wait for.
+	activation *activation
+	next       atomic.Uint64
+}
+
+// activation is the result of an activation, available once done is closed.
+type activation struct {
+	done      chan struct{}
+	endpoints []string
+	err       error
+}
+
+// errNotActivatable is returned when the controller rejects the activation, retrying it would not help.
+type errNotActivatable struct {
+	status  string
+	message string
+}
+
+func (e *errNotActivatable) Error() string {
+	return fmt.Sprintf("workspace cannot be activated: %s: %s", e.status, e.message)
+}
+
+// ServeHTTP holds the request until the workspace is ready and forwards it to an inference pod.
+func (a *Activator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	endpoints, err := a.getEndpoints(r.Context())
+	if err != nil {
+		klog.ErrorS(err, "failed to activate workspace", "workspace", klog.KRef(a.Namespace, a.Workspace))
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	endpoint := endpoints[a.next.Add(1)%uint64(len(endpoints))]
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: endpoint})
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		klog.ErrorS(err, "failed to forward request", "workspace", klog.KRef(a.Namespace, a.Workspace), "endpoint", endpoint)
+		// The pod may be gone, the next request asks the controller for the endpoints again.
+		a.mu.Lock()
+		a.endpoints = nil
+		a.mu.Unlock()
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// getEndpoints returns the ready endpoints of the workspace, activating it if needed. Concurrent requests share
+// the same activation.
+func (a *Activator) getEndpoints(ctx context.Context) ([]string, error) {
+	a.mu.Lock()
+	if len(a.endpoints) != 0 && time.Since(a.endpointsTime) < endpointsTTL {
+		endpoints := a.endpoints
+		a.mu.Unlock()
+		return endpoints, nil
+	}
+	act := a.activation
+	if act == nil {
+		act = &activation{done: make(chan struct{})}
+		a.activation = act
+		go a.activate(act)
+	}
+	a.mu.Unlock()
+
+	select {
+	case <-act.done:
+		return act.endpoints, act.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// activate waits for the workspace to be ready. It is not bound to any request, so that the activation goes on when
+// the client that started it gives up.
+func (a *Activator) activate(act *activation) {
+	klog.InfoS("activating workspace", "workspace", klog.KRef(a.Namespace, a.Workspace))
+	ctx, cancel := context.WithTimeout(context.Background(), a.ActivationTimeout)
+	defer cancel()
+	act.endpoints, act.err = a.waitForEndpoints(ctx)
+
+	a.mu.Lock()
+	a.activation = nil
+	if act.err == nil {
+		a.endpoints, a.endpointsTime = act.endpoints, time.Now()
+	}
+	a.mu.Unlock()
+	close(act.done)
+}
+
+// waitForEndpoints asks the controller to activate the workspace until it returns ready endpoints.
+func (a *Activator) waitForEndpoints(ctx context.Context) ([]string, error) {
+	for {
+		response, err := a.requestActivation(ctx)
+		switch {
+		case err != nil:
+			if _, ok := err.(*errNotActivatable); ok {
+				return nil, err
+			}
+			klog.ErrorS(err, "activation request failed", "workspace", klog.KRef(a.Namespace, a.Workspace))
+		case response.Ready && len(response.Endpoints) != 0:
+			klog.InfoS("workspace is ready", "workspace", klog.KRef(a.Namespace, a.Workspace), "endpoints", response.Endpoints)
+			return response.Endpoints, nil
+		default:
+			klog.InfoS("waiting for workspace", "workspace", klog.KRef(a.Namespace, a.Workspace), "message", response.Message)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("workspace %s/%s was not ready within %s", a.Namespace, a.Workspace, a.ActivationTimeout)
+		case <-time.After(a.PollInterval):
+		}
+	}
+}
+
+func (a *Activator) requestActivation(ctx context.Context) (*ActivationResponse, error) {
+	activationURL, err := url.JoinPath(a.ActivationURL, ActivatePath, a.Namespace, a.Workspace)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, activationURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	token, err := os.ReadFile(a.TokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the activation token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	resp, err := a.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		response := &ActivationResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+			return nil, fmt.Errorf("invalid activation response: %w", err)
+		}
+		return response, nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusForbidden:
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &errNotActivatable{status: resp.Status, message: string(message)}
+	default:
+		return nil, fmt.Errorf("activation server returned %s", resp.Status)
+	}
+}
@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/quota"
+	"github.com/kdm/pkg/sku"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// GPUQuotaReconciler reports the GPUs and nodes requested by the workspaces of the namespaces of GPUQuota objects.
+// The quotas are enforced by the workspace webhook and by the workspace controller before machines are created.
+type GPUQuotaReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	// InstanceTypes is the catalog the GPUs of the workspace instance types are read from.
+	InstanceTypes *sku.CatalogSource
+}
+
+func (c *GPUQuotaReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	quotaObj := &kdmv1alpha1.GPUQuota{}
+	if err := c.Client.Get(ctx, req.NamespacedName, quotaObj); err != nil {
+		if !apierrors.IsNotFound(err) {
+			klog.ErrorS(err, "failed to get GPU quota", "gpuQuota", req.Name)
+		}
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+
+	klog.InfoS("Reconciling", "gpuQuota", req.Name)
+
+	catalog, err := c.InstanceTypes.Load(ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	used, workspaceCount, err := quota.Usage(ctx, c.Client, catalog, quotaObj, "")
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	quotaObj.Status.Used = used
+	quotaObj.Status.Workspaces = int32(workspaceCount)
+
+	cObj := metav1.Condition{
+		Type:               string(kdmv1alpha1.GPUQuotaConditionTypeExceeded),
+		Status:             metav1.ConditionFalse,
+		Reason:             "gpuQuotaWithinLimits",
+		ObservedGeneration: quotaObj.GetGeneration(),
+		Message:            "the workspaces are within the limits of the GPU quota",
+	}
+	if maxGPUs := quotaObj.Spec.MaxGPUs; maxGPUs != nil && used.GPUs > *maxGPUs {
+		cObj.Status = metav1.ConditionTrue
+		cObj.Reason = "gpuQuotaExceeded"
+		cObj.Message = fmt.Sprintf("the workspaces request %d GPUs but the quota allows %d", used.GPUs, *maxGPUs)
+	} else if maxNodes := quotaObj.Spec.MaxNodes; maxNodes != nil && used.Nodes > *maxNodes {
+		cObj.Status = metav1.ConditionTrue
+		cObj.Reason = "gpuQuotaExceeded"
+		cObj.Message = fmt.Sprintf("the workspaces request %d nodes but the quota allows %d", used.Nodes, *maxNodes)
+	}
+	meta.SetStatusCondition(&quotaObj.Status.Conditions, cObj)
+
+	return reconcile.Result{}, retry.OnError(retry.DefaultRetry,
+		func(err error) bool {
+			return apierrors.IsServiceUnavailable(err) || apierrors.IsServerTimeout(err) || apierrors.IsTooManyRequests(err)
+		},
+		func() error {
+			return c.Client.Status().Update(ctx, quotaObj)
+		})
+}
+
+// watchAllQuotas enqueues all the GPU quotas, since any of them can select the namespace of the object.
+func (c *GPUQuotaReconciler) watchAllQuotas() handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(
+		func(ctx context.Context, o client.Object) []reconcile.Request {
+			quotaList := &kdmv1alpha1.GPUQuotaList{}
+			if err := c.Client.List(ctx, quotaList); err != nil {
+				klog.ErrorS(err, "failed to list GPU quotas", "object", klog.KObj(o))
+				return nil
+			}
+			return lo.Map(quotaList.Items, func(quotaObj kdmv1alpha1.GPUQuota, _ int) reconcile.Request {
+				return reconcile.Request{
+					NamespacedName: client.ObjectKeyFromObject(&quotaObj),
+				}
+			})
+		})
+}
+
+// workspaceNodesChangedPredicate passes the updates of the nodes and provisioning machines of the workspaces, which
+// decide whether they use their quotas.
+func workspaceNodesChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldWObj, okOld := e.ObjectOld.(*kdmv1alpha1.Workspace)
+			newWObj, okNew := e.ObjectNew.(*kdmv1alpha1.Workspace)
+			if !okOld || !okNew {
+				return false
+			}
+			return !reflect.DeepEqual(oldWObj.Status.WorkerNodes, newWObj.Status.WorkerNodes) ||
+				!reflect.DeepEqual(oldWObj.Status.ProvisioningMachines, newWObj.Status.ProvisioningMachines)
+		},
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (c *GPUQuotaReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&kdmv1alpha1.GPUQuota{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&kdmv1alpha1.Workspace{}, c.watchAllQuotas(), builder.WithPredicates(
+			predicate.Or(predicate.GenerationChangedPredicate{}, workspaceNodesChangedPredicate()))).
+		Watches(&corev1.Namespace{}, c.watchAllQuotas(), builder.WithPredicates(predicate.LabelChangedPredicate{})).
+		Complete(c)
+}
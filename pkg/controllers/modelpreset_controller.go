@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"context"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/inference"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ModelPresetReconciler validates ModelPreset objects and reports the result in their status.
+type ModelPresetReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+func (c *ModelPresetReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	presetObj := &kdmv1alpha1.ModelPreset{}
+	if err := c.Client.Get(ctx, req.NamespacedName, presetObj); err != nil {
+		if !apierrors.IsNotFound(err) {
+			klog.ErrorS(err, "failed to get model preset", "modelPreset", req.Name)
+		}
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+
+	klog.InfoS("Reconciling", "modelPreset", req.Name)
+
+	cObj := metav1.Condition{
+		Type:               string(kdmv1alpha1.ModelPresetConditionTypeValid),
+		Status:             metav1.ConditionTrue,
+		Reason:             "modelPresetValid",
+		ObservedGeneration: presetObj.GetGeneration(),
+		Message:            "model preset is valid",
+	}
+	if err := inference.ValidateModelPreset(presetObj); err != nil {
+		cObj.Status = metav1.ConditionFalse
+		cObj.Reason = "modelPresetInvalid"
+		cObj.Message = err.Error()
+	}
+	meta.SetStatusCondition(&presetObj.Status.Conditions, cObj)
+
+	return reconcile.Result{}, retry.OnError(retry.DefaultRetry,
+		func(err error) bool {
+			return apierrors.IsServiceUnavailable(err) || apierrors.IsServerTimeout(err) || apierrors.IsTooManyRequests(err)
+		},
+		func() error {
+			return c.Client.Status().Update(ctx, presetObj)
+		})
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (c *ModelPresetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&kdmv1alpha1.ModelPreset{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(c)
+}
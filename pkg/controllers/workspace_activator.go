@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/k8sresources"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// applyActivator deploys the activator of a workspace with scaleToZero, and removes it once scaleToZero is unset.
+// The activator keeps running while the workspace is ready, so that it can hold the requests as soon as the
+// workspace is at zero replicas again.
+func (c *WorkspaceReconciler) applyActivator(ctx context.Context, wObj *kdmv1alpha1.Workspace) error {
+	existingObj := &appsv1.Deployment{}
+	err := c.Client.Get(ctx, client.ObjectKey{Name: k8sresources.ActivatorName(wObj), Namespace: wObj.Namespace}, existingObj)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	found := err == nil
+
+	if wObj.Spec.ScaleToZero == nil || !hasInference(wObj) {
+		if !found {
+			return nil
+		}
+		klog.InfoS("deleting the activator of workspace", "workspace", klog.KObj(wObj))
+		return client.IgnoreNotFound(c.Client.Delete(ctx, existingObj, &client.DeleteOptions{}))
+	}
+	if c.ActivatorImage == "" {
+		return reconcile.TerminalError(errors.New("scaleToZero is not available, the controller has no activator image"))
+	}
+
+	if err := c.ensureActivatorServiceAccount(ctx, wObj); err != nil {
+		return err
+	}
+	desiredObj := k8sresources.GenerateActivatorDeploymentManifest(ctx, wObj, c.ActivatorImage, c.ActivationURL)
+	if !found {
+		return k8sresources.CreateDeployment(ctx, desiredObj, c.Client)
+	}
+	if k8sresources.GetSpecHash(existingObj) == k8sresources.GetSpecHash(desiredObj) {
+		return nil
+	}
+	return k8sresources.UpdateDeployment(ctx, desiredObj, c.Client)
+}
+
+// ensureActivatorServiceAccount creates the service account the activator authenticates to the activation server
+// with. It is deleted with the workspace.
+func (c *WorkspaceReconciler) ensureActivatorServiceAccount(ctx context.Context, wObj *kdmv1alpha1.Workspace) error {
+	existingObj := &corev1.ServiceAccount{}
+	err := c.Client.Get(ctx, client.ObjectKey{Name: k8sresources.ActivatorName(wObj), Namespace: wObj.Namespace}, existingObj)
+	if !apierrors.IsNotFound(err) {
+		return err
+	}
+	err = k8sresources.CreateServiceAccount(ctx, k8sresources.GenerateActivatorServiceAccountManifest(ctx, wObj), c.Client)
+	return client.IgnoreAlreadyExists(err)
+}
+
+// routeToActivator returns true if the requests to the workspace are held by its activator: the workspace has
+// scaleToZero and no ready inference replica.
+func routeToActivator(wObj *kdmv1alpha1.Workspace) bool {
+	return wObj.Spec.ScaleToZero != nil && wObj.Status.ReadyReplicas == 0
+}
@@ -2,18 +2,25 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
 	"time"
 
-	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
 	"github.com/go-logr/logr"
 	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
 	"github.com/kdm/pkg/inference"
 	"github.com/kdm/pkg/k8sresources"
 	"github.com/kdm/pkg/machine"
+	"github.com/kdm/pkg/quota"
+	"github.com/kdm/pkg/sku"
+	"github.com/kdm/pkg/training"
+	"github.com/kdm/pkg/utils"
 	"github.com/samber/lo"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
@@ -23,27 +30,56 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
-var torchRunParams = map[string]string{
-	"max_seq_len":    "512",
-	"max_batch_size": "8",
-}
+// presetNameIndexKey indexes workspaces by the names of the preset models they run inference or training with.
+const presetNameIndexKey = "preset.name"
+
+// workerNodesIndexKey indexes workspaces by the nodes in their Status.WorkerNodes.
+const workerNodesIndexKey = "status.workerNodes"
+
+// machineProvisioningRequeueInterval is how often a workspace with provisioning machines is checked again
+// in case a machine event is missed.
+var machineProvisioningRequeueInterval = 30 * time.Second
+
+// instanceTypeRetryInterval is how long an instance type whose machine failed to launch is skipped in favor of the
+// fallback instance types before it is tried again.
+var instanceTypeRetryInterval = 30 * time.Minute
+
+// inferenceStatusRequeueInterval is how often a workspace whose inference is not ready is checked again,
+// since pods failing to start do not always change the status of their workload.
+var inferenceStatusRequeueInterval = 30 * time.Second
+
+// workloadDeletionRequeueInterval is how often a workspace waiting for its workloads to be deleted is checked again
+// in case a deletion event is missed.
+var workloadDeletionRequeueInterval = 10 * time.Second
 
 type WorkspaceReconciler struct {
 	client.Client
-	Log      logr.Logger
-	Scheme   *runtime.Scheme
-	Recorder record.EventRecorder
+	Log             logr.Logger
+	Scheme          *runtime.Scheme
+	Recorder        record.EventRecorder
+	NodeProvisioner machine.NodeProvisioner
+	// InstanceTypes is the catalog the instance type of preset workspaces is selected from.
+	InstanceTypes *sku.CatalogSource
+	// MaxProvisioningMachines is the number of machines that can be provisioned at the same time in the cluster.
+	// Workspaces needing more are queued. Zero means no limit.
+	MaxProvisioningMachines int
+	// ActivatorImage is the image of the activators of the workspaces with scaleToZero, which cannot be deployed
+	// when it is empty.
+	ActivatorImage string
+	// ActivationURL is the URL of the activation server the activators resume the workspaces with.
+	ActivationURL string
 }
 
 func (c *WorkspaceReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
 	workspaceObj := &kdmv1alpha1.Workspace{}
 	if err := c.Client.Get(ctx, req.NamespacedName, workspaceObj); err != nil {
-		if !errors.IsNotFound(err) {
+		if !apierrors.IsNotFound(err) {
 			klog.ErrorS(err, "failed to get workspace", "workspace", req.Name)
 		}
 		return reconcile.Result{}, client.IgnoreNotFound(err)
@@ -56,27 +92,94 @@ func (c *WorkspaceReconciler) Reconcile(ctx context.Context, req reconcile.Reque
 		return c.deleteWorkspace(ctx, workspaceObj)
 	}
 
-	return c.addOrUpdateWorkspace(ctx, workspaceObj)
+	result, err := c.addOrUpdateWorkspace(ctx, workspaceObj)
+	return requeueForTimers(workspaceObj, result), err
 }
 
 func (c *WorkspaceReconciler) addOrUpdateWorkspace(ctx context.Context, wObj *kdmv1alpha1.Workspace) (reconcile.Result, error) {
-	// Read ResourceSpec
-	err := c.applyWorkspaceResource(ctx, wObj)
+	if err := c.ensureFinalizer(ctx, wObj); err != nil {
+		klog.ErrorS(err, "failed to add the finalizer to the workspace", "workspace", klog.KObj(wObj))
+		return reconcile.Result{}, err
+	}
+
+	if err := checkLabelSelector(wObj); err != nil {
+		if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeReady, metav1.ConditionFalse,
+			"workspaceFailed", err.Error()); err != nil {
+			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{}, err
+	}
+
+	if err := c.checkReplicas(ctx, wObj); err != nil {
+		if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeReady, metav1.ConditionFalse,
+			"workspaceFailed", err.Error()); err != nil {
+			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{}, err
+	}
+
+	if err := c.applySchedule(ctx, wObj); err != nil {
+		if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeReady, metav1.ConditionFalse,
+			"workspaceFailed", err.Error()); err != nil {
+			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{}, err
+	}
+
+	deleted, err := c.applyLifetime(ctx, wObj)
 	if err != nil {
 		if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeReady, metav1.ConditionFalse,
 			"workspaceFailed", err.Error()); err != nil {
 			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
 			return reconcile.Result{}, err
 		}
-		// if error is	due to machine instance types unavailability, stop reconcile.
-		if err.Error() == machine.ErrorInstanceTypesUnavailable {
-			return reconcile.Result{Requeue: false}, err
+		return reconcile.Result{}, err
+	}
+	if deleted {
+		return reconcile.Result{}, nil
+	}
+
+	if err := c.ensureInstanceType(ctx, wObj); err != nil {
+		if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeReady, metav1.ConditionFalse,
+			"workspaceFailed", err.Error()); err != nil {
+			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+			return reconcile.Result{}, err
 		}
 		return reconcile.Result{}, err
 	}
 
-	if wObj.GetAnnotations() != nil {
-		if err := c.applyAnnotations(ctx, wObj); err != nil {
+	if err := c.ensurePriority(ctx, wObj); err != nil {
+		if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeReady, metav1.ConditionFalse,
+			"workspaceFailed", err.Error()); err != nil {
+			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{}, err
+	}
+
+	if wObj.Spec.Suspend {
+		suspended, err := c.suspendWorkspace(ctx, wObj)
+		if err != nil {
+			if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeReady, metav1.ConditionFalse,
+				"workspaceFailed", err.Error()); err != nil {
+				klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+				return reconcile.Result{}, err
+			}
+			return reconcile.Result{}, err
+		}
+		if !suspended {
+			// The deletion events of the workloads bring the workspace back.
+			if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeReady, metav1.ConditionFalse,
+				"workspaceSuspending", "waiting for the workloads to be stopped"); err != nil {
+				klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+				return reconcile.Result{}, err
+			}
+			return reconcile.Result{RequeueAfter: workloadDeletionRequeueInterval}, nil
+		}
+		if err := c.applyActivator(ctx, wObj); err != nil {
 			if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeReady, metav1.ConditionFalse,
 				"workspaceFailed", err.Error()); err != nil {
 				klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
@@ -84,9 +187,30 @@ func (c *WorkspaceReconciler) addOrUpdateWorkspace(ctx context.Context, wObj *kd
 			}
 			return reconcile.Result{}, err
 		}
+		if wObj.Spec.ScaleToZero != nil && hasInference(wObj) {
+			// The requests to the suspended workspace are held by the activator, which resumes it.
+			if err := c.applyAnnotations(ctx, wObj); err != nil {
+				if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeReady, metav1.ConditionFalse,
+					"workspaceFailed", err.Error()); err != nil {
+					klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+					return reconcile.Result{}, err
+				}
+				return reconcile.Result{}, err
+			}
+		}
+		if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeReady, metav1.ConditionFalse,
+			"workspaceSuspended", "workspace is suspended"); err != nil {
+			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{}, nil
+	}
+	if err := c.resumeWorkspace(ctx, wObj); err != nil {
+		klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+		return reconcile.Result{}, err
 	}
 
-	if err = c.applyInference(ctx, wObj); err != nil {
+	if err := c.applyActivator(ctx, wObj); err != nil {
 		if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeReady, metav1.ConditionFalse,
 			"workspaceFailed", err.Error()); err != nil {
 			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
@@ -95,19 +219,103 @@ func (c *WorkspaceReconciler) addOrUpdateWorkspace(ctx context.Context, wObj *kd
 		return reconcile.Result{}, err
 	}
 
-	// TODO apply TrainingSpec
+	// Read ResourceSpec
+	provisioned, err := c.applyWorkspaceResource(ctx, wObj)
+	if err != nil {
+		if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeReady, metav1.ConditionFalse,
+			"workspaceFailed", err.Error()); err != nil {
+			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{}, err
+	}
+	if !provisioned {
+		// Machine events or the requeue bring the workspace back once the machines are launched and their nodes are
+		// labelled for the plugins. Queued workspaces are checked again by the requeue until capacity frees up or
+		// their instance types can be retried.
+		reason, message := "workspaceProvisioning", "waiting for machines to be provisioned"
+		if isQueued(wObj) {
+			reason, message = "workspaceQueued", fmt.Sprintf("waiting for GPU capacity at position %d in the queue", wObj.Status.QueuePosition)
+		}
+		if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeReady, metav1.ConditionUnknown,
+			reason, message); err != nil {
+			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{RequeueAfter: machineProvisioningRequeueInterval}, nil
+	}
+
+	if hasInference(wObj) {
+		inferenceStatus, err := c.applyInference(ctx, wObj)
+		if err != nil {
+			if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeReady, metav1.ConditionFalse,
+				"workspaceFailed", err.Error()); err != nil {
+				klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+				return reconcile.Result{}, err
+			}
+			return reconcile.Result{}, err
+		}
+		// The service selects the activator of a workspace with scaleToZero until the inference is ready.
+		if err := c.applyAnnotations(ctx, wObj); err != nil {
+			if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeReady, metav1.ConditionFalse,
+				"workspaceFailed", err.Error()); err != nil {
+				klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+				return reconcile.Result{}, err
+			}
+			return reconcile.Result{}, err
+		}
+		if !inferenceStatus.IsReady() {
+			// Workload status events or the requeue bring the workspace back once the pods make progress.
+			if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeReady, inferenceStatus.Status,
+				"workspaceInferenceNotReady", inferenceStatus.Message); err != nil {
+				klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+				return reconcile.Result{}, err
+			}
+			return reconcile.Result{RequeueAfter: inferenceStatusRequeueInterval}, nil
+		}
+	}
+
+	// Scaling down releases the machines only after the inference workload has been scaled down.
+	released, err := c.releaseExtraMachines(ctx, wObj)
+	if err != nil {
+		if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeResourceStatus, metav1.ConditionFalse,
+			"workspaceResourceStatusFailed", err.Error()); err != nil {
+			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{}, err
+	}
+
+	if wObj.Training.Preset.Name != "" {
+		if err = c.applyTraining(ctx, wObj); err != nil {
+			if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeReady, metav1.ConditionFalse,
+				"workspaceFailed", err.Error()); err != nil {
+				klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+				return reconcile.Result{}, err
+			}
+			return reconcile.Result{}, err
+		}
+	}
+
+	if wObj.Status.FirstReadyTime == nil {
+		// The TTL of the workspace starts when it is ready for the first time.
+		wObj.Status.FirstReadyTime = &metav1.Time{Time: time.Now()}
+	}
 	if err = c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeReady, metav1.ConditionTrue,
 		"workspaceReady", "workspace is ready"); err != nil {
 		klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
 		return reconcile.Result{}, err
 	}
 
+	if !released {
+		// The worker nodes are selected again once the pods of the released nodes are gone.
+		return reconcile.Result{RequeueAfter: machineProvisioningRequeueInterval}, nil
+	}
 	return reconcile.Result{}, nil
 }
 
 func (c *WorkspaceReconciler) deleteWorkspace(ctx context.Context, wObj *kdmv1alpha1.Workspace) (reconcile.Result, error) {
 	klog.InfoS("deleteWorkspace", "workspace", klog.KObj(wObj))
-	// TODO delete workspace, machine(s), training and inference (deployment, service) obj ( ok to delete machines? which will delete nodes??)
 	err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeDeleting, metav1.ConditionTrue, "workspaceDeleted", "workspace is being deleted")
 	if err != nil {
 		klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
@@ -117,20 +325,22 @@ func (c *WorkspaceReconciler) deleteWorkspace(ctx context.Context, wObj *kdmv1al
 	return c.garbageCollectWorkspace(ctx, wObj)
 }
 
-// applyWorkspaceResource applies workspace resource spec.
-func (c *WorkspaceReconciler) applyWorkspaceResource(ctx context.Context, wObj *kdmv1alpha1.Workspace) error {
+// applyWorkspaceResource applies workspace resource spec. Machines are created without waiting for them to be launched;
+// it returns false while machines are being provisioned, and the workspace is reconciled again once they are.
+func (c *WorkspaceReconciler) applyWorkspaceResource(ctx context.Context, wObj *kdmv1alpha1.Workspace) (bool, error) {
 	klog.InfoS("applyWorkspaceResource", "workspace", klog.KObj(wObj))
 	validNodeList := []*corev1.Node{}
+	nodeCount := utils.GetNodeCount(wObj)
 
-	machinesProvisioningCount, err := machine.CheckOngoingProvisioningMachines(ctx, wObj, c.Client)
+	provisioningMachines, err := c.checkProvisioningMachines(ctx, wObj)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	// Check the current cluster nodes if they match the labelSelector and instanceType
 	validCurrentClusterNodeList, err := c.validateCurrentClusterNodes(ctx, wObj)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	// Check preferredNodes
@@ -149,10 +359,18 @@ func (c *WorkspaceReconciler) applyWorkspaceResource(ctx context.Context, wObj *
 			})
 		}
 	}
-	// TODO check nodes in the WorkspaceStatus.WorkerNodes.
+	// Nodes running the pods of the workspace are kept first, so that scaling down releases the nodes whose pods
+	// are gone rather than the first nodes of the list.
+	podNodes, err := c.listWorkspacePodNodes(ctx, wObj)
+	if err != nil {
+		return false, err
+	}
+	runsPods := func(nodeItem *corev1.Node, _ int) bool { return lo.Contains(podNodes, nodeItem.Name) }
+	validCurrentClusterNodeList = append(lo.Filter(validCurrentClusterNodeList, runsPods),
+		lo.Reject(validCurrentClusterNodeList, runsPods)...)
 
 	for n := range validCurrentClusterNodeList {
-		if len(validNodeList) == lo.FromPtr(wObj.Resource.Count) {
+		if len(validNodeList) == nodeCount {
 			break
 		}
 		_, found := lo.Find(validNodeList, func(nodeItem *corev1.Node) bool {
@@ -165,44 +383,101 @@ func (c *WorkspaceReconciler) applyWorkspaceResource(ctx context.Context, wObj *
 
 	validNodeCount := len(validNodeList)
 	// subtract all valid nodes from the desired count
-	remainingNodeCount := lo.FromPtr(wObj.Resource.Count) - validNodeCount - machinesProvisioningCount
+	remainingNodeCount := nodeCount - validNodeCount - len(provisioningMachines)
 
 	// if current valid nodes Count == workspace count, then all good and return
-	if remainingNodeCount == 0 {
-		klog.InfoS("number of existing nodes are equal to the required workspace count", "workspace.Count", lo.FromPtr(wObj.Resource.Count))
+	if remainingNodeCount <= 0 {
+		klog.InfoS("number of existing nodes are equal to the required workspace count", "workspace.Count", nodeCount)
+		if err := c.dequeueWorkspace(ctx, wObj); err != nil {
+			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+			return false, err
+		}
+		if err := c.clearPreemption(ctx, wObj); err != nil {
+			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+			return false, err
+		}
 	} else {
 		klog.InfoS("need to create more nodes", "NodeCount", remainingNodeCount)
+		available, err := c.selectInstanceType(ctx, wObj)
+		if err != nil {
+			return false, err
+		}
+		if !available {
+			return false, c.preemptWorkspaces(ctx, wObj, remainingNodeCount)
+		}
+		withinQuota, err := c.checkQuota(ctx, wObj)
+		if err != nil {
+			return false, err
+		}
+		if !withinQuota {
+			return false, nil
+		}
+		admitted, err := c.admitWorkspace(ctx, wObj, remainingNodeCount)
+		if err != nil {
+			return false, err
+		}
+		if !admitted {
+			// The workspace waits for the machines being provisioned, preempting nodes would not make room for it.
+			return false, nil
+		}
 		for i := 0; i < remainingNodeCount; i++ {
-			newNode, err := c.createAndValidateNode(ctx, wObj)
+			machineName, err := c.createMachine(ctx, wObj)
+			if machine.IsNodeProvisioningDisabled(err) {
+				// The workspace is checked again once enough matching nodes have been added to the cluster.
+				if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeResourceStatus, metav1.ConditionUnknown,
+					"workspaceWaitingForNodes", fmt.Sprintf("%d more nodes matching the workspace are needed: %v",
+						remainingNodeCount, err)); err != nil {
+					klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+					return false, err
+				}
+				return false, c.preemptWorkspaces(ctx, wObj, remainingNodeCount-i)
+			}
 			if err != nil {
 				if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeResourceStatus, metav1.ConditionFalse,
 					"workspaceResourceStatusFailed", err.Error()); err != nil {
 					klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
-					return err
+					return false, err
 				}
-				return err
+				return false, err
 			}
-			validNodeList = append(validNodeList, newNode)
+			provisioningMachines = append(provisioningMachines, machineName)
+		}
+	}
+
+	if err := c.updateWorkspaceStatusWithProvisioningMachines(ctx, wObj, provisioningMachines); err != nil {
+		klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+		return false, err
+	}
+	if len(provisioningMachines) != 0 {
+		if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeMachineStatus, metav1.ConditionUnknown,
+			"checkMachineStatusPending", fmt.Sprintf("%d machines are being provisioned", len(provisioningMachines))); err != nil {
+			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+			return false, err
 		}
+		return false, nil
 	}
 
 	// Ensure all nodes plugins are running successfully
 	for i := range validNodeList {
-		err = c.ensureNodePlugins(ctx, wObj, validNodeList[i])
+		installed, err := c.ensureNodePlugins(ctx, wObj, validNodeList[i])
+		if err == nil && !installed {
+			// The requeue of the unprovisioned workspace tries again.
+			return false, nil
+		}
 		if err != nil {
 			if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeResourceStatus, metav1.ConditionFalse,
 				"workspaceResourceStatusFailed", err.Error()); err != nil {
 				klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
-				return err
+				return false, err
 			}
-			return err
+			return false, err
 		}
 	}
 
 	if err = c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeMachineStatus, metav1.ConditionTrue,
 		"installNodePluginsSuccess", "machines plugins have been installed successfully"); err != nil {
 		klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
-		return err
+		return false, err
 	}
 
 	// Add the valid nodes names to the WorkspaceStatus.WorkerNodes
@@ -211,18 +486,57 @@ func (c *WorkspaceReconciler) applyWorkspaceResource(ctx context.Context, wObj *
 		if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeResourceStatus, metav1.ConditionFalse,
 			"workspaceResourceStatusFailed", err.Error()); err != nil {
 			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
-			return err
+			return false, err
 		}
-		return err
+		return false, err
 	}
 
 	if err = c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeResourceStatus, metav1.ConditionTrue,
 		"workspaceResourceStatusSuccess", "workspace resource is ready"); err != nil {
 		klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
-		return err
+		return false, err
 	}
 
-	return nil
+	return true, nil
+}
+
+// checkProvisioningMachines returns the names of the machines of the workspace that are still being provisioned.
+// A machine that failed to launch is deleted and its instance type is recorded as failed, so that the machine is
+// replaced with one of the fallback instance types.
+func (c *WorkspaceReconciler) checkProvisioningMachines(ctx context.Context, wObj *kdmv1alpha1.Workspace) ([]string, error) {
+	klog.InfoS("checkProvisioningMachines", "workspace", klog.KObj(wObj))
+	nodes, err := c.NodeProvisioner.ListForWorkspace(ctx, wObj)
+	if err != nil {
+		return nil, err
+	}
+
+	var provisioningMachines []string
+	for _, node := range machine.ActiveNodes(wObj, nodes) {
+		phase, message, err := c.NodeProvisioner.Status(ctx, wObj, node)
+		if err != nil {
+			return nil, err
+		}
+		switch phase {
+		case machine.MachinePhaseFailed:
+			klog.InfoS("machine failed to launch", "machine", node.Name, "message", message)
+			if err := c.NodeProvisioner.Delete(ctx, node); err != nil && !apierrors.IsNotFound(err) {
+				return nil, err
+			}
+			if err := c.updateWorkspaceStatusWithInstanceTypeFailure(ctx, wObj, node.InstanceType, message); err != nil {
+				klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+				return nil, err
+			}
+			if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeMachineProvisioned, metav1.ConditionFalse,
+				"machineFailedProvision", fmt.Sprintf("machine %s of instance type %s failed to launch: %s",
+					node.Name, node.InstanceType, message)); err != nil {
+				klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+				return nil, err
+			}
+		case machine.MachinePhaseProvisioning:
+			provisioningMachines = append(provisioningMachines, node.Name)
+		}
+	}
+	return provisioningMachines, nil
 }
 
 // validateCurrentClusterNodes checks if the current cluster nodes match the labelSelector and instanceType.
@@ -242,9 +556,7 @@ func (c *WorkspaceReconciler) validateCurrentClusterNodes(ctx context.Context, w
 	for index := range nodeList.Items {
 		nodeObj := nodeList.Items[index]
 		foundInstanceType := c.validateNodeInstanceType(ctx, wObj, lo.ToPtr(nodeObj))
-		_, statusRunning := lo.Find(nodeObj.Status.Conditions, func(condition corev1.NodeCondition) bool {
-			return condition.Type == corev1.NodeReady && condition.Status == corev1.ConditionTrue
-		})
+		statusRunning := isNodeReady(&nodeObj)
 
 		if foundInstanceType && statusRunning {
 			klog.InfoS("found a current valid node", "name", nodeObj.Name)
@@ -255,135 +567,186 @@ func (c *WorkspaceReconciler) validateCurrentClusterNodes(ctx context.Context, w
 	return validCurrentNodeList, nil
 }
 
-// check if node has the required instanceType
+// check if node has one of the accepted instanceTypes
 func (c *WorkspaceReconciler) validateNodeInstanceType(ctx context.Context, wObj *kdmv1alpha1.Workspace, nodeObj *corev1.Node) bool {
 	klog.InfoS("validateNodeInstanceType", "workspace", klog.KObj(wObj))
 
+	instanceTypes := utils.GetInstanceTypes(wObj)
 	if instanceTypeLabel, found := nodeObj.Labels[corev1.LabelInstanceTypeStable]; found {
-		if instanceTypeLabel != wObj.Resource.InstanceType {
-			klog.InfoS("node has instance type which does not match the workspace instance type", "node",
-				nodeObj.Name, "InstanceType", wObj.Resource.InstanceType)
+		if !lo.Contains(instanceTypes, instanceTypeLabel) {
+			klog.InfoS("node has instance type which does not match the workspace instance types", "node",
+				nodeObj.Name, "InstanceType", instanceTypeLabel, "workspaceInstanceTypes", instanceTypes)
 			return false
 		}
 	}
 	klog.InfoS("node instance type matches the workspace one", "node",
-		nodeObj.Name, "InstanceType", wObj.Resource.InstanceType)
+		nodeObj.Name, "InstanceTypes", instanceTypes)
 	return true
 }
 
-// createAndValidateNode creates a new machine and validates status.
-func (c *WorkspaceReconciler) createAndValidateNode(ctx context.Context, wObj *kdmv1alpha1.Workspace) (*corev1.Node, error) {
-	klog.InfoS("createAndValidateNode", "workspace", klog.KObj(wObj))
-	newMachine := machine.GenerateMachineManifest(ctx, wObj)
-
-	if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeMachineProvisioned, metav1.ConditionUnknown,
-		"machineProvisioning", fmt.Sprintf("machine %s is getting provisioned", newMachine.Name)); err != nil {
-		klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
-		return nil, err
+// checkQuota returns true if the workspace fits in the GPU quotas of its namespace. Otherwise the workspace is queued
+// until other workspaces release their GPUs or the quotas are raised.
+func (c *WorkspaceReconciler) checkQuota(ctx context.Context, wObj *kdmv1alpha1.Workspace) (bool, error) {
+	catalog, err := c.InstanceTypes.Load(ctx)
+	if err != nil {
+		return false, err
 	}
-
-	if err := machine.CreateMachine(ctx, newMachine, c.Client); err != nil {
-		klog.ErrorS(err, "failed to create machine", "machine", newMachine.Name)
-		if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeMachineProvisioned, metav1.ConditionFalse,
-			"machineFailedProvision", err.Error()); err != nil {
+	if err := quota.Check(ctx, c.Client, catalog, wObj); err != nil {
+		if !quota.IsQuotaExceeded(err) {
+			return false, err
+		}
+		if err := c.enqueueWorkspace(ctx, wObj, queueReasonQuota, err.Error()); err != nil {
 			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
-			return nil, err
+			return false, err
 		}
-		return nil, err
+		return false, nil
 	}
-	klog.InfoS("a new machine has been created", "machine", newMachine.Name)
+	return true, nil
+}
 
-	err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeMachineProvisioned, metav1.ConditionTrue,
-		"machineProvisionSuccess", "machine has been provisioned successfully")
-	if err != nil {
-		klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
-		return nil, err
+// selectInstanceType selects the instance type new machines are provisioned with: the first accepted instance type
+// that has not failed to launch within instanceTypeRetryInterval. When all of them failed recently, the workspace is
+// queued until the first one can be retried and false is returned.
+func (c *WorkspaceReconciler) selectInstanceType(ctx context.Context, wObj *kdmv1alpha1.Workspace) (bool, error) {
+	now := time.Now()
+	var retryAt time.Time
+	for _, instanceType := range utils.GetInstanceTypes(wObj) {
+		failure, found := lo.Find(wObj.Status.InstanceTypeFailures, func(f kdmv1alpha1.InstanceTypeFailure) bool {
+			return f.InstanceType == instanceType
+		})
+		if found && now.Before(failure.LastFailureTime.Add(instanceTypeRetryInterval)) {
+			if nextTry := failure.LastFailureTime.Add(instanceTypeRetryInterval); retryAt.IsZero() || nextTry.Before(retryAt) {
+				retryAt = nextTry
+			}
+			continue
+		}
+		if wObj.Status.InstanceType == instanceType {
+			return true, nil
+		}
+		klog.InfoS("selectInstanceType", "workspace", klog.KObj(wObj), "instanceType", instanceType)
+		wObj.Status.InstanceType = instanceType
+		return true, c.updateWorkspaceStatus(ctx, wObj)
 	}
 
-	if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeMachineStatus, metav1.ConditionUnknown,
-		"checkMachineStatusPending", fmt.Sprintf("checking machine %s status", newMachine.Name)); err != nil {
+	if err := c.enqueueWorkspace(ctx, wObj, queueReasonInstanceTypesUnavailable,
+		fmt.Sprintf("machines of all instance types failed to launch, retrying at %s", retryAt.UTC().Format(time.RFC3339))); err != nil {
 		klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
-		return nil, err
+		return false, err
 	}
+	return false, nil
+}
+
+// createMachine creates a new machine for the workspace without waiting for it to be launched.
+func (c *WorkspaceReconciler) createMachine(ctx context.Context, wObj *kdmv1alpha1.Workspace) (string, error) {
+	klog.InfoS("createMachine", "workspace", klog.KObj(wObj))
 
-	// check machine status until it is ready
-	err = machine.CheckMachineStatus(ctx, newMachine, c.Client)
+	machineName, err := c.NodeProvisioner.Provision(ctx, wObj)
 	if err != nil {
-		if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeMachineStatus, metav1.ConditionFalse,
-			"checkMachineStatusFailed", err.Error()); err != nil {
+		if machine.IsNodeProvisioningDisabled(err) {
+			return "", err
+		}
+		klog.ErrorS(err, "failed to create machine", "workspace", klog.KObj(wObj))
+		if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeMachineProvisioned, metav1.ConditionFalse,
+			"machineFailedProvision", err.Error()); err != nil {
 			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
-			return nil, err
+			return "", err
 		}
-		return nil, err
+		return "", err
 	}
+	klog.InfoS("a new machine has been created", "machine", machineName)
 
-	// get the node object from the machine status nodeName.
-	return k8sresources.GetNode(ctx, newMachine.Status.NodeName, c.Client)
+	if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeMachineProvisioned, metav1.ConditionTrue,
+		"machineProvisionSuccess", fmt.Sprintf("machine %s has been created", machineName)); err != nil {
+		klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+		return "", err
+	}
+	return machineName, nil
 }
 
-// ensureNodePlugins ensures node plugins (Nvidia and DADI) are installed.
-func (c *WorkspaceReconciler) ensureNodePlugins(ctx context.Context, wObj *kdmv1alpha1.Workspace, nodeObj *corev1.Node) error {
+// ensureNodePlugins ensures node plugins (Nvidia and DADI) are installed. It returns false without waiting when the
+// node cannot be labelled yet, the workspace is requeued to try again.
+func (c *WorkspaceReconciler) ensureNodePlugins(ctx context.Context, wObj *kdmv1alpha1.Workspace, nodeObj *corev1.Node) (bool, error) {
 	klog.InfoS("EnsureNodePlugins", "node", klog.KObj(nodeObj))
 
-	var foundNvidiaPlugin bool
+	if nodeObj == nil {
+		return false, apierrors.NewNotFound(core.Resource("nodes"), "")
+	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-			if nodeObj == nil {
-				return errors.NewNotFound(core.Resource("nodes"), nodeObj.Name)
-			}
+	//Nvidia Plugin
+	if !k8sresources.CheckNvidiaPlugin(ctx, nodeObj) {
+		err := k8sresources.UpdateNodeWithLabel(ctx, nodeObj.Name, k8sresources.LabelKeyNvidia, k8sresources.LabelValueNvidia, c.Client)
+		if err != nil {
+			return c.waitForNodePlugins(ctx, wObj, nodeObj, "nvidia", err)
+		}
+	}
 
-			//Nvidia Plugin
-			foundNvidiaPlugin = k8sresources.CheckNvidiaPlugin(ctx, nodeObj)
-			if !foundNvidiaPlugin {
-				err := k8sresources.UpdateNodeWithLabel(ctx, nodeObj.Name, k8sresources.LabelKeyNvidia, k8sresources.LabelValueNvidia, c.Client)
-				if err != nil {
-					if errors.IsNotFound(err) {
-						klog.ErrorS(err, "nvidia plugin cannot be installed, node not found", "node", nodeObj.Name)
-						if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeMachineStatus, metav1.ConditionFalse,
-							"checkMachineStatusFailed", err.Error()); err != nil {
-							klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
-							return err
-						}
-						return err
-					}
-					if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeMachineStatus, metav1.ConditionUnknown, "InstallNodePluginsWaiting",
-						fmt.Sprintf("waiting for plugins to get installed on node %s", nodeObj.Name)); err != nil {
-						return err
-					}
-					time.Sleep(1 * time.Second)
-					continue
-				}
-			}
+	//DADI plugin
+	if err := k8sresources.CheckDADIPlugin(ctx, nodeObj, c.Client); err != nil {
+		if err := k8sresources.UpdateNodeWithLabel(ctx, nodeObj.Name, k8sresources.LabelKeyCustomGPUProvisioner,
+			k8sresources.GPUString, c.Client); err != nil {
+			return c.waitForNodePlugins(ctx, wObj, nodeObj, "DADI", err)
+		}
+	}
 
-			//DADI plugin
-			if err := k8sresources.CheckDADIPlugin(ctx, nodeObj, c.Client); err != nil {
-				if err := k8sresources.UpdateNodeWithLabel(ctx, nodeObj.Name, k8sresources.LabelKeyCustomGPUProvisioner,
-					k8sresources.GPUString, c.Client); err != nil {
-					if errors.IsNotFound(err) {
-						klog.ErrorS(err, "DADI plugin cannot be installed, node not found", "node", nodeObj.Name)
-						if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeMachineStatus, metav1.ConditionFalse,
-							"checkMachineStatusFailed", err.Error()); err != nil {
-							klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
-							return err
-						}
-						return err
-					}
-					if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeMachineStatus, metav1.ConditionUnknown, "InstallNodePluginsWaiting",
-						fmt.Sprintf("waiting for plugins to get installed on node %s", nodeObj.Name)); err != nil {
-						return err
-					}
-					time.Sleep(1 * time.Second)
-					continue
-				}
-			}
+	return true, nil
+}
 
-			return nil
+// waitForNodePlugins handles the failure to label the node for a plugin: the workspace fails if the node is gone,
+// and waits for the plugins otherwise.
+func (c *WorkspaceReconciler) waitForNodePlugins(ctx context.Context, wObj *kdmv1alpha1.Workspace, nodeObj *corev1.Node,
+	plugin string, err error) (bool, error) {
+	if apierrors.IsNotFound(err) {
+		klog.ErrorS(err, "plugin cannot be installed, node not found", "plugin", plugin, "node", nodeObj.Name)
+		if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeMachineStatus, metav1.ConditionFalse,
+			"checkMachineStatusFailed", err.Error()); err != nil {
+			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+			return false, err
 		}
+		return false, err
+	}
+	klog.ErrorS(err, "failed to label node for plugin, retrying", "plugin", plugin, "node", nodeObj.Name)
+	if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeMachineStatus, metav1.ConditionUnknown, "InstallNodePluginsWaiting",
+		fmt.Sprintf("waiting for plugins to get installed on node %s", nodeObj.Name)); err != nil {
+		return false, err
 	}
+	return false, nil
+}
+
+// ensureInstanceType selects the cheapest instance type of the catalog that fits the preset when the workspace
+// does not specify one, and records it in Status.SelectedInstanceType so that the machines of the workspace keep the
+// same instance type. The instance type new machines are provisioned with is selected among it and the fallback
+// instance types by selectInstanceType.
+func (c *WorkspaceReconciler) ensureInstanceType(ctx context.Context, wObj *kdmv1alpha1.Workspace) error {
+	if wObj.Resource.InstanceType != "" || wObj.Status.SelectedInstanceType != "" ||
+		(wObj.Inference.Preset.Name == "" && wObj.Training.Preset.Name == "") {
+		return nil
+	}
+	catalog, err := c.InstanceTypes.Load(ctx)
+	if err != nil {
+		return err
+	}
+	instanceType, err := inference.SelectInstanceType(ctx, wObj, catalog, c.Client)
+	if err != nil {
+		return err
+	}
+	klog.InfoS("ensureInstanceType", "workspace", klog.KObj(wObj), "instanceType", instanceType)
+	wObj.Status.SelectedInstanceType = instanceType
+	return c.updateWorkspaceStatus(ctx, wObj)
+}
+
+// ensurePriority records the priority of the workspace in Status.Priority, taken from its PriorityClass when the
+// workspace does not specify one.
+func (c *WorkspaceReconciler) ensurePriority(ctx context.Context, wObj *kdmv1alpha1.Workspace) error {
+	priority, err := k8sresources.ResolveWorkspacePriority(ctx, wObj, c.Client)
+	if err != nil {
+		return err
+	}
+	if lo.FromPtr(priority) == wObj.Status.Priority {
+		return nil
+	}
+	klog.InfoS("ensurePriority", "workspace", klog.KObj(wObj), "priorityClassName", wObj.Resource.PriorityClassName, "priority", lo.FromPtr(priority))
+	wObj.Status.Priority = lo.FromPtr(priority)
+	return c.updateWorkspaceStatus(ctx, wObj)
 }
 
 func (c *WorkspaceReconciler) applyAnnotations(ctx context.Context, wObj *kdmv1alpha1.Workspace) error {
@@ -399,15 +762,29 @@ func (c *WorkspaceReconciler) applyAnnotations(ctx context.Context, wObj *kdmv1a
 	}
 
 	existingObj, err := k8sresources.GetService(ctx, wObj.Name, wObj.Namespace, c.Client)
-	if err != nil && !errors.IsNotFound(err) {
+	if err != nil && !apierrors.IsNotFound(err) {
 		return err
 	}
+	isStatefulSet, err := c.isDistributedInference(ctx, wObj)
+	if err != nil {
+		return err
+	}
+	serviceObj := k8sresources.GenerateServiceManifest(ctx, wObj, serviceType, isStatefulSet)
+	if routeToActivator(wObj) {
+		serviceObj.Spec.Selector = k8sresources.GenerateActivatorLabels(wObj)
+	}
+
 	if existingObj != nil {
 		klog.InfoS("a service already exists for workspace", "workspace", klog.KObj(wObj), "serviceType", serviceType)
-		return nil
+		// The selector changes when a distributed model switches between a deployment and a statefulset, and when
+		// a workspace with scaleToZero switches between its activator and its inference pods.
+		if reflect.DeepEqual(existingObj.Spec.Selector, serviceObj.Spec.Selector) {
+			return nil
+		}
+		existingObj.Spec.Selector = serviceObj.Spec.Selector
+		return c.Client.Update(ctx, existingObj, &client.UpdateOptions{})
 	}
 
-	serviceObj := k8sresources.GenerateServiceManifest(ctx, wObj, serviceType)
 	err = k8sresources.CreateService(ctx, serviceObj, c.Client)
 	if err != nil {
 		return err
@@ -417,69 +794,272 @@ func (c *WorkspaceReconciler) applyAnnotations(ctx context.Context, wObj *kdmv1a
 	return nil
 }
 
-// applyInference applies inference spec.
-func (c *WorkspaceReconciler) applyInference(ctx context.Context, wObj *kdmv1alpha1.Workspace) error {
+// applyInference applies inference spec. The existing workload is updated when the desired pod template differs
+// from the one it was created with: deployments are rolled out in place, statefulsets are recreated.
+// The returned status tells whether the workload is ready; the controller is requeued by the workload status events.
+func (c *WorkspaceReconciler) applyInference(ctx context.Context, wObj *kdmv1alpha1.Workspace) (*inference.InferenceStatus, error) {
 	klog.InfoS("applyInference", "workspace", klog.KObj(wObj))
 
-	existingObj, err := k8sresources.GetDeployment(ctx, wObj.Name, wObj.Namespace, c.Client)
-	if err != nil && !errors.IsNotFound(err) {
+	status, err := c.reconcileInference(ctx, wObj)
+	if err != nil {
 		if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeInferenceStatus, metav1.ConditionFalse,
 			"WorkspaceInferenceStatusFailed", err.Error()); err != nil {
 			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
-			return err
+			return nil, err
+		}
+		return nil, err
+	}
+	return status, nil
+}
+
+func (c *WorkspaceReconciler) reconcileInference(ctx context.Context, wObj *kdmv1alpha1.Workspace) (*inference.InferenceStatus, error) {
+	desiredObj, err := c.generateInference(ctx, wObj)
+	if err != nil {
+		klog.ErrorS(err, "no inference has been created")
+		return nil, err
+	}
+
+	existingObj, err := k8sresources.GetDeployment(ctx, wObj.Name, wObj.Namespace, c.Client)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	existingStatefulSetObj, err := k8sresources.GetStatefulSet(ctx, wObj.Name, wObj.Namespace, c.Client)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	switch desired := desiredObj.(type) {
+	case *appsv1.Deployment:
+		if existingStatefulSetObj != nil {
+			return c.deleteInference(ctx, wObj, existingStatefulSetObj)
+		}
+		if existingObj != nil {
+			if k8sresources.GetSpecHash(existingObj) != k8sresources.GetSpecHash(desired) {
+				if err := c.rolloutInference(ctx, wObj, desired); err != nil {
+					return nil, err
+				}
+			} else {
+				klog.InfoS("a deployment already exists for workspace", "workspace", klog.KObj(wObj))
+				if err := inference.ScaleInference(ctx, wObj, existingObj, c.Client); err != nil {
+					return nil, err
+				}
+			}
+			return c.reportInferenceStatus(ctx, wObj, existingObj)
 		}
+	case *appsv1.StatefulSet:
+		if existingObj != nil {
+			return c.deleteInference(ctx, wObj, existingObj)
+		}
+		if existingStatefulSetObj != nil {
+			if k8sresources.GetSpecHash(existingStatefulSetObj) == k8sresources.GetSpecHash(desired) {
+				klog.InfoS("a statefulset already exists for workspace", "workspace", klog.KObj(wObj))
+				return c.reportInferenceStatus(ctx, wObj, existingStatefulSetObj)
+			}
+			// All ranks of a distributed model have to run the same spec, so the statefulset is recreated.
+			return c.deleteInference(ctx, wObj, existingStatefulSetObj)
+		}
+	}
+
+	if err := inference.CreateInference(ctx, wObj, desiredObj, c.Client); err != nil {
+		return nil, err
+	}
+	return c.reportInferenceStatus(ctx, wObj, desiredObj)
+}
+
+// deleteInference starts deleting the inference workload replaced by the desired one and reports that the workspace
+// waits for it. The desired workload is created once the deletion event of the old one brings the workspace back.
+func (c *WorkspaceReconciler) deleteInference(ctx context.Context, wObj *kdmv1alpha1.Workspace,
+	workloadObj client.Object) (*inference.InferenceStatus, error) {
+	if _, err := inference.DeleteInference(ctx, workloadObj, c.Client); err != nil {
+		return nil, err
+	}
+	status := inference.GetDeletingStatus(workloadObj)
+	if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeInferenceStatus, status.Status,
+		status.Reason, status.Message); err != nil {
+		klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+		return nil, err
+	}
+	return status, nil
+}
+
+// reportInferenceStatus updates InferenceStatus and the replicas of the workspace from the status of the inference workload.
+func (c *WorkspaceReconciler) reportInferenceStatus(ctx context.Context, wObj *kdmv1alpha1.Workspace,
+	workloadObj client.Object) (*inference.InferenceStatus, error) {
+	status, err := inference.GetInferenceStatus(ctx, workloadObj, c.Client)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeInferenceStatus, status.Status,
+		status.Reason, status.Message); err != nil {
+		klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+		return nil, err
+	}
+	if err := c.updateWorkspaceStatusWithReplicas(ctx, wObj, status.Replicas, status.ReadyReplicas); err != nil {
+		klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+		return nil, err
+	}
+	return status, nil
+}
+
+// generateInference generates the desired inference workload of the workspace.
+func (c *WorkspaceReconciler) generateInference(ctx context.Context, wObj *kdmv1alpha1.Workspace) (client.Object, error) {
+	if wObj.Inference.Template != nil {
+		return inference.GenerateTemplateInference(ctx, wObj), nil
+	}
+
+	preset, err := inference.ResolvePreset(ctx, wObj.Inference.Preset.Name, c.Client)
+	if err != nil {
+		return nil, err
+	}
+	return inference.GeneratePresetInference(ctx, wObj, preset, wObj.Inference.Preset.Volume)
+}
+
+// rolloutInference starts rolling the inference deployment out to the desired spec.
+// A surge replica is only started when a spare GPU node that matches the workspace can run it.
+func (c *WorkspaceReconciler) rolloutInference(ctx context.Context, wObj *kdmv1alpha1.Workspace, desiredObj *appsv1.Deployment) error {
+	klog.InfoS("rolloutInference", "workspace", klog.KObj(wObj))
+
+	nodes, err := c.validateCurrentClusterNodes(ctx, wObj)
+	if err != nil {
 		return err
 	}
+	desiredObj.Spec.Strategy = k8sresources.GenerateRollingUpdateStrategy(len(nodes) > int(lo.FromPtr(desiredObj.Spec.Replicas)))
+	return inference.RolloutInference(ctx, wObj, desiredObj, c.Client)
+}
 
-	if existingObj != nil {
-		klog.InfoS("a deployment already exists for workspace", "workspace", klog.KObj(wObj))
-		return nil
+// releaseExtraMachines deletes the machines created for the workspace whose nodes are no longer in Status.WorkerNodes,
+// e.g., after the workspace has been scaled down. Nodes that were not provisioned by kdm are left untouched, and so
+// are the nodes still running pods of the workspace. It returns false if some machines are kept for their pods.
+func (c *WorkspaceReconciler) releaseExtraMachines(ctx context.Context, wObj *kdmv1alpha1.Workspace) (bool, error) {
+	klog.InfoS("releaseExtraMachines", "workspace", klog.KObj(wObj))
+	nodes, err := c.NodeProvisioner.ListForWorkspace(ctx, wObj)
+	if err != nil {
+		return false, err
+	}
+	podNodes, err := c.listWorkspacePodNodes(ctx, wObj)
+	if err != nil {
+		return false, err
 	}
 
-	// TODO check if preset exists, template shouldn't.
-	volume := wObj.Inference.Preset.Volume
-	if volume == nil {
-		volume = []corev1.Volume{}
+	released := true
+	for _, node := range nodes {
+		if node.Deleting || node.NodeName == "" || lo.Contains(wObj.Status.WorkerNodes, node.NodeName) {
+			continue
+		}
+		if lo.Contains(podNodes, node.NodeName) {
+			klog.InfoS("keeping a machine running pods of workspace", "workspace", klog.KObj(wObj), "machine", node.Name, "node", node.NodeName)
+			released = false
+			continue
+		}
+		if err := c.NodeProvisioner.Delete(ctx, node); err != nil && !apierrors.IsNotFound(err) {
+			return false, err
+		}
+		klog.InfoS("released a machine that is no longer needed by workspace", "workspace", klog.KObj(wObj), "machine", node.Name)
 	}
+	return released, nil
+}
 
-	presetName := wObj.Inference.Preset.Name
-	switch presetName {
-	case kdmv1alpha1.PresetSetModelllama2A:
-		err = inference.CreateLLAMA2APresetModel(ctx, wObj, volume, torchRunParams, c.Client)
-	case kdmv1alpha1.PresetSetModelllama2B:
-		err = inference.CreateLLAMA2BPresetModel(ctx, wObj, []corev1.Volume{
-			{
-				Name: "dshm",
-				VolumeSource: corev1.VolumeSource{
-					EmptyDir: &corev1.EmptyDirVolumeSource{
-						Medium: "Memory",
-					},
-				},
-			},
-		}, torchRunParams, c.Client)
-	case kdmv1alpha1.PresetSetModelllama2C:
-		err = inference.CreateLLAMA2CPresetModel(ctx, wObj, volume, torchRunParams, c.Client)
-	default:
-		err = fmt.Errorf("preset model %s is not supported", presetName)
-		klog.ErrorS(err, "no inference has been created")
+// listWorkspacePodNodes returns the names of the nodes running the inference and training pods of the workspace
+// that are not terminating.
+func (c *WorkspaceReconciler) listWorkspacePodNodes(ctx context.Context, wObj *kdmv1alpha1.Workspace) ([]string, error) {
+	podList := &corev1.PodList{}
+	if err := c.List(ctx, podList, client.InNamespace(wObj.Namespace),
+		client.MatchingLabels{kdmv1alpha1.LabelWorkspaceName: wObj.Name}); err != nil {
+		return nil, err
 	}
+	var nodeNames []string
+	for i := range podList.Items {
+		podObj := &podList.Items[i]
+		if _, found := podObj.Labels[kdmv1alpha1.LabelWorkspaceActivator]; found || podObj.Spec.NodeName == "" ||
+			!podObj.DeletionTimestamp.IsZero() || podObj.Status.Phase == corev1.PodSucceeded || podObj.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		nodeNames = append(nodeNames, podObj.Spec.NodeName)
+	}
+	return lo.Uniq(nodeNames), nil
+}
+
+// isDistributedInference returns true if the preset model of the workspace is sharded across several nodes.
+func (c *WorkspaceReconciler) isDistributedInference(ctx context.Context, wObj *kdmv1alpha1.Workspace) (bool, error) {
+	if wObj.Inference.Template != nil {
+		return false, nil
+	}
+	preset, err := inference.ResolvePreset(ctx, wObj.Inference.Preset.Name, c.Client)
 	if err != nil {
-		if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeInferenceStatus, metav1.ConditionFalse,
-			"WorkspaceInferenceStatusFailed", err.Error()); err != nil {
-			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+		return false, err
+	}
+	return inference.IsDistributed(wObj, preset.GetInferenceParameters()), nil
+}
+
+// applyTraining creates the training job of the workspace and reports its progress.
+func (c *WorkspaceReconciler) applyTraining(ctx context.Context, wObj *kdmv1alpha1.Workspace) error {
+	klog.InfoS("applyTraining", "workspace", klog.KObj(wObj))
+
+	jobObj, err := k8sresources.GetJob(ctx, training.JobName(wObj), wObj.Namespace, c.Client)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	if apierrors.IsNotFound(err) {
+		preset, err := inference.ResolvePreset(ctx, wObj.Training.Preset.Name, c.Client)
+		if err == nil {
+			err = training.CreatePresetTraining(ctx, wObj, preset, c.Client)
+		}
+		if err != nil {
+			if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeTrainingStatus, metav1.ConditionFalse,
+				"trainingFailed", err.Error()); err != nil {
+				klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+				return err
+			}
 			return err
 		}
-		return err
+		return c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeTrainingStatus, metav1.ConditionUnknown,
+			"trainingJobCreated", "training job has been created")
 	}
 
-	if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeInferenceStatus, metav1.ConditionTrue,
-		"WorkspaceInferenceStatusSuccess", "Inference has been deployed successfully"); err != nil {
+	status, reason, message := training.GetTrainingStatus(jobObj)
+	if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeTrainingStatus, status, reason, message); err != nil {
 		klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
 		return err
 	}
+	if status == metav1.ConditionFalse {
+		// Retrying does not help a failed job, the user needs to update or recreate the workspace.
+		return reconcile.TerminalError(errors.New(message))
+	}
+	return nil
+}
+
+// checkLabelSelector rejects workspaces without node labels, which the validating webhook rejects when it is enabled.
+// The labels select the GPU nodes and the inference pods of the workspace.
+func checkLabelSelector(wObj *kdmv1alpha1.Workspace) error {
+	if wObj.Resource.LabelSelector == nil || len(wObj.Resource.LabelSelector.MatchLabels) == 0 {
+		return reconcile.TerminalError(errors.New("resource.labelSelector.matchLabels is required"))
+	}
 	return nil
 }
 
+// checkReplicas rejects workspaces of a preset model sharded across the workspace nodes with spec.replicas, which
+// the validating webhook rejects when it is enabled. Such workspaces are scaled by resource.count.
+func (c *WorkspaceReconciler) checkReplicas(ctx context.Context, wObj *kdmv1alpha1.Workspace) error {
+	if wObj.Spec.Replicas == nil || wObj.Inference.Preset.Name == "" {
+		return nil
+	}
+	preset, err := inference.ResolvePreset(ctx, wObj.Inference.Preset.Name, c.Client)
+	if err != nil {
+		return err
+	}
+	if preset.GetInferenceParameters().DistributedInference {
+		return reconcile.TerminalError(fmt.Errorf("preset %s is sharded across the workspace nodes and cannot be scaled by replicas, use resource.count",
+			preset.Name()))
+	}
+	return nil
+}
+
+// hasInference returns true if the workspace runs an inference workload.
+func hasInference(wObj *kdmv1alpha1.Workspace) bool {
+	return wObj.Inference.Preset.Name != "" || wObj.Inference.Template != nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (c *WorkspaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	c.Recorder = mgr.GetEventRecorderFor("Workspace")
@@ -489,27 +1069,127 @@ func (c *WorkspaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	}); err != nil {
 		return err
 	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &kdmv1alpha1.Workspace{}, presetNameIndexKey, func(rawObj client.Object) []string {
+		wObj := rawObj.(*kdmv1alpha1.Workspace)
+		names := lo.Uniq([]string{string(wObj.Inference.Preset.Name), string(wObj.Training.Preset.Name)})
+		return lo.Without(names, "")
+	}); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &kdmv1alpha1.Workspace{}, workerNodesIndexKey, func(rawObj client.Object) []string {
+		return rawObj.(*kdmv1alpha1.Workspace).Status.WorkerNodes
+	}); err != nil {
+		return err
+	}
 
-	return ctrl.NewControllerManagedBy(mgr).
+	bldr := ctrl.NewControllerManagedBy(mgr).
 		For(&kdmv1alpha1.Workspace{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&appsv1.Deployment{}).
+		Owns(&appsv1.StatefulSet{}).
+		Owns(&corev1.Service{}).
+		Owns(&batchv1.Job{}).
+		Watches(
+			&corev1.Node{}, c.watchNodes(), builder.WithPredicates(nodeReadinessChangedPredicate())).
 		Watches(
-			&v1alpha5.Machine{}, c.watchMachines()).
-		WithOptions(controller.Options{MaxConcurrentReconciles: 5}).
-		Complete(c)
+			&kdmv1alpha1.ModelPreset{}, c.watchModelPresets(), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		WithOptions(controller.Options{MaxConcurrentReconciles: 5})
+	// Nodes brought by the user are not tracked by any object.
+	if machineObj := c.NodeProvisioner.WatchedObject(); machineObj != nil {
+		bldr = bldr.Watches(machineObj, c.watchMachines())
+	}
+	return bldr.Complete(c)
 }
 
-// watches	for machine with label LabelCreatedByWorkspace equals workspace name.
+// watches for the objects tracking provisioned nodes and enqueues the workspace they were created for, found by the workspace labels or,
+// for machines created before the namespace label was added, by the workspace owner reference.
 func (c *WorkspaceReconciler) watchMachines() handler.EventHandler {
 	return handler.EnqueueRequestsFromMapFunc(
 		func(ctx context.Context, o client.Object) []reconcile.Request {
-			machineObj := o.(*v1alpha5.Machine)
+			name, namespace := o.GetLabels()[kdmv1alpha1.LabelWorkspaceName], o.GetLabels()[kdmv1alpha1.LabelWorkspaceNamespace]
+			if name != "" && namespace != "" {
+				return []reconcile.Request{
+					{
+						NamespacedName: client.ObjectKey{
+							Name:      name,
+							Namespace: namespace,
+						},
+					},
+				}
+			}
+
+			ownerRef, found := lo.Find(o.GetOwnerReferences(), func(ref metav1.OwnerReference) bool {
+				return ref.Kind == "Workspace" && ref.APIVersion == kdmv1alpha1.GroupVersion.String()
+			})
+			if !found {
+				return nil
+			}
+			// Owner references do not record the namespace of the owner, so the workspace is looked up by its uid.
+			workspaceList := &kdmv1alpha1.WorkspaceList{}
+			if err := c.Client.List(ctx, workspaceList); err != nil {
+				klog.ErrorS(err, "failed to list workspaces for machine", "machine", o.GetName())
+				return nil
+			}
+			wObj, found := lo.Find(workspaceList.Items, func(wObj kdmv1alpha1.Workspace) bool {
+				return wObj.UID == ownerRef.UID
+			})
+			if !found {
+				return nil
+			}
 			return []reconcile.Request{
 				{
-					NamespacedName: client.ObjectKey{
-						Name:      machineObj.Name,
-						Namespace: machineObj.Namespace,
-					},
+					NamespacedName: client.ObjectKeyFromObject(&wObj),
 				},
 			}
 		})
 }
+
+// watches for nodes and enqueues the workspaces that run on them, so that a lost node is replaced.
+func (c *WorkspaceReconciler) watchNodes() handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(
+		func(ctx context.Context, o client.Object) []reconcile.Request {
+			workspaceList := &kdmv1alpha1.WorkspaceList{}
+			if err := c.Client.List(ctx, workspaceList, client.MatchingFields{workerNodesIndexKey: o.GetName()}); err != nil {
+				klog.ErrorS(err, "failed to list workspaces for node", "node", o.GetName())
+				return nil
+			}
+			return lo.Map(workspaceList.Items, func(wObj kdmv1alpha1.Workspace, _ int) reconcile.Request {
+				return reconcile.Request{
+					NamespacedName: client.ObjectKeyFromObject(&wObj),
+				}
+			})
+		})
+}
+
+// nodeReadinessChangedPredicate filters out the node updates that do not change whether the node is ready,
+// e.g., the periodic status updates of the kubelet.
+func nodeReadinessChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return isNodeReady(e.ObjectOld.(*corev1.Node)) != isNodeReady(e.ObjectNew.(*corev1.Node))
+		},
+	}
+}
+
+func isNodeReady(nodeObj *corev1.Node) bool {
+	_, found := lo.Find(nodeObj.Status.Conditions, func(condition corev1.NodeCondition) bool {
+		return condition.Type == corev1.NodeReady && condition.Status == corev1.ConditionTrue
+	})
+	return found
+}
+
+// watches for model presets and enqueues the workspaces that use them.
+func (c *WorkspaceReconciler) watchModelPresets() handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(
+		func(ctx context.Context, o client.Object) []reconcile.Request {
+			workspaceList := &kdmv1alpha1.WorkspaceList{}
+			if err := c.Client.List(ctx, workspaceList, client.MatchingFields{presetNameIndexKey: o.GetName()}); err != nil {
+				klog.ErrorS(err, "failed to list workspaces for model preset", "modelPreset", o.GetName())
+				return nil
+			}
+			return lo.Map(workspaceList.Items, func(wObj kdmv1alpha1.Workspace, _ int) reconcile.Request {
+				return reconcile.Request{
+					NamespacedName: client.ObjectKeyFromObject(&wObj),
+				}
+			})
+		})
+}
@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newTestReconciler returns a workspace reconciler with a fake client holding the objects.
+func newTestReconciler(t *testing.T, objs ...client.Object) *WorkspaceReconciler {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add the client-go types to the scheme: %v", err)
+	}
+	if err := kdmv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add the kdm types to the scheme: %v", err)
+	}
+	kubeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&kdmv1alpha1.Workspace{}).
+		Build()
+	return &WorkspaceReconciler{Client: kubeClient, Scheme: scheme}
+}
+
+// getWorkspace returns the workspace stored by the fake client.
+func getWorkspace(t *testing.T, c *WorkspaceReconciler, wObj *kdmv1alpha1.Workspace) *kdmv1alpha1.Workspace {
+	t.Helper()
+	got := &kdmv1alpha1.Workspace{}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(wObj), got); err != nil {
+		t.Fatalf("failed to get workspace %s: %v", wObj.Name, err)
+	}
+	return got
+}
+
+func instanceTypeFailure(instanceType string, failedAgo time.Duration) kdmv1alpha1.InstanceTypeFailure {
+	return kdmv1alpha1.InstanceTypeFailure{
+		InstanceType:    instanceType,
+		LastFailureTime: metav1.NewTime(time.Now().Add(-failedAgo)),
+	}
+}
+
+func TestSelectInstanceType(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		instanceType         string
+		fallbacks            []string
+		selectedInstanceType string
+		currentInstanceType  string
+		failures             []kdmv1alpha1.InstanceTypeFailure
+		wantAvailable        bool
+		wantInstanceType     string
+	}{
+		{name: "instance type", instanceType: "A", fallbacks: []string{"B"},
+			wantAvailable: true, wantInstanceType: "A"},
+		{name: "selected instance type", selectedInstanceType: "A", fallbacks: []string{"B"},
+			wantAvailable: true, wantInstanceType: "A"},
+		{name: "fallback of an instance type that failed", instanceType: "A", fallbacks: []string{"B"},
+			failures:      []kdmv1alpha1.InstanceTypeFailure{instanceTypeFailure("A", time.Minute)},
+			wantAvailable: true, wantInstanceType: "B"},
+		{name: "fallback of a selected instance type that failed", selectedInstanceType: "A", fallbacks: []string{"B"},
+			failures:      []kdmv1alpha1.InstanceTypeFailure{instanceTypeFailure("A", time.Minute)},
+			wantAvailable: true, wantInstanceType: "B"},
+		{name: "selected instance type is retried after the fallback", selectedInstanceType: "A", fallbacks: []string{"B"},
+			currentInstanceType: "B",
+			failures:            []kdmv1alpha1.InstanceTypeFailure{instanceTypeFailure("A", instanceTypeRetryInterval+time.Minute)},
+			wantAvailable:       true, wantInstanceType: "A"},
+		{name: "next fallback", selectedInstanceType: "A", fallbacks: []string{"B", "C"}, currentInstanceType: "B",
+			failures: []kdmv1alpha1.InstanceTypeFailure{
+				instanceTypeFailure("A", time.Minute),
+				instanceTypeFailure("B", time.Minute),
+			},
+			wantAvailable: true, wantInstanceType: "C"},
+		{name: "all instance types failed", selectedInstanceType: "A", fallbacks: []string{"B"}, currentInstanceType: "B",
+			failures: []kdmv1alpha1.InstanceTypeFailure{
+				instanceTypeFailure("A", time.Minute),
+				instanceTypeFailure("B", time.Minute),
+			},
+			wantAvailable: false, wantInstanceType: "B"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			wObj := &kdmv1alpha1.Workspace{
+				ObjectMeta: metav1.ObjectMeta{Name: "workspace", Namespace: "default", UID: "uid"},
+				Resource: kdmv1alpha1.ResourceSpec{
+					InstanceType:          tc.instanceType,
+					FallbackInstanceTypes: tc.fallbacks,
+				},
+				Status: kdmv1alpha1.WorkspaceStatus{
+					SelectedInstanceType: tc.selectedInstanceType,
+					InstanceType:         tc.currentInstanceType,
+					InstanceTypeFailures: tc.failures,
+				},
+			}
+			c := newTestReconciler(t, wObj)
+			wObj = getWorkspace(t, c, wObj)
+
+			available, err := c.selectInstanceType(context.Background(), wObj)
+			if err != nil {
+				t.Fatalf("selectInstanceType() error = %v", err)
+			}
+			if available != tc.wantAvailable {
+				t.Errorf("selectInstanceType() = %t, want %t", available, tc.wantAvailable)
+			}
+			got := getWorkspace(t, c, wObj)
+			if got.Status.InstanceType != tc.wantInstanceType {
+				t.Errorf("status.instanceType = %q, want %q", got.Status.InstanceType, tc.wantInstanceType)
+			}
+			if got.Status.SelectedInstanceType != tc.selectedInstanceType {
+				t.Errorf("status.selectedInstanceType = %q, want %q", got.Status.SelectedInstanceType, tc.selectedInstanceType)
+			}
+			if queued := meta.IsStatusConditionTrue(got.Status.Conditions, string(kdmv1alpha1.WorkspaceConditionTypeQueued)); queued == tc.wantAvailable {
+				t.Errorf("queued = %t, want %t", queued, !tc.wantAvailable)
+			}
+		})
+	}
+}
@@ -4,19 +4,95 @@ import (
 	"context"
 
 	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/inference"
+	"github.com/kdm/pkg/k8sresources"
+	"github.com/kdm/pkg/training"
 	"github.com/kdm/pkg/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-// garbageCollectWorkspace remove finalizer associated with workspace object.
+// ensureFinalizer adds the workspace finalizer so that the controller gets to tear the workspace down.
+func (c *WorkspaceReconciler) ensureFinalizer(ctx context.Context, wObj *kdmv1alpha1.Workspace) error {
+	if controllerutil.ContainsFinalizer(wObj, utils.WorkspaceFinalizer) {
+		return nil
+	}
+	klog.InfoS("ensureFinalizer", "workspace", klog.KObj(wObj))
+	controllerutil.AddFinalizer(wObj, utils.WorkspaceFinalizer)
+	return c.Update(ctx, wObj, &client.UpdateOptions{})
+}
+
+// garbageCollectWorkspace tears the workspace down in order: the inference and training workloads are stopped,
+// the services are deleted and then the machines are released according to the reclaim policy. The finalizer is
+// removed once everything is gone.
 func (c *WorkspaceReconciler) garbageCollectWorkspace(ctx context.Context, wObj *kdmv1alpha1.Workspace) (ctrl.Result, error) {
 	klog.InfoS("garbageCollectWorkspace", "workspace", klog.KObj(wObj))
 
+	deleted, err := c.deleteWorkloads(ctx, wObj)
+	if err != nil {
+		if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeInferenceDeleted, metav1.ConditionFalse,
+			"workspaceInferenceDeleteFailed", err.Error()); err != nil {
+			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, err
+	}
+	if !deleted {
+		// The deletion events of the workloads bring the workspace back.
+		if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeInferenceDeleted, metav1.ConditionFalse,
+			"workspaceInferenceDeleting", "waiting for the inference and training workloads to be deleted"); err != nil {
+			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: workloadDeletionRequeueInterval}, nil
+	}
+	if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeInferenceDeleted, metav1.ConditionTrue,
+		"workspaceInferenceDeleted", "inference and training workloads have been deleted"); err != nil {
+		klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+		return ctrl.Result{}, err
+	}
+
+	if err := c.deleteServices(ctx, wObj); err != nil {
+		if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeResourceDeleted, metav1.ConditionFalse,
+			"workspaceResourceDeleteFailed", err.Error()); err != nil {
+			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, err
+	}
+	if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeResourceDeleted, metav1.ConditionTrue,
+		"workspaceResourceDeleted", "workspace services have been deleted"); err != nil {
+		klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+		return ctrl.Result{}, err
+	}
+
+	if err := c.reclaimMachines(ctx, wObj); err != nil {
+		if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeMachineDeleted, metav1.ConditionFalse,
+			"machineDeleteFailed", err.Error()); err != nil {
+			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, err
+	}
+	machineMessage := "machines have been deleted"
+	if wObj.Resource.ReclaimPolicy == kdmv1alpha1.ReclaimPolicyRetain {
+		machineMessage = "machines have been retained for reuse"
+	}
+	if err := c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeMachineDeleted, metav1.ConditionTrue,
+		"machineDeleted", machineMessage); err != nil {
+		klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+		return ctrl.Result{}, err
+	}
+
 	staleWObj := wObj.DeepCopy()
-	staleWObj.SetFinalizers(nil)
+	controllerutil.RemoveFinalizer(staleWObj, utils.WorkspaceFinalizer)
 	if updateErr := c.Update(ctx, staleWObj, &client.UpdateOptions{}); updateErr != nil {
 		klog.ErrorS(updateErr, "failed to remove the finalizer from the workspace",
 			"workspace", klog.KObj(wObj), "workspace", klog.KObj(staleWObj))
@@ -27,3 +103,71 @@ func (c *WorkspaceReconciler) garbageCollectWorkspace(ctx context.Context, wObj
 	controllerutil.RemoveFinalizer(wObj, utils.WorkspaceFinalizer)
 	return ctrl.Result{}, nil
 }
+
+// deleteWorkloads scales the inference deployment to zero and deletes the inference and training workloads and the
+// activator. It returns true once they are gone.
+func (c *WorkspaceReconciler) deleteWorkloads(ctx context.Context, wObj *kdmv1alpha1.Workspace) (bool, error) {
+	klog.InfoS("deleteWorkloads", "workspace", klog.KObj(wObj))
+
+	if err := k8sresources.ScaleDeployment(ctx, wObj.Name, wObj.Namespace, 0, c.Client); err != nil && !apierrors.IsNotFound(err) {
+		return false, err
+	}
+	workloads := []client.Object{
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: wObj.Name, Namespace: wObj.Namespace}},
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: wObj.Name, Namespace: wObj.Namespace}},
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: training.JobName(wObj), Namespace: wObj.Namespace}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: k8sresources.ActivatorName(wObj), Namespace: wObj.Namespace}},
+	}
+	// The workloads are deleted together, the workspace is requeued until all of them are gone.
+	allDeleted := true
+	for _, workloadObj := range workloads {
+		deleted, err := inference.DeleteInference(ctx, workloadObj, c.Client)
+		if err != nil {
+			return false, err
+		}
+		allDeleted = allDeleted && deleted
+	}
+	return allDeleted, nil
+}
+
+// deleteServices deletes the inference service and the headless services of the distributed and training workloads.
+func (c *WorkspaceReconciler) deleteServices(ctx context.Context, wObj *kdmv1alpha1.Workspace) error {
+	klog.InfoS("deleteServices", "workspace", klog.KObj(wObj))
+
+	for _, serviceName := range []string{wObj.Name, inference.HeadlessServiceName(wObj), training.JobName(wObj)} {
+		serviceObj := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: serviceName, Namespace: wObj.Namespace}}
+		if err := k8sresources.DeleteService(ctx, serviceObj, c.Client); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// reclaimMachines deletes the machines provisioned for the workspace. With the Retain reclaim policy the machines are
+// kept and only lose their owner reference, so that deleting the workspace does not garbage collect them.
+func (c *WorkspaceReconciler) reclaimMachines(ctx context.Context, wObj *kdmv1alpha1.Workspace) error {
+	klog.InfoS("reclaimMachines", "workspace", klog.KObj(wObj), "reclaimPolicy", wObj.Resource.ReclaimPolicy)
+	nodes, err := c.NodeProvisioner.ListForWorkspace(ctx, wObj)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		if node.Deleting {
+			continue
+		}
+
+		if wObj.Resource.ReclaimPolicy != kdmv1alpha1.ReclaimPolicyRetain {
+			if err := c.NodeProvisioner.Delete(ctx, node); err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+			continue
+		}
+
+		if err := c.NodeProvisioner.Retain(ctx, wObj, node); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		klog.InfoS("retained a machine of the deleted workspace", "workspace", klog.KObj(wObj), "machine", node.Name)
+	}
+	return nil
+}
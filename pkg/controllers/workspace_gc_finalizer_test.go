@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/inference"
+	"github.com/kdm/pkg/machine"
+	"github.com/kdm/pkg/training"
+	"github.com/kdm/pkg/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fakeNodeProvisioner lists its nodes for every workspace and records the nodes it deleted and retained.
+type fakeNodeProvisioner struct {
+	nodes    []*machine.ProvisionedNode
+	deleted  []string
+	retained []string
+}
+
+var _ machine.NodeProvisioner = &fakeNodeProvisioner{}
+
+func (p *fakeNodeProvisioner) Provision(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace) (string, error) {
+	return "", nil
+}
+
+func (p *fakeNodeProvisioner) Status(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace,
+	node *machine.ProvisionedNode) (machine.MachinePhase, string, error) {
+	return machine.MachinePhaseReady, "", nil
+}
+
+func (p *fakeNodeProvisioner) Delete(ctx context.Context, node *machine.ProvisionedNode) error {
+	p.deleted = append(p.deleted, node.Name)
+	return nil
+}
+
+func (p *fakeNodeProvisioner) Retain(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, node *machine.ProvisionedNode) error {
+	p.retained = append(p.retained, node.Name)
+	return nil
+}
+
+func (p *fakeNodeProvisioner) Reassign(ctx context.Context, from, to *kdmv1alpha1.Workspace, node *machine.ProvisionedNode) error {
+	return nil
+}
+
+func (p *fakeNodeProvisioner) ListForWorkspace(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace) ([]*machine.ProvisionedNode, error) {
+	return p.nodes, nil
+}
+
+func (p *fakeNodeProvisioner) WatchedObject() client.Object {
+	return nil
+}
+
+func TestGarbageCollectWorkspace(t *testing.T) {
+	testCases := []struct {
+		name         string
+		policy       kdmv1alpha1.ReclaimPolicy
+		wantDeleted  []string
+		wantRetained []string
+	}{
+		{name: "machines are deleted", policy: kdmv1alpha1.ReclaimPolicyDelete, wantDeleted: []string{"machine-a"}},
+		{name: "machines are retained", policy: kdmv1alpha1.ReclaimPolicyRetain, wantRetained: []string{"machine-a"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			now := metav1.Now()
+			wObj := &kdmv1alpha1.Workspace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "workspace",
+					Namespace:         "default",
+					UID:               "uid",
+					DeletionTimestamp: &now,
+					Finalizers:        []string{utils.WorkspaceFinalizer},
+				},
+				Resource: kdmv1alpha1.ResourceSpec{ReclaimPolicy: tc.policy},
+			}
+			objectMeta := func(name string) metav1.ObjectMeta {
+				return metav1.ObjectMeta{Name: name, Namespace: wObj.Namespace}
+			}
+			workloads := []client.Object{
+				&appsv1.Deployment{ObjectMeta: objectMeta(wObj.Name)},
+				&batchv1.Job{ObjectMeta: objectMeta(training.JobName(wObj))},
+			}
+			services := []client.Object{
+				&corev1.Service{ObjectMeta: objectMeta(wObj.Name)},
+				&corev1.Service{ObjectMeta: objectMeta(inference.HeadlessServiceName(wObj))},
+			}
+			c := newTestReconciler(t, append(append([]client.Object{wObj}, workloads...), services...)...)
+			provisioner := &fakeNodeProvisioner{nodes: []*machine.ProvisionedNode{
+				{Name: "machine-a", NodeName: "node-a"},
+				{Name: "machine-b", NodeName: "node-b", Deleting: true},
+			}}
+			c.NodeProvisioner = provisioner
+			ctx := context.Background()
+
+			// The workloads are deleted first, the workspace waits for them to be gone.
+			wObj = getWorkspace(t, c, wObj)
+			result, err := c.garbageCollectWorkspace(ctx, wObj)
+			if err != nil {
+				t.Fatalf("garbageCollectWorkspace() error = %v", err)
+			}
+			if result.RequeueAfter != workloadDeletionRequeueInterval {
+				t.Errorf("garbageCollectWorkspace() = %+v, want a requeue after %v", result, workloadDeletionRequeueInterval)
+			}
+			for _, workloadObj := range workloads {
+				if err := c.Get(ctx, client.ObjectKeyFromObject(workloadObj), workloadObj); !apierrors.IsNotFound(err) {
+					t.Errorf("workload %s has not been deleted: %v", workloadObj.GetName(), err)
+				}
+			}
+			for _, serviceObj := range services {
+				if err := c.Get(ctx, client.ObjectKeyFromObject(serviceObj), serviceObj); err != nil {
+					t.Errorf("service %s has been deleted before the workloads are gone: %v", serviceObj.GetName(), err)
+				}
+			}
+			if len(provisioner.deleted) != 0 || len(provisioner.retained) != 0 {
+				t.Errorf("machines have been reclaimed before the workloads are gone")
+			}
+			got := getWorkspace(t, c, wObj)
+			if meta.IsStatusConditionTrue(got.Status.Conditions, string(kdmv1alpha1.WorkspaceConditionTypeInferenceDeleted)) {
+				t.Errorf("condition %s is true before the workloads are gone", kdmv1alpha1.WorkspaceConditionTypeInferenceDeleted)
+			}
+
+			// Once the workloads are gone, the services and the machines are released and the finalizer is removed.
+			result, err = c.garbageCollectWorkspace(ctx, got)
+			if err != nil {
+				t.Fatalf("garbageCollectWorkspace() error = %v", err)
+			}
+			if result.RequeueAfter != 0 {
+				t.Errorf("garbageCollectWorkspace() = %+v, want no requeue", result)
+			}
+			for _, serviceObj := range services {
+				if err := c.Get(ctx, client.ObjectKeyFromObject(serviceObj), serviceObj); !apierrors.IsNotFound(err) {
+					t.Errorf("service %s has not been deleted: %v", serviceObj.GetName(), err)
+				}
+			}
+			if !reflect.DeepEqual(provisioner.deleted, tc.wantDeleted) {
+				t.Errorf("deleted machines = %v, want %v", provisioner.deleted, tc.wantDeleted)
+			}
+			if !reflect.DeepEqual(provisioner.retained, tc.wantRetained) {
+				t.Errorf("retained machines = %v, want %v", provisioner.retained, tc.wantRetained)
+			}
+			if err := c.Get(ctx, client.ObjectKeyFromObject(wObj), &kdmv1alpha1.Workspace{}); !apierrors.IsNotFound(err) {
+				t.Errorf("workspace has not been deleted once its finalizer is removed: %v", err)
+			}
+		})
+	}
+}
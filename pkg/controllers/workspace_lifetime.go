@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/inference"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// idleCheckInterval is how often the request counter of a workspace with an idle timeout is scraped.
+var idleCheckInterval = time.Minute
+
+// requestMetricsClient scrapes the request counters of the workspaces, within the deadline of inference.GetRequestCount.
+var requestMetricsClient = &http.Client{}
+
+// applyLifetime deletes the workspace once its TTL has expired, and suspends or deletes it once it has been idle for
+// its idle timeout. It returns true if the workspace has been deleted.
+func (c *WorkspaceReconciler) applyLifetime(ctx context.Context, wObj *kdmv1alpha1.Workspace) (bool, error) {
+	if expiry, found := ttlExpiry(wObj); found && !time.Now().Before(expiry) {
+		klog.InfoS("deleting workspace whose TTL has expired", "workspace", klog.KObj(wObj),
+			"firstReadyTime", wObj.Status.FirstReadyTime, "ttlSecondsAfterReady", *wObj.Spec.TTLSecondsAfterReady)
+		return true, client.IgnoreNotFound(c.Delete(ctx, wObj, &client.DeleteOptions{}))
+	}
+	return c.applyIdleTimeout(ctx, wObj)
+}
+
+// ttlExpiry returns when the TTL of the workspace expires, if it has one and has been ready.
+func ttlExpiry(wObj *kdmv1alpha1.Workspace) (time.Time, bool) {
+	if wObj.Spec.TTLSecondsAfterReady == nil || wObj.Status.FirstReadyTime == nil {
+		return time.Time{}, false
+	}
+	return wObj.Status.FirstReadyTime.Add(time.Duration(*wObj.Spec.TTLSecondsAfterReady) * time.Second), true
+}
+
+// applyIdleTimeout scrapes the request counter of the workspace and records when it last changed. Once it has not
+// changed for the idle timeout, the workspace is suspended or deleted according to its idle action. The idle time
+// only counts while the inference serves: it restarts when the workspace is ready again, e.g., after a resume.
+func (c *WorkspaceReconciler) applyIdleTimeout(ctx context.Context, wObj *kdmv1alpha1.Workspace) (bool, error) {
+	if wObj.Spec.IdleTimeout == nil || wObj.Spec.Suspend {
+		return false, nil
+	}
+	if wObj.Status.ReadyReplicas == 0 {
+		if wObj.Status.LastRequestTime == nil {
+			return false, nil
+		}
+		wObj.Status.LastRequestTime, wObj.Status.RequestCount = nil, 0
+		return false, c.updateWorkspaceStatus(ctx, wObj)
+	}
+
+	count, err := inference.GetRequestCount(ctx, wObj, c.Client, requestMetricsClient)
+	if err != nil {
+		// A workspace is only stopped when it is known to be idle.
+		klog.ErrorS(err, "skipping idle detection, failed to get the request count", "workspace", klog.KObj(wObj))
+		return false, nil
+	}
+	now := time.Now()
+	if wObj.Status.LastRequestTime == nil || count != wObj.Status.RequestCount {
+		wObj.Status.LastRequestTime, wObj.Status.RequestCount = &metav1.Time{Time: now}, count
+		return false, c.updateWorkspaceStatus(ctx, wObj)
+	}
+	if idle := now.Sub(wObj.Status.LastRequestTime.Time); idle < wObj.Spec.IdleTimeout.Duration {
+		return false, nil
+	}
+
+	klog.InfoS("workspace is idle", "workspace", klog.KObj(wObj), "lastRequestTime", wObj.Status.LastRequestTime,
+		"idleTimeout", wObj.Spec.IdleTimeout.Duration, "idleAction", wObj.Spec.IdleAction)
+	if wObj.Spec.IdleAction == kdmv1alpha1.IdleActionDelete {
+		return true, client.IgnoreNotFound(c.Delete(ctx, wObj, &client.DeleteOptions{}))
+	}
+	wObj.Spec.Suspend = true
+	return false, c.Update(ctx, wObj, &client.UpdateOptions{})
+}
+
+// requeueForTimers returns the result requeueing the workspace at the latest at its next scheduled action, when its
+// TTL expires, and when its request counter has to be scraped again.
+func requeueForTimers(wObj *kdmv1alpha1.Workspace, result reconcile.Result) reconcile.Result {
+	if !wObj.DeletionTimestamp.IsZero() {
+		return result
+	}
+	if next := wObj.Status.NextScheduledAction; next != nil {
+		result = requeueBefore(result, next.Time.Time)
+	}
+	if expiry, found := ttlExpiry(wObj); found {
+		result = requeueBefore(result, expiry)
+	}
+	if wObj.Spec.IdleTimeout != nil && !wObj.Spec.Suspend && wObj.Status.ReadyReplicas != 0 {
+		result = requeueBefore(result, time.Now().Add(idleCheckInterval))
+	}
+	return result
+}
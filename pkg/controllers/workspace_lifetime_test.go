@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/k8sresources"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestApplyIdleTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = fmt.Fprintf(w, "# TYPE http_requests_total counter\nhttp_requests_total 10\n")
+	}))
+	defer server.Close()
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to get the port of the metrics server: %v", err)
+	}
+	metricsPort, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatalf("failed to parse the port of the metrics server: %v", err)
+	}
+
+	const idleTimeout = time.Hour
+	lastRequest := func(ago time.Duration) *metav1.Time {
+		return &metav1.Time{Time: time.Now().Add(-ago)}
+	}
+	testCases := []struct {
+		name                string
+		idleAction          kdmv1alpha1.IdleAction
+		readyReplicas       int32
+		metricsPath         string
+		requestCount        int64
+		lastRequestTime     *metav1.Time
+		wantDeleted         bool
+		wantSuspended       bool
+		wantRequestCount    int64
+		wantLastRequestTime bool
+		wantLastRequestNow  bool
+	}{
+		{name: "first scrape records the request count", readyReplicas: 1,
+			wantRequestCount: 10, wantLastRequestTime: true, wantLastRequestNow: true},
+		{name: "new requests restart the idle time", readyReplicas: 1, requestCount: 4,
+			lastRequestTime:  lastRequest(2 * idleTimeout),
+			wantRequestCount: 10, wantLastRequestTime: true, wantLastRequestNow: true},
+		{name: "idle for less than the timeout", readyReplicas: 1, requestCount: 10,
+			lastRequestTime:  lastRequest(idleTimeout / 2),
+			wantRequestCount: 10, wantLastRequestTime: true},
+		{name: "idle workspace is suspended", idleAction: kdmv1alpha1.IdleActionSuspend, readyReplicas: 1,
+			requestCount: 10, lastRequestTime: lastRequest(2 * idleTimeout),
+			wantSuspended: true, wantRequestCount: 10, wantLastRequestTime: true},
+		{name: "idle workspace is deleted", idleAction: kdmv1alpha1.IdleActionDelete, readyReplicas: 1,
+			requestCount: 10, lastRequestTime: lastRequest(2 * idleTimeout), wantDeleted: true},
+		{name: "workspace is not stopped when the request count is unknown", readyReplicas: 1,
+			metricsPath: "/missing", requestCount: 10, lastRequestTime: lastRequest(2 * idleTimeout),
+			wantRequestCount: 10, wantLastRequestTime: true},
+		{name: "idle time restarts once the workspace is ready again", requestCount: 10,
+			lastRequestTime: lastRequest(2 * idleTimeout)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			wObj := &kdmv1alpha1.Workspace{
+				ObjectMeta: metav1.ObjectMeta{Name: "workspace", Namespace: "default", UID: "uid"},
+				Resource: kdmv1alpha1.ResourceSpec{
+					LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "workspace"}},
+				},
+				Spec: kdmv1alpha1.WorkspaceSpec{
+					IdleTimeout: &metav1.Duration{Duration: idleTimeout},
+					IdleAction:  tc.idleAction,
+					RequestMetrics: &kdmv1alpha1.RequestMetricsSpec{
+						Port: int32(metricsPort),
+						Path: tc.metricsPath,
+					},
+				},
+				Status: kdmv1alpha1.WorkspaceStatus{
+					ReadyReplicas:   tc.readyReplicas,
+					RequestCount:    tc.requestCount,
+					LastRequestTime: tc.lastRequestTime,
+				},
+			}
+			podObj := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "workspace-pod",
+					Namespace: "default",
+					Labels:    k8sresources.GenerateInferenceSelector(wObj),
+				},
+				Status: corev1.PodStatus{
+					PodIP:      "127.0.0.1",
+					Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+				},
+			}
+			c := newTestReconciler(t, wObj, podObj)
+			wObj = getWorkspace(t, c, wObj)
+
+			deleted, err := c.applyIdleTimeout(context.Background(), wObj)
+			if err != nil {
+				t.Fatalf("applyIdleTimeout() error = %v", err)
+			}
+			if deleted != tc.wantDeleted {
+				t.Errorf("applyIdleTimeout() = %t, want %t", deleted, tc.wantDeleted)
+			}
+			got := &kdmv1alpha1.Workspace{}
+			err = c.Get(context.Background(), client.ObjectKeyFromObject(wObj), got)
+			if tc.wantDeleted {
+				if !apierrors.IsNotFound(err) {
+					t.Errorf("workspace has not been deleted: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to get workspace: %v", err)
+			}
+			if got.Spec.Suspend != tc.wantSuspended {
+				t.Errorf("spec.suspend = %t, want %t", got.Spec.Suspend, tc.wantSuspended)
+			}
+			if got.Status.RequestCount != tc.wantRequestCount {
+				t.Errorf("status.requestCount = %d, want %d", got.Status.RequestCount, tc.wantRequestCount)
+			}
+			if (got.Status.LastRequestTime != nil) != tc.wantLastRequestTime {
+				t.Fatalf("status.lastRequestTime = %v, want set %t", got.Status.LastRequestTime, tc.wantLastRequestTime)
+			}
+			if tc.wantLastRequestNow && time.Since(got.Status.LastRequestTime.Time) > time.Minute {
+				t.Errorf("status.lastRequestTime = %v, want now", got.Status.LastRequestTime)
+			}
+			if !tc.wantLastRequestNow && tc.wantLastRequestTime &&
+				!got.Status.LastRequestTime.Time.Equal(tc.lastRequestTime.Time.Truncate(time.Second)) {
+				t.Errorf("status.lastRequestTime = %v, want %v", got.Status.LastRequestTime, tc.lastRequestTime)
+			}
+		})
+	}
+}
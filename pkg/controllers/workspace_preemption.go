@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/k8sresources"
+	"github.com/kdm/pkg/machine"
+	"github.com/kdm/pkg/utils"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// listPreemptionVictims returns the workspaces whose nodes the workspace can preempt, in the order they are
+// preempted: lowest priority first, then the most recently created. Only the nodes of single node inference
+// workspaces are preempted, training jobs and sharded models would have to restart on all their nodes.
+func (c *WorkspaceReconciler) listPreemptionVictims(ctx context.Context, wObj *kdmv1alpha1.Workspace) ([]*kdmv1alpha1.Workspace, error) {
+	workspaceList := &kdmv1alpha1.WorkspaceList{}
+	if err := c.List(ctx, workspaceList); err != nil {
+		return nil, err
+	}
+
+	priority := wObj.Status.Priority
+	var victims []*kdmv1alpha1.Workspace
+	for i := range workspaceList.Items {
+		item := &workspaceList.Items[i]
+		if item.UID == wObj.UID || !item.DeletionTimestamp.IsZero() || item.Status.Priority >= priority ||
+			len(item.Status.WorkerNodes) == 0 || !hasInference(item) || item.Training.Preset.Name != "" {
+			continue
+		}
+		// Workspaces without node labels have no nodes to reassign, and the victim would select the reassigned nodes
+		// again.
+		if item.Resource.LabelSelector == nil || wObj.Resource.LabelSelector == nil ||
+			labels.SelectorFromSet(item.Resource.LabelSelector.MatchLabels).Matches(labels.Set(wObj.Resource.LabelSelector.MatchLabels)) {
+			continue
+		}
+		distributed, err := c.isDistributedInference(ctx, item)
+		if err != nil {
+			klog.ErrorS(err, "skipping preemption of workspace", "workspace", klog.KObj(item))
+			continue
+		}
+		if !distributed {
+			victims = append(victims, item)
+		}
+	}
+
+	sort.SliceStable(victims, func(i, j int) bool {
+		a, b := victims[i], victims[j]
+		if pa, pb := a.Status.Priority, b.Status.Priority; pa != pb {
+			return pa < pb
+		}
+		return b.CreationTimestamp.Before(&a.CreationTimestamp)
+	})
+	return victims, nil
+}
+
+// preemptWorkspaces reclaims up to nodeCount ready nodes of an accepted instance type from workspaces with a lower
+// priority when the workspace cannot get new machines: all its instance types failed to launch, or no node matches
+// it without node provisioning. Workspaces waiting for the machines being provisioned do not preempt. The nodes are reassigned to the workspace, the inference
+// pods of the victims on them are deleted and their deployments are scaled down. The victims are marked preempted
+// and get new nodes once capacity frees up.
+func (c *WorkspaceReconciler) preemptWorkspaces(ctx context.Context, wObj *kdmv1alpha1.Workspace, nodeCount int) error {
+	victims, err := c.listPreemptionVictims(ctx, wObj)
+	if err != nil {
+		return err
+	}
+
+	instanceTypes := utils.GetInstanceTypes(wObj)
+	for _, victim := range victims {
+		if nodeCount <= 0 {
+			break
+		}
+		var preemptedNodes []string
+		for _, nodeName := range victim.Status.WorkerNodes {
+			if len(preemptedNodes) == nodeCount {
+				break
+			}
+			nodeObj, err := k8sresources.GetNode(ctx, nodeName, c.Client)
+			if err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				return err
+			}
+			if !isNodeReady(nodeObj) || !lo.Contains(instanceTypes, nodeObj.Labels[corev1.LabelInstanceTypeStable]) {
+				continue
+			}
+			if err := c.preemptNode(ctx, wObj, victim, nodeName); err != nil {
+				if machine.IsReassignNotSupported(err) {
+					klog.InfoS("nodes cannot be preempted with the node provisioner", "workspace", klog.KObj(wObj))
+					return nil
+				}
+				return err
+			}
+			preemptedNodes = append(preemptedNodes, nodeName)
+		}
+		if len(preemptedNodes) == 0 {
+			continue
+		}
+		if err := c.markWorkspacePreempted(ctx, victim, wObj, preemptedNodes); err != nil {
+			return err
+		}
+		nodeCount -= len(preemptedNodes)
+		wObj.Status.WorkerNodes = append(wObj.Status.WorkerNodes, preemptedNodes...)
+		if err := c.updateWorkspaceStatus(ctx, wObj); err != nil {
+			klog.ErrorS(err, "failed to update workspace status", "workspace", wObj)
+			return err
+		}
+	}
+	return nil
+}
+
+// preemptNode reassigns the node of the victim, and the machine it was provisioned with, to the workspace and
+// deletes the pods of the victim running on it.
+func (c *WorkspaceReconciler) preemptNode(ctx context.Context, wObj, victim *kdmv1alpha1.Workspace, nodeName string) error {
+	klog.InfoS("preemptNode", "workspace", klog.KObj(wObj), "victim", klog.KObj(victim), "node", nodeName)
+	nodes, err := c.NodeProvisioner.ListForWorkspace(ctx, victim)
+	if err != nil {
+		return err
+	}
+	if node, found := lo.Find(nodes, func(node *machine.ProvisionedNode) bool {
+		return node.NodeName == nodeName && !node.Deleting
+	}); found {
+		if err := c.NodeProvisioner.Reassign(ctx, victim, wObj, node); err != nil {
+			return err
+		}
+	}
+	if err := machine.ReassignNode(ctx, nodeName, victim, wObj, c.Client); err != nil {
+		return err
+	}
+
+	podList := &corev1.PodList{}
+	if err := c.List(ctx, podList, client.InNamespace(victim.Namespace), client.MatchingFields{"spec.nodeName": nodeName},
+		client.MatchingLabels{kdmv1alpha1.LabelWorkspaceName: victim.Name}); err != nil {
+		return err
+	}
+	for i := range podList.Items {
+		klog.InfoS("deleting preempted pod", "pod", klog.KObj(&podList.Items[i]), "node", nodeName)
+		if err := c.Delete(ctx, &podList.Items[i], &client.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// markWorkspacePreempted removes the preempted nodes from the victim, scales its inference deployment down so that
+// the deleted pods are not recreated, and sets the Preempted condition. The victim is reconciled through its
+// deployment and scaled up again once it has new nodes.
+func (c *WorkspaceReconciler) markWorkspacePreempted(ctx context.Context, victim, wObj *kdmv1alpha1.Workspace, preemptedNodes []string) error {
+	klog.InfoS("markWorkspacePreempted", "workspace", klog.KObj(victim), "preemptor", klog.KObj(wObj), "nodes", preemptedNodes)
+	victim.Status.WorkerNodes = lo.Without(victim.Status.WorkerNodes, preemptedNodes...)
+	if err := c.setStatusCondition(ctx, victim, kdmv1alpha1.WorkspaceConditionTypePreempted, metav1.ConditionTrue,
+		"workspacePreempted", fmt.Sprintf("nodes %v have been reassigned to workspace %s with a higher priority",
+			preemptedNodes, klog.KObj(wObj))); err != nil {
+		klog.ErrorS(err, "failed to update workspace status", "workspace", victim)
+		return err
+	}
+
+	deploymentObj, err := k8sresources.GetDeployment(ctx, victim.Name, victim.Namespace, c.Client)
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	replicas := lo.Max([]int{int(lo.FromPtr(deploymentObj.Spec.Replicas)) - len(preemptedNodes), 0})
+	return k8sresources.ScaleDeployment(ctx, victim.Name, victim.Namespace, replicas, c.Client)
+}
+
+// clearPreemption marks the workspace no longer preempted once it has all its nodes again.
+func (c *WorkspaceReconciler) clearPreemption(ctx context.Context, wObj *kdmv1alpha1.Workspace) error {
+	if !meta.IsStatusConditionTrue(wObj.Status.Conditions, string(kdmv1alpha1.WorkspaceConditionTypePreempted)) {
+		return nil
+	}
+	return c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypePreempted, metav1.ConditionFalse,
+		"workspaceRecovered", "workspace has got nodes to replace the preempted ones")
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// newInferenceWorkspace returns a template inference workspace with the priority, created createdAgo, on a node
+// selected by the pool label.
+func newInferenceWorkspace(name string, priority int32, pool string, createdAgo time.Duration) *kdmv1alpha1.Workspace {
+	return &kdmv1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			UID:               types.UID(name),
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-createdAgo)),
+		},
+		Resource: kdmv1alpha1.ResourceSpec{
+			LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"pool": pool}},
+		},
+		Inference: kdmv1alpha1.InferenceSpec{Template: &corev1.PodTemplateSpec{}},
+		Status: kdmv1alpha1.WorkspaceStatus{
+			Priority:    priority,
+			WorkerNodes: []string{name + "-node"},
+		},
+	}
+}
+
+func TestListPreemptionVictims(t *testing.T) {
+	withoutNodes := newInferenceWorkspace("without-nodes", 0, "without-nodes", time.Hour)
+	withoutNodes.Status.WorkerNodes = nil
+	training := newInferenceWorkspace("training", 0, "training", time.Hour)
+	training.Training.Preset.Name = "falcon-7b"
+	withoutInference := newInferenceWorkspace("without-inference", 0, "without-inference", time.Hour)
+	withoutInference.Inference.Template = nil
+	withoutSelector := newInferenceWorkspace("without-selector", 0, "", time.Hour)
+	withoutSelector.Resource.LabelSelector = nil
+
+	testCases := []struct {
+		name       string
+		workspace  *kdmv1alpha1.Workspace
+		workspaces []*kdmv1alpha1.Workspace
+		want       []string
+	}{
+		{
+			name:      "lowest priority first, then the most recently created",
+			workspace: newInferenceWorkspace("workspace", 100, "workspace", 0),
+			workspaces: []*kdmv1alpha1.Workspace{
+				newInferenceWorkspace("old", 0, "old", 2*time.Hour),
+				newInferenceWorkspace("medium", 10, "medium", time.Hour),
+				newInferenceWorkspace("new", 0, "new", time.Hour),
+				newInferenceWorkspace("lowest", -10, "lowest", time.Minute),
+			},
+			want: []string{"lowest", "new", "old", "medium"},
+		},
+		{
+			name:      "same or higher priority is not preempted",
+			workspace: newInferenceWorkspace("workspace", 10, "workspace", 0),
+			workspaces: []*kdmv1alpha1.Workspace{
+				newInferenceWorkspace("same", 10, "same", time.Hour),
+				newInferenceWorkspace("higher", 100, "higher", time.Hour),
+				newInferenceWorkspace("lower", 0, "lower", time.Hour),
+			},
+			want: []string{"lower"},
+		},
+		{
+			name:      "only single node inference workspaces with nodes are preempted",
+			workspace: newInferenceWorkspace("workspace", 100, "workspace", 0),
+			workspaces: []*kdmv1alpha1.Workspace{
+				withoutNodes,
+				training,
+				withoutInference,
+				newInferenceWorkspace("victim", 0, "victim", time.Hour),
+			},
+			want: []string{"victim"},
+		},
+		{
+			name:      "workspace that would select the reassigned nodes again is not preempted",
+			workspace: newInferenceWorkspace("workspace", 100, "shared", 0),
+			workspaces: []*kdmv1alpha1.Workspace{
+				newInferenceWorkspace("shared", 0, "shared", time.Hour),
+				withoutSelector,
+				newInferenceWorkspace("victim", 0, "victim", time.Hour),
+			},
+			want: []string{"victim"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			objs := []client.Object{tc.workspace}
+			for _, wObj := range tc.workspaces {
+				objs = append(objs, wObj)
+			}
+			c := newTestReconciler(t, objs...)
+
+			victims, err := c.listPreemptionVictims(context.Background(), getWorkspace(t, c, tc.workspace))
+			if err != nil {
+				t.Fatalf("listPreemptionVictims() error = %v", err)
+			}
+			got := lo.Map(victims, func(wObj *kdmv1alpha1.Workspace, _ int) string {
+				return wObj.Name
+			})
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("listPreemptionVictims() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// queueReasonCapacity queues a workspace while the machines being provisioned in the cluster are at the limit.
+	queueReasonCapacity = "workspaceQueuedForCapacity"
+	// queueReasonQuota queues a workspace while it does not fit in the GPU quotas of its namespace.
+	queueReasonQuota = "workspaceQueuedForQuota"
+	// queueReasonInstanceTypesUnavailable queues a workspace while all its instance types failed to launch recently.
+	queueReasonInstanceTypesUnavailable = "workspaceQueuedForInstanceTypes"
+)
+
+// isQueued returns true if the workspace waits for GPU capacity.
+func isQueued(wObj *kdmv1alpha1.Workspace) bool {
+	return meta.IsStatusConditionTrue(wObj.Status.Conditions, string(kdmv1alpha1.WorkspaceConditionTypeQueued))
+}
+
+// queuedTime returns when the workspace was queued. Workspaces that are not queued are queued now.
+func queuedTime(wObj *kdmv1alpha1.Workspace, now time.Time) time.Time {
+	condition := meta.FindStatusCondition(wObj.Status.Conditions, string(kdmv1alpha1.WorkspaceConditionTypeQueued))
+	if condition == nil || condition.Status != metav1.ConditionTrue {
+		return now
+	}
+	return condition.LastTransitionTime.Time
+}
+
+// queueOrder sorts the workspaces in the order they are admitted: by priority, then first in first out.
+func queueOrder(workspaces []*kdmv1alpha1.Workspace) {
+	now := time.Now()
+	sort.SliceStable(workspaces, func(i, j int) bool {
+		a, b := workspaces[i], workspaces[j]
+		if pa, pb := a.Status.Priority, b.Status.Priority; pa != pb {
+			return pa > pb
+		}
+		if ta, tb := queuedTime(a, now), queuedTime(b, now); !ta.Equal(tb) {
+			return ta.Before(tb)
+		}
+		return client.ObjectKeyFromObject(a).String() < client.ObjectKeyFromObject(b).String()
+	})
+}
+
+// listQueue returns the queued workspaces of the cluster and the workspace in the order they are admitted, and the
+// number of machines being provisioned for the other workspaces.
+func (c *WorkspaceReconciler) listQueue(ctx context.Context, wObj *kdmv1alpha1.Workspace) ([]*kdmv1alpha1.Workspace, int, error) {
+	workspaceList := &kdmv1alpha1.WorkspaceList{}
+	if err := c.List(ctx, workspaceList); err != nil {
+		return nil, 0, err
+	}
+
+	queue := []*kdmv1alpha1.Workspace{wObj}
+	provisioningMachines := 0
+	for i := range workspaceList.Items {
+		item := &workspaceList.Items[i]
+		if item.UID == wObj.UID {
+			continue
+		}
+		provisioningMachines += len(item.Status.ProvisioningMachines)
+		if isQueued(item) && item.DeletionTimestamp.IsZero() {
+			queue = append(queue, item)
+		}
+	}
+	queueOrder(queue)
+	return queue, provisioningMachines, nil
+}
+
+// admitWorkspace returns true if the workspace can create machines for nodeCount nodes. When the machines being
+// provisioned in the cluster would exceed MaxProvisioningMachines, the workspace is queued and admitted once it is
+// the first workspace of the queue waiting for capacity and enough machines have been provisioned.
+func (c *WorkspaceReconciler) admitWorkspace(ctx context.Context, wObj *kdmv1alpha1.Workspace, nodeCount int) (bool, error) {
+	if c.MaxProvisioningMachines <= 0 {
+		return true, c.dequeueWorkspace(ctx, wObj)
+	}
+
+	queue, provisioningMachines, err := c.listQueue(ctx, wObj)
+	if err != nil {
+		return false, err
+	}
+	provisioningMachines += len(wObj.Status.ProvisioningMachines)
+	// Workspaces waiting for their instance types do not hold back the ones behind them.
+	ahead := lo.CountBy(queue[:lo.IndexOf(queue, wObj)], func(item *kdmv1alpha1.Workspace) bool {
+		condition := meta.FindStatusCondition(item.Status.Conditions, string(kdmv1alpha1.WorkspaceConditionTypeQueued))
+		return condition.Reason == queueReasonCapacity
+	})
+	// A workspace needing more machines than the limit is admitted once no other machine is being provisioned.
+	if ahead == 0 && (provisioningMachines+nodeCount <= c.MaxProvisioningMachines || provisioningMachines == 0) {
+		return true, c.dequeueWorkspace(ctx, wObj)
+	}
+
+	klog.InfoS("workspace is waiting for capacity", "workspace", klog.KObj(wObj), "provisioningMachines", provisioningMachines,
+		"maxProvisioningMachines", c.MaxProvisioningMachines, "workspacesAhead", ahead)
+	return false, c.enqueueWorkspace(ctx, wObj, queueReasonCapacity,
+		fmt.Sprintf("%d machines are being provisioned in the cluster, at most %d can be, and %d workspaces are ahead",
+			provisioningMachines, c.MaxProvisioningMachines, ahead))
+}
+
+// enqueueWorkspace marks the workspace queued and records its position in the queue.
+func (c *WorkspaceReconciler) enqueueWorkspace(ctx context.Context, wObj *kdmv1alpha1.Workspace, reason, message string) error {
+	queue, _, err := c.listQueue(ctx, wObj)
+	if err != nil {
+		return err
+	}
+	position := lo.IndexOf(queue, wObj) + 1
+	klog.InfoS("enqueueWorkspace", "workspace", klog.KObj(wObj), "position", position, "reason", reason)
+	wObj.Status.QueuePosition = int32(position)
+	return c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeQueued, metav1.ConditionTrue,
+		reason, fmt.Sprintf("workspace is at position %d in the queue: %s", position, message))
+}
+
+// dequeueWorkspace marks the workspace admitted if it was queued.
+func (c *WorkspaceReconciler) dequeueWorkspace(ctx context.Context, wObj *kdmv1alpha1.Workspace) error {
+	if !isQueued(wObj) && wObj.Status.QueuePosition == 0 {
+		return nil
+	}
+	klog.InfoS("dequeueWorkspace", "workspace", klog.KObj(wObj))
+	wObj.Status.QueuePosition = 0
+	return c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeQueued, metav1.ConditionFalse,
+		"workspaceAdmitted", "workspace has been admitted")
+}
@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// newQueuedWorkspace returns a workspace with the priority, queued for the reason since queuedAgo. A zero queuedAgo
+// means the workspace is not queued.
+func newQueuedWorkspace(name string, priority int32, reason string, queuedAgo time.Duration) *kdmv1alpha1.Workspace {
+	wObj := &kdmv1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name)},
+		Status:     kdmv1alpha1.WorkspaceStatus{Priority: priority},
+	}
+	if queuedAgo != 0 {
+		wObj.Status.Conditions = []metav1.Condition{{
+			Type:               string(kdmv1alpha1.WorkspaceConditionTypeQueued),
+			Status:             metav1.ConditionTrue,
+			Reason:             reason,
+			LastTransitionTime: metav1.NewTime(time.Now().Add(-queuedAgo)),
+		}}
+	}
+	return wObj
+}
+
+func TestQueueOrder(t *testing.T) {
+	testCases := []struct {
+		name       string
+		workspaces []*kdmv1alpha1.Workspace
+		want       []string
+	}{
+		{
+			name: "first in first out",
+			workspaces: []*kdmv1alpha1.Workspace{
+				newQueuedWorkspace("b", 0, queueReasonCapacity, time.Minute),
+				newQueuedWorkspace("a", 0, queueReasonCapacity, time.Hour),
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name: "higher priority first",
+			workspaces: []*kdmv1alpha1.Workspace{
+				newQueuedWorkspace("low", 0, queueReasonCapacity, time.Hour),
+				newQueuedWorkspace("high", 100, queueReasonCapacity, time.Minute),
+				newQueuedWorkspace("negative", -10, queueReasonCapacity, 2*time.Hour),
+			},
+			want: []string{"high", "low", "negative"},
+		},
+		{
+			name: "workspace that is not queued yet is last of its priority",
+			workspaces: []*kdmv1alpha1.Workspace{
+				newQueuedWorkspace("new", 10, "", 0),
+				newQueuedWorkspace("queued", 10, queueReasonCapacity, time.Minute),
+				newQueuedWorkspace("low", 0, queueReasonCapacity, time.Hour),
+			},
+			want: []string{"queued", "new", "low"},
+		},
+		{
+			name: "same priority and queued time by name",
+			workspaces: []*kdmv1alpha1.Workspace{
+				newQueuedWorkspace("b", 0, "", 0),
+				newQueuedWorkspace("a", 0, "", 0),
+			},
+			want: []string{"a", "b"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			queueOrder(tc.workspaces)
+			got := lo.Map(tc.workspaces, func(wObj *kdmv1alpha1.Workspace, _ int) string {
+				return wObj.Name
+			})
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("queueOrder() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAdmitWorkspace(t *testing.T) {
+	provisioning := func(wObj *kdmv1alpha1.Workspace, machines ...string) *kdmv1alpha1.Workspace {
+		wObj.Status.ProvisioningMachines = machines
+		return wObj
+	}
+	testCases := []struct {
+		name                    string
+		workspace               *kdmv1alpha1.Workspace
+		others                  []*kdmv1alpha1.Workspace
+		maxProvisioningMachines int
+		nodeCount               int
+		wantAdmitted            bool
+		wantPosition            int32
+	}{
+		{
+			name:      "no limit",
+			workspace: newQueuedWorkspace("workspace", 0, "", 0),
+			others: []*kdmv1alpha1.Workspace{
+				provisioning(newQueuedWorkspace("other", 0, "", 0), "m1", "m2"),
+			},
+			nodeCount:    1,
+			wantAdmitted: true,
+		},
+		{
+			name:      "within the limit",
+			workspace: newQueuedWorkspace("workspace", 0, "", 0),
+			others: []*kdmv1alpha1.Workspace{
+				provisioning(newQueuedWorkspace("other", 0, "", 0), "m1"),
+			},
+			maxProvisioningMachines: 2,
+			nodeCount:               1,
+			wantAdmitted:            true,
+		},
+		{
+			name:      "over the limit",
+			workspace: newQueuedWorkspace("workspace", 0, "", 0),
+			others: []*kdmv1alpha1.Workspace{
+				provisioning(newQueuedWorkspace("other", 0, "", 0), "m1", "m2"),
+			},
+			maxProvisioningMachines: 2,
+			nodeCount:               1,
+			wantAdmitted:            false,
+			wantPosition:            1,
+		},
+		{
+			name:                    "more nodes than the limit when nothing is provisioned",
+			workspace:               newQueuedWorkspace("workspace", 0, "", 0),
+			maxProvisioningMachines: 2,
+			nodeCount:               3,
+			wantAdmitted:            true,
+		},
+		{
+			name:      "behind a workspace queued for capacity",
+			workspace: newQueuedWorkspace("workspace", 0, "", 0),
+			others: []*kdmv1alpha1.Workspace{
+				newQueuedWorkspace("ahead", 0, queueReasonCapacity, time.Minute),
+			},
+			maxProvisioningMachines: 2,
+			nodeCount:               1,
+			wantAdmitted:            false,
+			wantPosition:            2,
+		},
+		{
+			name:      "ahead of a queued workspace with a lower priority",
+			workspace: newQueuedWorkspace("workspace", 10, "", 0),
+			others: []*kdmv1alpha1.Workspace{
+				newQueuedWorkspace("behind", 0, queueReasonCapacity, time.Minute),
+			},
+			maxProvisioningMachines: 2,
+			nodeCount:               1,
+			wantAdmitted:            true,
+		},
+		{
+			name:      "not held back by a workspace waiting for its instance types",
+			workspace: newQueuedWorkspace("workspace", 0, "", 0),
+			others: []*kdmv1alpha1.Workspace{
+				newQueuedWorkspace("ahead", 0, queueReasonInstanceTypesUnavailable, time.Minute),
+			},
+			maxProvisioningMachines: 2,
+			nodeCount:               1,
+			wantAdmitted:            true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			objs := []client.Object{tc.workspace}
+			for _, other := range tc.others {
+				objs = append(objs, other)
+			}
+			c := newTestReconciler(t, objs...)
+			c.MaxProvisioningMachines = tc.maxProvisioningMachines
+			wObj := getWorkspace(t, c, tc.workspace)
+
+			admitted, err := c.admitWorkspace(context.Background(), wObj, tc.nodeCount)
+			if err != nil {
+				t.Fatalf("admitWorkspace() error = %v", err)
+			}
+			if admitted != tc.wantAdmitted {
+				t.Errorf("admitWorkspace() = %t, want %t", admitted, tc.wantAdmitted)
+			}
+			got := getWorkspace(t, c, wObj)
+			if queued := meta.IsStatusConditionTrue(got.Status.Conditions, string(kdmv1alpha1.WorkspaceConditionTypeQueued)); queued == tc.wantAdmitted {
+				t.Errorf("queued = %t, want %t", queued, !tc.wantAdmitted)
+			}
+			if got.Status.QueuePosition != tc.wantPosition {
+				t.Errorf("status.queuePosition = %d, want %d", got.Status.QueuePosition, tc.wantPosition)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/schedule"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// scheduleRequeueDelay is added to the time of the next scheduled action, so that the workspace is not reconciled
+// just before it.
+var scheduleRequeueDelay = time.Second
+
+// applySchedule suspends or resumes the workspace at the last start or stop of its schedule that has not been applied
+// yet, and records the next one. Changes made by hand in between are kept until the next scheduled action.
+func (c *WorkspaceReconciler) applySchedule(ctx context.Context, wObj *kdmv1alpha1.Workspace) error {
+	if wObj.Spec.Schedule == nil {
+		if wObj.Status.LastScheduledAction == nil && wObj.Status.NextScheduledAction == nil {
+			return nil
+		}
+		wObj.Status.LastScheduledAction, wObj.Status.NextScheduledAction = nil, nil
+		return c.updateWorkspaceStatus(ctx, wObj)
+	}
+
+	sched, err := schedule.Parse(wObj.Spec.Schedule)
+	if err != nil {
+		return reconcile.TerminalError(err)
+	}
+	now := time.Now()
+	var after time.Time
+	if wObj.Status.LastScheduledAction != nil {
+		after = wObj.Status.LastScheduledAction.Time.Time
+	}
+	lastAction := wObj.Status.LastScheduledAction
+	if action := sched.Last(after, now); action != nil {
+		suspend := action.Action == kdmv1alpha1.ScheduledActionStop
+		if wObj.Spec.Suspend != suspend {
+			klog.InfoS("applySchedule", "workspace", klog.KObj(wObj), "action", action.Action, "time", action.Time)
+			wObj.Spec.Suspend = suspend
+			if err := c.Update(ctx, wObj, &client.UpdateOptions{}); err != nil {
+				return err
+			}
+		}
+		lastAction = action
+	}
+	nextAction := sched.Next(now)
+
+	if scheduledActionEqual(wObj.Status.LastScheduledAction, lastAction) && scheduledActionEqual(wObj.Status.NextScheduledAction, nextAction) {
+		return nil
+	}
+	wObj.Status.LastScheduledAction, wObj.Status.NextScheduledAction = lastAction, nextAction
+	return c.updateWorkspaceStatus(ctx, wObj)
+}
+
+// scheduledActionEqual compares scheduled actions by their instant, the time zone is lost when the status is stored.
+func scheduledActionEqual(a, b *kdmv1alpha1.ScheduledAction) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Action == b.Action && a.Time.Equal(&b.Time)
+}
+
+// requeueBefore returns the result requeueing the workspace at the latest just after the given time.
+func requeueBefore(result reconcile.Result, t time.Time) reconcile.Result {
+	untilNext := time.Until(t) + scheduleRequeueDelay
+	if untilNext < scheduleRequeueDelay {
+		untilNext = scheduleRequeueDelay
+	}
+	if result.RequeueAfter == 0 || untilNext < result.RequeueAfter {
+		result.RequeueAfter = untilNext
+	}
+	return result
+}
@@ -2,13 +2,17 @@ package controllers
 
 import (
 	"context"
+	"reflect"
 
 	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/k8sresources"
+	"github.com/kdm/pkg/utils"
 	"github.com/samber/lo"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
 )
@@ -50,3 +54,42 @@ func (c *WorkspaceReconciler) updateWorkspaceStatusWithNodeList(ctx context.Cont
 	wObj.Status.WorkerNodes = nodeNameList
 	return c.updateWorkspaceStatus(ctx, wObj)
 }
+
+// updateWorkspaceStatusWithReplicas updates workspace status with the desired and ready inference replicas.
+func (c *WorkspaceReconciler) updateWorkspaceStatusWithReplicas(ctx context.Context, wObj *kdmv1alpha1.Workspace, replicas, readyReplicas int32) error {
+	klog.InfoS("updateWorkspaceStatusWithReplicas", "workspace", klog.KObj(wObj), "replicas", replicas, "readyReplicas", readyReplicas)
+	selector := labels.SelectorFromSet(k8sresources.GenerateInferenceSelector(wObj)).String()
+	if wObj.Status.Replicas == replicas && wObj.Status.ReadyReplicas == readyReplicas && wObj.Status.Selector == selector {
+		return nil
+	}
+	wObj.Status.Replicas = replicas
+	wObj.Status.ReadyReplicas = readyReplicas
+	wObj.Status.Selector = selector
+	return c.updateWorkspaceStatus(ctx, wObj)
+}
+
+// updateWorkspaceStatusWithProvisioningMachines updates workspace status with the machines that are being provisioned.
+func (c *WorkspaceReconciler) updateWorkspaceStatusWithProvisioningMachines(ctx context.Context, wObj *kdmv1alpha1.Workspace, machineNames []string) error {
+	if reflect.DeepEqual(wObj.Status.ProvisioningMachines, machineNames) {
+		return nil
+	}
+	klog.InfoS("updateWorkspaceStatusWithProvisioningMachines", "workspace", klog.KObj(wObj), "machines", machineNames)
+	wObj.Status.ProvisioningMachines = machineNames
+	return c.updateWorkspaceStatus(ctx, wObj)
+}
+
+// updateWorkspaceStatusWithInstanceTypeFailure records that a machine of the instance type failed to launch now.
+// The failures of the instance types the workspace no longer accepts are dropped.
+func (c *WorkspaceReconciler) updateWorkspaceStatusWithInstanceTypeFailure(ctx context.Context, wObj *kdmv1alpha1.Workspace, instanceType, message string) error {
+	klog.InfoS("updateWorkspaceStatusWithInstanceTypeFailure", "workspace", klog.KObj(wObj), "instanceType", instanceType, "message", message)
+	instanceTypes := utils.GetInstanceTypes(wObj)
+	failures := lo.Filter(wObj.Status.InstanceTypeFailures, func(f kdmv1alpha1.InstanceTypeFailure, _ int) bool {
+		return f.InstanceType != instanceType && lo.Contains(instanceTypes, f.InstanceType)
+	})
+	wObj.Status.InstanceTypeFailures = append(failures, kdmv1alpha1.InstanceTypeFailure{
+		InstanceType:    instanceType,
+		Message:         message,
+		LastFailureTime: metav1.Now(),
+	})
+	return c.updateWorkspaceStatus(ctx, wObj)
+}
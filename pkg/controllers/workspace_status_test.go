@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestUpdateWorkspaceStatusWithInstanceTypeFailure(t *testing.T) {
+	testCases := []struct {
+		name         string
+		failures     []kdmv1alpha1.InstanceTypeFailure
+		instanceType string
+		want         []string
+	}{
+		{name: "first failure", instanceType: "A", want: []string{"A"}},
+		{name: "failure of the next instance type",
+			failures:     []kdmv1alpha1.InstanceTypeFailure{instanceTypeFailure("A", time.Hour)},
+			instanceType: "B", want: []string{"A", "B"}},
+		{name: "repeated failure replaces the previous one",
+			failures: []kdmv1alpha1.InstanceTypeFailure{
+				instanceTypeFailure("A", time.Hour),
+				instanceTypeFailure("B", time.Hour),
+			},
+			instanceType: "A", want: []string{"B", "A"}},
+		{name: "failures of instance types no longer accepted are dropped",
+			failures: []kdmv1alpha1.InstanceTypeFailure{
+				instanceTypeFailure("removed", time.Hour),
+				instanceTypeFailure("A", time.Hour),
+			},
+			instanceType: "B", want: []string{"A", "B"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			wObj := &kdmv1alpha1.Workspace{
+				ObjectMeta: metav1.ObjectMeta{Name: "workspace", Namespace: "default", UID: "uid"},
+				Resource: kdmv1alpha1.ResourceSpec{
+					InstanceType:          "A",
+					FallbackInstanceTypes: []string{"B"},
+				},
+				Status: kdmv1alpha1.WorkspaceStatus{InstanceTypeFailures: tc.failures},
+			}
+			c := newTestReconciler(t, wObj)
+			wObj = getWorkspace(t, c, wObj)
+
+			if err := c.updateWorkspaceStatusWithInstanceTypeFailure(context.Background(), wObj, tc.instanceType,
+				"insufficient capacity"); err != nil {
+				t.Fatalf("updateWorkspaceStatusWithInstanceTypeFailure() error = %v", err)
+			}
+			failures := getWorkspace(t, c, wObj).Status.InstanceTypeFailures
+			got := lo.Map(failures, func(f kdmv1alpha1.InstanceTypeFailure, _ int) string {
+				return f.InstanceType
+			})
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("status.instanceTypeFailures = %v, want %v", got, tc.want)
+			}
+			failure := failures[len(failures)-1]
+			if failure.Message != "insufficient capacity" || time.Since(failure.LastFailureTime.Time) > time.Minute {
+				t.Errorf("status.instanceTypeFailures records %+v, want the failure of now", failure)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"context"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/inference"
+	"github.com/kdm/pkg/k8sresources"
+	"github.com/kdm/pkg/training"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// isSuspended returns true if the workspace has been suspended by the controller.
+func isSuspended(wObj *kdmv1alpha1.Workspace) bool {
+	return meta.IsStatusConditionTrue(wObj.Status.Conditions, string(kdmv1alpha1.WorkspaceConditionTypeSuspended))
+}
+
+// suspendWorkspace stops the workloads of the workspace and releases its GPU machines once the workloads are gone.
+// The workspace, its service and its inference deployment are kept, so that resuming it only provisions machines and
+// scales the deployment up again. It returns false while the workloads are being deleted.
+func (c *WorkspaceReconciler) suspendWorkspace(ctx context.Context, wObj *kdmv1alpha1.Workspace) (bool, error) {
+	// Nothing is started again while the workspace stays suspended.
+	if isSuspended(wObj) {
+		return true, nil
+	}
+	klog.InfoS("suspendWorkspace", "workspace", klog.KObj(wObj))
+
+	stopped, err := c.suspendWorkloads(ctx, wObj)
+	if err != nil || !stopped {
+		return false, err
+	}
+	if err := c.releaseMachines(ctx, wObj); err != nil {
+		return false, err
+	}
+
+	wObj.Status.WorkerNodes = nil
+	wObj.Status.ProvisioningMachines = nil
+	wObj.Status.QueuePosition = 0
+	wObj.Status.Replicas = 0
+	wObj.Status.ReadyReplicas = 0
+	wObj.Status.RequestCount = 0
+	wObj.Status.LastRequestTime = nil
+	if meta.IsStatusConditionTrue(wObj.Status.Conditions, string(kdmv1alpha1.WorkspaceConditionTypeQueued)) {
+		meta.SetStatusCondition(&wObj.Status.Conditions, metav1.Condition{
+			Type:               string(kdmv1alpha1.WorkspaceConditionTypeQueued),
+			Status:             metav1.ConditionFalse,
+			Reason:             "workspaceSuspended",
+			ObservedGeneration: wObj.GetGeneration(),
+			Message:            "workspace has been suspended",
+		})
+	}
+	return true, c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeSuspended, metav1.ConditionTrue,
+		"workspaceSuspended", "workloads have been stopped and the machines have been released")
+}
+
+// suspendWorkloads scales the inference deployment to zero and deletes the statefulset of a distributed model and
+// the running training job. It returns true once they are gone.
+func (c *WorkspaceReconciler) suspendWorkloads(ctx context.Context, wObj *kdmv1alpha1.Workspace) (bool, error) {
+	if err := k8sresources.ScaleDeployment(ctx, wObj.Name, wObj.Namespace, 0, c.Client); err != nil && !apierrors.IsNotFound(err) {
+		return false, err
+	}
+	// All ranks of a distributed model start together, the statefulset is recreated on resume.
+	stsObj := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: wObj.Name, Namespace: wObj.Namespace}}
+	stsDeleted, err := inference.DeleteInference(ctx, stsObj, c.Client)
+	if err != nil {
+		return false, err
+	}
+	if wObj.Training.Preset.Name == "" {
+		return stsDeleted, nil
+	}
+	// The training pods are bound to the released nodes, a running job is restarted on the new nodes on resume.
+	// Finished jobs are kept so that the training is not run again.
+	jobObj, err := k8sresources.GetJob(ctx, training.JobName(wObj), wObj.Namespace, c.Client)
+	if err != nil {
+		return stsDeleted && apierrors.IsNotFound(err), client.IgnoreNotFound(err)
+	}
+	if status, _, _ := training.GetTrainingStatus(jobObj); status != metav1.ConditionUnknown {
+		return stsDeleted, nil
+	}
+	jobDeleted, err := inference.DeleteInference(ctx, jobObj, c.Client)
+	return stsDeleted && jobDeleted, err
+}
+
+// releaseMachines deletes all the machines provisioned for the workspace. Nodes that were not provisioned by kdm are
+// only released from the workspace status.
+func (c *WorkspaceReconciler) releaseMachines(ctx context.Context, wObj *kdmv1alpha1.Workspace) error {
+	nodes, err := c.NodeProvisioner.ListForWorkspace(ctx, wObj)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		if node.Deleting {
+			continue
+		}
+		if err := c.NodeProvisioner.Delete(ctx, node); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		klog.InfoS("released a machine of the suspended workspace", "workspace", klog.KObj(wObj), "machine", node.Name)
+	}
+	return nil
+}
+
+// resumeWorkspace marks a suspended workspace resumed. Its machines are provisioned again and its workloads are
+// scaled up or recreated by the rest of the reconciliation.
+func (c *WorkspaceReconciler) resumeWorkspace(ctx context.Context, wObj *kdmv1alpha1.Workspace) error {
+	if !isSuspended(wObj) {
+		return nil
+	}
+	klog.InfoS("resumeWorkspace", "workspace", klog.KObj(wObj))
+	return c.setStatusCondition(ctx, wObj, kdmv1alpha1.WorkspaceConditionTypeSuspended, metav1.ConditionFalse,
+		"workspaceResumed", "workspace has been resumed")
+}
@@ -0,0 +1,98 @@
+package inference
+
+import (
+	"context"
+	"fmt"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/sku"
+	"github.com/kdm/pkg/utils"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// InferenceRequirements returns the requirements a node must meet to run the preset on nodeCount nodes.
+// Distributed presets shard their GPUs evenly across the nodes.
+func InferenceRequirements(inferenceParam *PresetInferenceParam, nodeCount int) (sku.Requirements, error) {
+	req := sku.Requirements{GPUCount: inferenceParam.GPUCountPerReplica}
+	if inferenceParam.DistributedInference && nodeCount > 1 {
+		if req.GPUCount%nodeCount != 0 {
+			return sku.Requirements{}, fmt.Errorf("%d GPUs cannot be split evenly across %d nodes", req.GPUCount, nodeCount)
+		}
+		req.GPUCount /= nodeCount
+	}
+	if inferenceParam.MinGPUMemory != "" {
+		quantity, err := resource.ParseQuantity(inferenceParam.MinGPUMemory)
+		if err != nil {
+			return sku.Requirements{}, fmt.Errorf("invalid minimum GPU memory %q: %w", inferenceParam.MinGPUMemory, err)
+		}
+		req.MinGPUMemory = quantity
+	}
+	if inferenceParam.DiskStorageRequirement != "" {
+		quantity, err := resource.ParseQuantity(inferenceParam.DiskStorageRequirement)
+		if err != nil {
+			return sku.Requirements{}, fmt.Errorf("invalid disk storage requirement %q: %w", inferenceParam.DiskStorageRequirement, err)
+		}
+		req.Disk = quantity
+	}
+	return req, nil
+}
+
+// TrainingRequirements returns the requirements a node must meet to fine-tune the preset.
+// The GPU memory needed by the model is the one needed for inference.
+func TrainingRequirements(preset Preset, trainingParam *PresetTrainingParam) (sku.Requirements, error) {
+	req, err := InferenceRequirements(preset.GetInferenceParameters(), 1)
+	if err != nil {
+		return sku.Requirements{}, err
+	}
+	req.GPUCount = trainingParam.GPUCountPerNode
+	return req, nil
+}
+
+// WorkspaceRequirements returns the preset and the per node requirements of the workspace.
+// The training preset takes precedence, as the inference preset of a training workspace is only deployed afterwards.
+func WorkspaceRequirements(ctx context.Context, wObj *kdmv1alpha1.Workspace, kubeClient client.Client) (Preset, sku.Requirements, error) {
+	if wObj.Training.Preset.Name != "" {
+		preset, err := ResolvePreset(ctx, wObj.Training.Preset.Name, kubeClient)
+		if err != nil {
+			return nil, sku.Requirements{}, err
+		}
+		trainingParam, err := GetTrainingParameters(preset)
+		if err != nil {
+			return nil, sku.Requirements{}, err
+		}
+		req, err := TrainingRequirements(preset, trainingParam)
+		return preset, req, err
+	}
+	if wObj.Inference.Preset.Name == "" || wObj.Inference.Template != nil {
+		return nil, sku.Requirements{}, fmt.Errorf("workspace %s does not use a preset", klog.KObj(wObj))
+	}
+	preset, err := ResolvePreset(ctx, wObj.Inference.Preset.Name, kubeClient)
+	if err != nil {
+		return nil, sku.Requirements{}, err
+	}
+	req, err := InferenceRequirements(preset.GetInferenceParameters(), utils.GetNodeCount(wObj))
+	return preset, req, err
+}
+
+// SelectInstanceType returns the cheapest instance type of the catalog that fits the preset of the workspace.
+// The default instance type of the preset is returned when none fits.
+func SelectInstanceType(ctx context.Context, wObj *kdmv1alpha1.Workspace, catalog *sku.Catalog, kubeClient client.Client) (string, error) {
+	preset, req, err := WorkspaceRequirements(ctx, wObj, kubeClient)
+	if err != nil {
+		return "", err
+	}
+	instanceType, err := catalog.Select(req)
+	if err != nil {
+		defaultInstanceType := preset.GetInferenceParameters().DefaultInstanceType
+		if defaultInstanceType == "" {
+			return "", err
+		}
+		klog.InfoS("no instance type of the catalog fits the preset, using its default instance type",
+			"workspace", klog.KObj(wObj), "preset", preset.Name(), "instanceType", defaultInstanceType, "err", err)
+		return defaultInstanceType, nil
+	}
+	klog.InfoS("SelectInstanceType", "workspace", klog.KObj(wObj), "preset", preset.Name(), "instanceType", instanceType.Name)
+	return instanceType.Name, nil
+}
@@ -0,0 +1,152 @@
+package inference
+
+import (
+	"context"
+	"fmt"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// modelPreset adapts a ModelPreset object to the Preset interface.
+type modelPreset struct {
+	name          kdmv1alpha1.PresetModelName
+	param         PresetInferenceParam
+	trainingParam *PresetTrainingParam
+}
+
+// NewModelPreset returns the Preset described by a ModelPreset object.
+func NewModelPreset(presetObj *kdmv1alpha1.ModelPreset) Preset {
+	spec := presetObj.Spec
+	param := PresetInferenceParam{
+		Image:                spec.Image,
+		BaseCommand:          spec.BaseCommand,
+		InferenceFile:        spec.InferenceFile,
+		TorchRunParams:       spec.TorchRunParams,
+		ModelRunParams:       spec.ModelRunParams,
+		GPUCountPerReplica:   spec.GPUCountPerReplica,
+		DistributedInference: spec.DistributedInference,
+		DefaultInstanceType:  spec.DefaultInstanceType,
+		SharedMemory:         spec.SharedMemory,
+		Volumes:              spec.Volumes,
+		VolumeMounts:         spec.VolumeMounts,
+		LivenessProbe:        spec.LivenessProbe,
+		ReadinessProbe:       spec.ReadinessProbe,
+	}
+	if spec.MinGPUMemory != nil {
+		param.MinGPUMemory = spec.MinGPUMemory.String()
+	}
+	if spec.DiskStorageRequirement != nil {
+		param.DiskStorageRequirement = spec.DiskStorageRequirement.String()
+	}
+	var trainingParam *PresetTrainingParam
+	if spec.Training != nil {
+		trainingParam = &PresetTrainingParam{
+			Image:           spec.Training.Image,
+			BaseCommand:     spec.Training.BaseCommand,
+			TrainingFile:    spec.Training.TrainingFile,
+			TorchRunParams:  spec.Training.TorchRunParams,
+			TrainingParams:  spec.Training.TrainingParams,
+			GPUCountPerNode: spec.Training.GPUCountPerNode,
+		}
+		if trainingParam.Image == "" {
+			trainingParam.Image = spec.Image
+		}
+	}
+	return &modelPreset{
+		name:          kdmv1alpha1.PresetModelName(presetObj.Name),
+		param:         param,
+		trainingParam: trainingParam,
+	}
+}
+
+func (p *modelPreset) Name() kdmv1alpha1.PresetModelName {
+	return p.name
+}
+
+func (p *modelPreset) GetInferenceParameters() *PresetInferenceParam {
+	return &p.param
+}
+
+func (p *modelPreset) GetTrainingParameters() *PresetTrainingParam {
+	return p.trainingParam
+}
+
+// ValidateModelPreset checks that a ModelPreset describes a deployable model.
+func ValidateModelPreset(presetObj *kdmv1alpha1.ModelPreset) error {
+	specPath := field.NewPath("spec")
+	spec := presetObj.Spec
+	var errs field.ErrorList
+
+	if spec.Image == "" {
+		errs = append(errs, field.Required(specPath.Child("image"), ""))
+	}
+	if spec.BaseCommand == "" {
+		errs = append(errs, field.Required(specPath.Child("baseCommand"), ""))
+	}
+	if spec.InferenceFile == "" {
+		errs = append(errs, field.Required(specPath.Child("inferenceFile"), ""))
+	}
+	if spec.GPUCountPerReplica < 1 {
+		errs = append(errs, field.Invalid(specPath.Child("gpuCountPerReplica"), spec.GPUCountPerReplica, "must be at least 1"))
+	}
+
+	volumeNames := lo.Map(spec.Volumes, func(v corev1.Volume, _ int) string {
+		return v.Name
+	})
+	if spec.SharedMemory {
+		volumeNames = append(volumeNames, SharedMemoryVolumeName)
+	}
+	for i, volumeMount := range spec.VolumeMounts {
+		if !lo.Contains(volumeNames, volumeMount.Name) {
+			errs = append(errs, field.NotFound(specPath.Child("volumeMounts").Index(i).Child("name"), volumeMount.Name))
+		}
+	}
+
+	if training := spec.Training; training != nil {
+		trainingPath := specPath.Child("training")
+		if training.BaseCommand == "" {
+			errs = append(errs, field.Required(trainingPath.Child("baseCommand"), ""))
+		}
+		if training.TrainingFile == "" {
+			errs = append(errs, field.Required(trainingPath.Child("trainingFile"), ""))
+		}
+		if training.GPUCountPerNode < 1 {
+			errs = append(errs, field.Invalid(trainingPath.Child("gpuCountPerNode"), training.GPUCountPerNode, "must be at least 1"))
+		}
+	}
+
+	for probePath, probe := range map[string]*corev1.Probe{"livenessProbe": spec.LivenessProbe, "readinessProbe": spec.ReadinessProbe} {
+		if probe != nil && probe.HTTPGet == nil && probe.TCPSocket == nil && probe.Exec == nil && probe.GRPC == nil {
+			errs = append(errs, field.Required(specPath.Child(probePath), "must specify a handler"))
+		}
+	}
+
+	return errs.ToAggregate()
+}
+
+// ResolvePreset returns the preset with the given name. A ModelPreset object in the cluster takes precedence
+// over a preset registered with RegisterPreset.
+func ResolvePreset(ctx context.Context, name kdmv1alpha1.PresetModelName, kubeClient client.Client) (Preset, error) {
+	klog.InfoS("ResolvePreset", "preset", name)
+
+	presetObj := &kdmv1alpha1.ModelPreset{}
+	err := kubeClient.Get(ctx, client.ObjectKey{Name: string(name)}, presetObj)
+	if err != nil {
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return GetPreset(name)
+		}
+		return nil, err
+	}
+
+	if err := ValidateModelPreset(presetObj); err != nil {
+		return nil, fmt.Errorf("preset model %s is invalid: %w", name, err)
+	}
+	return NewModelPreset(presetObj), nil
+}
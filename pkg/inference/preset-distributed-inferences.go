@@ -0,0 +1,93 @@
+package inference
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/k8sresources"
+	"github.com/samber/lo"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// RendezvousPort is the port torchrun uses to rendezvous the ranks of a distributed model.
+const RendezvousPort = int32(29500)
+
+// IsDistributed returns true if the preset model is sharded across the workspace nodes, in which case
+// the model runs as a statefulset instead of a deployment.
+func IsDistributed(workspaceObj *kdmv1alpha1.Workspace, inferenceParam *PresetInferenceParam) bool {
+	return inferenceParam.DistributedInference && len(workspaceObj.Status.WorkerNodes) > 1
+}
+
+// HeadlessServiceName returns the name of the headless service giving the ranks of a distributed model stable DNS names.
+func HeadlessServiceName(workspaceObj *kdmv1alpha1.Workspace) string {
+	return workspaceObj.Name + "-headless"
+}
+
+// generateDistributedPresetInference generates a statefulset running one rank of the model on each node in Status.WorkerNodes.
+func generateDistributedPresetInference(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, inferenceParam *PresetInferenceParam,
+	volume []corev1.Volume, volumeMount []corev1.VolumeMount) (*appsv1.StatefulSet, error) {
+	nodeNames := workspaceObj.Status.WorkerNodes
+	nodeCount := len(nodeNames)
+	if inferenceParam.GPUCountPerReplica%nodeCount != 0 {
+		return nil, fmt.Errorf("%d GPUs of the preset model cannot be split evenly across %d nodes", inferenceParam.GPUCountPerReplica, nodeCount)
+	}
+	gpuCountPerNode := inferenceParam.GPUCountPerReplica / nodeCount
+
+	resourceRequirements := buildResourceRequirements(inferenceParam)
+	gpuQuantity := resource.MustParse(strconv.Itoa(gpuCountPerNode))
+	resourceRequirements.Limits[corev1.ResourceName(k8sresources.CapacityNvidiaGPU)] = gpuQuantity
+	resourceRequirements.Requests[corev1.ResourceName(k8sresources.CapacityNvidiaGPU)] = gpuQuantity
+
+	ports := append([]corev1.ContainerPort{}, containerPorts...)
+	ports = append(ports, corev1.ContainerPort{ContainerPort: RendezvousPort})
+
+	// Only the rank 0 pod serves http requests, so probes on port 5000 would keep restarting the other ranks.
+	serviceName := HeadlessServiceName(workspaceObj)
+	return k8sresources.GenerateStatefulSetManifest(ctx, workspaceObj, inferenceParam.Image, serviceName, nodeNames,
+		buildDistributedCommand(workspaceObj, inferenceParam, serviceName, gpuCountPerNode), ports, nil, nil,
+		resourceRequirements, volumeMount, tolerations, volume), nil
+}
+
+// createDistributedPresetInference creates the statefulset of a distributed model and the headless service
+// used for the torchrun rendezvous.
+func createDistributedPresetInference(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, stsObj *appsv1.StatefulSet,
+	kubeClient client.Client) error {
+	serviceObj := k8sresources.GenerateHeadlessServiceManifest(ctx, workspaceObj, stsObj.Spec.ServiceName,
+		workspaceObj.Resource.LabelSelector.MatchLabels, RendezvousPort)
+	if err := k8sresources.CreateService(ctx, serviceObj, kubeClient); err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	return k8sresources.CreateStatefulSet(ctx, stsObj, kubeClient)
+}
+
+// buildDistributedCommand assembles the torchrun command of a distributed model. The node rank is the ordinal
+// in the pod host name and every rank rendezvous with the pod with ordinal 0.
+func buildDistributedCommand(workspaceObj *kdmv1alpha1.Workspace, inferenceParam *PresetInferenceParam,
+	serviceName string, gpuCountPerNode int) []string {
+	torchRunParams := lo.Assign(inferenceParam.TorchRunParams, map[string]string{
+		"nnodes":         strconv.Itoa(len(workspaceObj.Status.WorkerNodes)),
+		"nproc_per_node": strconv.Itoa(gpuCountPerNode),
+		"node_rank":      "${HOSTNAME##*-}",
+		"master_addr":    fmt.Sprintf("%s-0.%s", workspaceObj.Name, serviceName),
+		"master_port":    strconv.Itoa(int(RendezvousPort)),
+	})
+
+	commandParts := []string{inferenceParam.BaseCommand}
+	commandParts = append(commandParts, BuildParams(torchRunParams)...)
+	commandParts = append(commandParts, inferenceParam.InferenceFile)
+	commandParts = append(commandParts, BuildParams(inferenceParam.ModelRunParams)...)
+
+	return []string{
+		"/bin/sh",
+		"-c",
+		strings.Join(commandParts, " "),
+	}
+}
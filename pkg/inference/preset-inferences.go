@@ -0,0 +1,148 @@
+package inference
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/k8sresources"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	ProbePath = "/healthz"
+	Port5000  = int32(5000)
+)
+
+var (
+	containerPorts = []corev1.ContainerPort{{
+		ContainerPort: Port5000,
+	},
+	}
+
+	tolerations = []corev1.Toleration{
+		{
+			Effect:   corev1.TaintEffectNoSchedule,
+			Operator: corev1.TolerationOpEqual,
+			Key:      k8sresources.GPUString,
+		},
+		{
+			Effect: corev1.TaintEffectNoSchedule,
+			Value:  k8sresources.GPUString,
+			Key:    "sku",
+		},
+	}
+)
+
+// GPUTolerations returns the tolerations of the taints kdm puts on the GPU nodes.
+func GPUTolerations() []corev1.Toleration {
+	return tolerations
+}
+
+// SharedMemoryVolumeName is the name of the memory backed volume mounted at /dev/shm.
+const SharedMemoryVolumeName = "dshm"
+
+// SharedMemoryVolume returns the memory backed volume used by presets that need shared memory.
+func SharedMemoryVolume() corev1.Volume {
+	return corev1.Volume{
+		Name: SharedMemoryVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{
+				Medium: corev1.StorageMediumMemory,
+			},
+		},
+	}
+}
+
+// GeneratePresetInference generates the workload running a preset model: a deployment with one replica per
+// workspace node, or a statefulset for distributed presets on a workspace with several nodes.
+func GeneratePresetInference(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, preset Preset,
+	volume []corev1.Volume) (client.Object, error) {
+	inferenceParam := preset.GetInferenceParameters()
+
+	commands := buildCommand(inferenceParam)
+	resourceRequirements := buildResourceRequirements(inferenceParam)
+
+	_, foundSharedMemory := lo.Find(volume, func(v corev1.Volume) bool {
+		return v.Name == SharedMemoryVolumeName
+	})
+	if inferenceParam.SharedMemory && !foundSharedMemory {
+		volume = append([]corev1.Volume{SharedMemoryVolume()}, volume...)
+	}
+	volumeMount := []corev1.VolumeMount{}
+	if len(volume) != 0 {
+		// The shared memory volume is mounted at /dev/shm, otherwise the first custom volume is.
+		shmVolumeName := volume[0].Name
+		if foundSharedMemory {
+			shmVolumeName = SharedMemoryVolumeName
+		}
+		volumeMount = append(volumeMount, corev1.VolumeMount{
+			Name:      shmVolumeName,
+			MountPath: "/dev/shm",
+		})
+	}
+	volume = append(volume, inferenceParam.Volumes...)
+	volumeMount = append(volumeMount, inferenceParam.VolumeMounts...)
+
+	if IsDistributed(workspaceObj, inferenceParam) {
+		return generateDistributedPresetInference(ctx, workspaceObj, inferenceParam, volume, volumeMount)
+	}
+
+	return k8sresources.GenerateDeploymentManifest(ctx, workspaceObj, inferenceParam.Image,
+		Replicas(workspaceObj), commands, containerPorts, inferenceParam.LivenessProbe, inferenceParam.ReadinessProbe,
+		resourceRequirements, volumeMount, tolerations, volume), nil
+}
+
+func buildResourceRequirements(inferenceParam *PresetInferenceParam) corev1.ResourceRequirements {
+	gpuQuantity := resource.MustParse(strconv.Itoa(inferenceParam.GPUCountPerReplica))
+	resourceRequirements := corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{
+			corev1.ResourceName(k8sresources.CapacityNvidiaGPU): gpuQuantity,
+		},
+		Requests: corev1.ResourceList{
+			corev1.ResourceName(k8sresources.CapacityNvidiaGPU): gpuQuantity,
+		},
+	}
+	if inferenceParam.DiskStorageRequirement != "" {
+		resourceRequirements.Requests[corev1.ResourceEphemeralStorage] = resource.MustParse(inferenceParam.DiskStorageRequirement)
+	}
+	return resourceRequirements
+}
+
+// buildCommand assembles "<BaseCommand> <torchrun params> <InferenceFile> <model run params>".
+// Parameters are sorted by name so the generated command is stable across reconciles.
+func buildCommand(inferenceParam *PresetInferenceParam) []string {
+	commandParts := []string{inferenceParam.BaseCommand}
+	commandParts = append(commandParts, BuildParams(inferenceParam.TorchRunParams)...)
+	commandParts = append(commandParts, inferenceParam.InferenceFile)
+	commandParts = append(commandParts, BuildParams(inferenceParam.ModelRunParams)...)
+
+	commands := []string{
+		"/bin/sh",
+		"-c",
+		strings.Join(commandParts, " "),
+	}
+
+	return commands
+}
+
+// BuildParams converts the parameters to "--key=value" arguments sorted by key.
+func BuildParams(params map[string]string) []string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, fmt.Sprintf("--%s=%s", key, params[key]))
+	}
+	return result
+}
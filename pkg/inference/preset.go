@@ -0,0 +1,132 @@
+package inference
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// PresetInferenceParam describes how a preset model is deployed for inference.
+type PresetInferenceParam struct {
+	// Image is the container image that serves the model.
+	Image string
+	// BaseCommand is the command used to launch torchrun, e.g. "cd /workspace/llama/llama-2-7b-chat && torchrun".
+	BaseCommand string
+	// InferenceFile is the python entrypoint passed to torchrun.
+	InferenceFile string
+	// TorchRunParams are the torchrun arguments placed before the inference file, e.g. nproc_per_node.
+	TorchRunParams map[string]string
+	// ModelRunParams are the arguments passed to the inference file, e.g. max_seq_len.
+	ModelRunParams map[string]string
+	// GPUCountPerReplica is the number of nvidia.com/gpu required by one replica.
+	GPUCountPerReplica int
+	// DistributedInference indicates one replica can be sharded across several nodes with torchrun.
+	// GPUCountPerReplica is then the total number of GPUs used across the nodes.
+	DistributedInference bool
+	// DefaultInstanceType is used when a workspace using the preset does not specify an instance type
+	// and no instance type of the catalog fits the preset.
+	DefaultInstanceType string
+	// MinGPUMemory is the minimum memory of a single GPU, e.g. "16Gi". Empty means no requirement.
+	MinGPUMemory string
+	// SharedMemory indicates the model needs a memory backed volume mounted at /dev/shm.
+	SharedMemory bool
+	// DiskStorageRequirement is the ephemeral storage requested by one replica, e.g. "300Gi". Empty means no request.
+	DiskStorageRequirement string
+	// Volumes and VolumeMounts are always added to the pod running the model.
+	Volumes      []corev1.Volume
+	VolumeMounts []corev1.VolumeMount
+	// LivenessProbe and ReadinessProbe are set on the inference container.
+	LivenessProbe  *corev1.Probe
+	ReadinessProbe *corev1.Probe
+}
+
+// Preset is a model that kdm knows how to deploy. Implementations register themselves with RegisterPreset,
+// usually from an init function, and workspaces refer to them by name in Inference.Preset.Name.
+type Preset interface {
+	// Name returns the name workspaces use to refer to the preset.
+	Name() kdmv1alpha1.PresetModelName
+	// GetInferenceParameters returns the parameters used to build the inference workload.
+	GetInferenceParameters() *PresetInferenceParam
+}
+
+// PresetTrainingParam describes how a preset model is fine-tuned.
+type PresetTrainingParam struct {
+	// Image is the container image that fine-tunes the model. The inference image is used if empty.
+	Image string
+	// BaseCommand is the command used to launch torchrun.
+	BaseCommand string
+	// TrainingFile is the python entrypoint passed to torchrun.
+	TrainingFile string
+	// TorchRunParams are the torchrun arguments placed before the training file.
+	TorchRunParams map[string]string
+	// TrainingParams are the arguments passed to the training file.
+	TrainingParams map[string]string
+	// GPUCountPerNode is the number of nvidia.com/gpu used on each training node.
+	GPUCountPerNode int
+}
+
+// TrainablePreset is implemented by presets that can be fine-tuned.
+type TrainablePreset interface {
+	Preset
+	// GetTrainingParameters returns the parameters used to build the training job, or nil if the preset cannot be fine-tuned.
+	GetTrainingParameters() *PresetTrainingParam
+}
+
+// GetTrainingParameters returns the training parameters of the preset, or an error if the preset cannot be fine-tuned.
+func GetTrainingParameters(preset Preset) (*PresetTrainingParam, error) {
+	trainablePreset, ok := preset.(TrainablePreset)
+	if !ok || trainablePreset.GetTrainingParameters() == nil {
+		return nil, fmt.Errorf("preset model %s does not support training", preset.Name())
+	}
+	return trainablePreset.GetTrainingParameters(), nil
+}
+
+var (
+	presetsMu sync.RWMutex
+	presets   = map[kdmv1alpha1.PresetModelName]Preset{}
+)
+
+// RegisterPreset makes a preset available to the workspace controller.
+// It panics if a preset with the same name has already been registered.
+func RegisterPreset(preset Preset) {
+	presetsMu.Lock()
+	defer presetsMu.Unlock()
+
+	if preset == nil {
+		panic("inference: RegisterPreset preset is nil")
+	}
+	if _, found := presets[preset.Name()]; found {
+		panic(fmt.Sprintf("inference: RegisterPreset called twice for preset %s", preset.Name()))
+	}
+	presets[preset.Name()] = preset
+}
+
+// GetPreset returns the registered preset with the given name.
+func GetPreset(name kdmv1alpha1.PresetModelName) (Preset, error) {
+	presetsMu.RLock()
+	defer presetsMu.RUnlock()
+
+	preset, found := presets[name]
+	if !found {
+		return nil, fmt.Errorf("preset model %s is not supported", name)
+	}
+	return preset, nil
+}
+
+// ListPresets returns the names of all registered presets in sorted order.
+func ListPresets() []kdmv1alpha1.PresetModelName {
+	presetsMu.RLock()
+	defer presetsMu.RUnlock()
+
+	names := make([]kdmv1alpha1.PresetModelName, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i] < names[j]
+	})
+	return names
+}
@@ -0,0 +1,192 @@
+package inference
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type testPreset struct {
+	name          kdmv1alpha1.PresetModelName
+	param         PresetInferenceParam
+	trainingParam *PresetTrainingParam
+}
+
+func (p *testPreset) Name() kdmv1alpha1.PresetModelName {
+	return p.name
+}
+
+func (p *testPreset) GetInferenceParameters() *PresetInferenceParam {
+	return &p.param
+}
+
+func (p *testPreset) GetTrainingParameters() *PresetTrainingParam {
+	return p.trainingParam
+}
+
+// registerTestPreset registers the preset for the duration of the test.
+func registerTestPreset(t *testing.T, preset Preset) {
+	t.Helper()
+	RegisterPreset(preset)
+	t.Cleanup(func() {
+		presetsMu.Lock()
+		defer presetsMu.Unlock()
+		delete(presets, preset.Name())
+	})
+}
+
+func expectPanic(t *testing.T, f func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic")
+		}
+	}()
+	f()
+}
+
+func TestPresetRegistry(t *testing.T) {
+	registerTestPreset(t, &testPreset{name: "test-b", param: PresetInferenceParam{Image: "b"}})
+	registerTestPreset(t, &testPreset{name: "test-a", param: PresetInferenceParam{Image: "a"}})
+
+	preset, err := GetPreset("test-a")
+	if err != nil {
+		t.Fatalf("GetPreset() error = %v", err)
+	}
+	if image := preset.GetInferenceParameters().Image; image != "a" {
+		t.Errorf("GetPreset() returned the preset with image %q, want %q", image, "a")
+	}
+	if _, err := GetPreset("test-missing"); err == nil {
+		t.Errorf("GetPreset() of an unknown preset succeeded")
+	}
+	if got, want := ListPresets(), []kdmv1alpha1.PresetModelName{"test-a", "test-b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListPresets() = %v, want %v", got, want)
+	}
+
+	expectPanic(t, func() { RegisterPreset(&testPreset{name: "test-a"}) })
+	expectPanic(t, func() { RegisterPreset(nil) })
+}
+
+func TestGetTrainingParameters(t *testing.T) {
+	trainingParam := &PresetTrainingParam{TrainingFile: "train.py"}
+	testCases := []struct {
+		name    string
+		preset  Preset
+		want    *PresetTrainingParam
+		wantErr bool
+	}{
+		{name: "trainable preset", preset: &testPreset{name: "test", trainingParam: trainingParam}, want: trainingParam},
+		{name: "preset without training parameters", preset: &testPreset{name: "test"}, wantErr: true},
+		{name: "preset that is not trainable", preset: NewModelPreset(&kdmv1alpha1.ModelPreset{}), wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := GetTrainingParameters(tc.preset)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("GetTrainingParameters() error = %v, want error %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("GetTrainingParameters() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func newModelPreset(name, image string) *kdmv1alpha1.ModelPreset {
+	return &kdmv1alpha1.ModelPreset{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: kdmv1alpha1.ModelPresetSpec{
+			Image:              image,
+			BaseCommand:        "torchrun",
+			InferenceFile:      "inference.py",
+			GPUCountPerReplica: 1,
+		},
+	}
+}
+
+func TestResolvePreset(t *testing.T) {
+	registerTestPreset(t, &testPreset{name: "test-registered", param: PresetInferenceParam{Image: "registered"}})
+	registerTestPreset(t, &testPreset{name: "test-overridden", param: PresetInferenceParam{Image: "registered"}})
+	invalid := newModelPreset("test-invalid", "")
+
+	scheme := runtime.NewScheme()
+	if err := kdmv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add the kdm types to the scheme: %v", err)
+	}
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		newModelPreset("test-object", "object"),
+		newModelPreset("test-overridden", "object"),
+		invalid,
+	).Build()
+
+	testCases := []struct {
+		name      string
+		preset    kdmv1alpha1.PresetModelName
+		wantImage string
+		wantErr   bool
+	}{
+		{name: "registered preset", preset: "test-registered", wantImage: "registered"},
+		{name: "ModelPreset object", preset: "test-object", wantImage: "object"},
+		{name: "ModelPreset object takes precedence", preset: "test-overridden", wantImage: "object"},
+		{name: "invalid ModelPreset object", preset: "test-invalid", wantErr: true},
+		{name: "unknown preset", preset: "test-missing", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			preset, err := ResolvePreset(context.Background(), tc.preset, kubeClient)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ResolvePreset() error = %v, want error %t", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if preset.Name() != tc.preset {
+				t.Errorf("ResolvePreset() returned preset %s, want %s", preset.Name(), tc.preset)
+			}
+			if image := preset.GetInferenceParameters().Image; image != tc.wantImage {
+				t.Errorf("ResolvePreset() returned the preset with image %q, want %q", image, tc.wantImage)
+			}
+		})
+	}
+}
+
+func TestValidateModelPreset(t *testing.T) {
+	withTraining := newModelPreset("preset", "image")
+	withTraining.Spec.Training = &kdmv1alpha1.ModelPresetTrainingSpec{BaseCommand: "torchrun", TrainingFile: "train.py"}
+	mountsUnknownVolume := newModelPreset("preset", "image")
+	mountsUnknownVolume.Spec.VolumeMounts = []corev1.VolumeMount{{Name: "data", MountPath: "/data"}}
+	mountsSharedMemory := newModelPreset("preset", "image")
+	mountsSharedMemory.Spec.SharedMemory = true
+	mountsSharedMemory.Spec.VolumeMounts = []corev1.VolumeMount{{Name: SharedMemoryVolumeName, MountPath: "/dev/shm"}}
+	probeWithoutHandler := newModelPreset("preset", "image")
+	probeWithoutHandler.Spec.ReadinessProbe = &corev1.Probe{PeriodSeconds: 10}
+
+	testCases := []struct {
+		name    string
+		preset  *kdmv1alpha1.ModelPreset
+		wantErr bool
+	}{
+		{name: "valid preset", preset: newModelPreset("preset", "image")},
+		{name: "missing image", preset: newModelPreset("preset", ""), wantErr: true},
+		{name: "training without GPUs", preset: withTraining, wantErr: true},
+		{name: "mount of an unknown volume", preset: mountsUnknownVolume, wantErr: true},
+		{name: "mount of the shared memory volume", preset: mountsSharedMemory},
+		{name: "probe without handler", preset: probeWithoutHandler, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := ValidateModelPreset(tc.preset); (err != nil) != tc.wantErr {
+				t.Errorf("ValidateModelPreset() error = %v, want error %t", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package inference
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/k8sresources"
+	"github.com/samber/lo"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetReadyEndpoints returns the host:port addresses of the ready inference pods serving the requests to the
+// workspace. Only the pod with ordinal 0 serves the requests of a distributed model.
+func GetReadyEndpoints(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, kubeClient client.Client) ([]string, error) {
+	pods, err := listReadyPods(ctx, workspaceObj, kubeClient)
+	if err != nil {
+		return nil, err
+	}
+	port := strconv.Itoa(int(k8sresources.GetTargetPort(workspaceObj)))
+	leader := fmt.Sprintf("%s-0", workspaceObj.Name)
+	pods = lo.Filter(pods, func(podObj *corev1.Pod, _ int) bool {
+		name, found := podObj.Labels[appsv1.StatefulSetPodNameLabel]
+		return !found || name == leader
+	})
+	return lo.Map(pods, func(podObj *corev1.Pod, _ int) string {
+		return net.JoinHostPort(podObj.Status.PodIP, port)
+	}), nil
+}
+
+// listReadyPods returns the ready pods of the workspace that have an IP.
+func listReadyPods(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, kubeClient client.Client) ([]*corev1.Pod, error) {
+	podList := &corev1.PodList{}
+	if err := kubeClient.List(ctx, podList, client.InNamespace(workspaceObj.Namespace),
+		client.MatchingLabels(k8sresources.GenerateInferenceSelector(workspaceObj))); err != nil {
+		return nil, err
+	}
+	var pods []*corev1.Pod
+	for i := range podList.Items {
+		podObj := &podList.Items[i]
+		if podObj.Status.PodIP != "" && podObj.DeletionTimestamp.IsZero() && isPodReady(podObj) {
+			pods = append(pods, podObj)
+		}
+	}
+	return pods, nil
+}
@@ -0,0 +1,142 @@
+package inference
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultRequestMetricName is the counter of served requests scraped when the workspace does not name one.
+	DefaultRequestMetricName = "http_requests_total"
+	// DefaultRequestMetricsPath is the path of the metrics endpoint of the inference pods.
+	DefaultRequestMetricsPath = "/metrics"
+	// requestMetricsTimeout bounds all the scrapes of a workspace, so that unresponsive pods do not hold up the
+	// reconciliation.
+	requestMetricsTimeout = 5 * time.Second
+)
+
+// GetRequestCount returns the number of requests served by the workspace: the sum of the request counter of its
+// ready inference pods, scraped concurrently, or the counter of the service in front of it when the workspace names one.
+func GetRequestCount(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, kubeClient client.Client,
+	httpClient *http.Client) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestMetricsTimeout)
+	defer cancel()
+
+	spec := lo.FromPtr(workspaceObj.Spec.RequestMetrics)
+	name := lo.Ternary(spec.Name != "", spec.Name, DefaultRequestMetricName)
+	port := lo.Ternary(spec.Port != 0, spec.Port, Port5000)
+	path := lo.Ternary(spec.Path != "", spec.Path, DefaultRequestMetricsPath)
+	if spec.Service != "" {
+		// The service is always looked up in the namespace of the workspace.
+		if errs := validation.IsDNS1035Label(spec.Service); len(errs) > 0 {
+			return 0, fmt.Errorf("invalid request metrics service %q: %s", spec.Service, strings.Join(errs, ", "))
+		}
+		host := fmt.Sprintf("%s.%s.svc", spec.Service, workspaceObj.Namespace)
+		url := fmt.Sprintf("http://%s%s", net.JoinHostPort(host, strconv.Itoa(int(port))), path)
+		count, err := scrapeRequestCount(ctx, httpClient, url, name, spec.MatchLabels)
+		return int64(count), err
+	}
+
+	pods, err := listReadyPods(ctx, workspaceObj, kubeClient)
+	if err != nil {
+		return 0, err
+	}
+	counts := make([]float64, len(pods))
+	errs := make([]error, len(pods))
+	var wg sync.WaitGroup
+	for i := range pods {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			podObj := pods[i]
+			url := fmt.Sprintf("http://%s%s", net.JoinHostPort(podObj.Status.PodIP, strconv.Itoa(int(port))), path)
+			count, err := scrapeRequestCount(ctx, httpClient, url, name, spec.MatchLabels)
+			if err != nil {
+				errs[i] = fmt.Errorf("failed to scrape pod %s: %w", podObj.Name, err)
+				return
+			}
+			counts[i] = count
+		}(i)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return 0, err
+	}
+	return int64(lo.Sum(counts)), nil
+}
+
+// scrapeRequestCount returns the sum of the samples of the counter matching the labels in the metrics at the URL.
+func scrapeRequestCount(ctx context.Context, httpClient *http.Client, url, name string, matchLabels map[string]string) (float64, error) {
+	klog.InfoS("scrapeRequestCount", "url", url, "metric", name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("metrics endpoint %s returned %s", url, resp.Status)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("invalid metrics at %s: %w", url, err)
+	}
+	family, found := families[name]
+	if !found {
+		// A missing counter must not be mistaken for an idle workspace.
+		return 0, fmt.Errorf("metric %s not found at %s", name, url)
+	}
+
+	total := float64(0)
+	for _, metric := range family.GetMetric() {
+		if !matchesLabels(metric, matchLabels) {
+			continue
+		}
+		switch {
+		case metric.Counter != nil:
+			total += metric.GetCounter().GetValue()
+		case metric.Untyped != nil:
+			total += metric.GetUntyped().GetValue()
+		case metric.Gauge != nil:
+			total += metric.GetGauge().GetValue()
+		}
+	}
+	return total, nil
+}
+
+func matchesLabels(metric *dto.Metric, matchLabels map[string]string) bool {
+	metricLabels := lo.SliceToMap(metric.GetLabel(), func(pair *dto.LabelPair) (string, string) {
+		return pair.GetName(), pair.GetValue()
+	})
+	return lo.EveryBy(lo.Entries(matchLabels), func(entry lo.Entry[string, string]) bool {
+		return metricLabels[entry.Key] == entry.Value
+	})
+}
+
+func isPodReady(podObj *corev1.Pod) bool {
+	_, found := lo.Find(podObj.Status.Conditions, func(condition corev1.PodCondition) bool {
+		return condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue
+	})
+	return found
+}
@@ -0,0 +1,66 @@
+package inference
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testMetrics = `# TYPE http_requests_total counter
+http_requests_total{code="200",service="a"} 10
+http_requests_total{code="500",service="a"} 2
+http_requests_total{code="200",service="b"} 5
+# TYPE requests untyped
+requests 7
+# TYPE in_flight gauge
+in_flight 3
+`
+
+func TestScrapeRequestCount(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/metrics":
+			_, _ = w.Write([]byte(testMetrics))
+		case "/invalid":
+			_, _ = w.Write([]byte("http_requests_total{code=\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name        string
+		path        string
+		metric      string
+		matchLabels map[string]string
+		want        float64
+		wantErr     bool
+	}{
+		{name: "sum of the samples", path: "/metrics", metric: "http_requests_total", want: 17},
+		{name: "samples matching the labels", path: "/metrics", metric: "http_requests_total",
+			matchLabels: map[string]string{"service": "a"}, want: 12},
+		{name: "samples matching all the labels", path: "/metrics", metric: "http_requests_total",
+			matchLabels: map[string]string{"service": "a", "code": "200"}, want: 10},
+		{name: "no sample matches the labels", path: "/metrics", metric: "http_requests_total",
+			matchLabels: map[string]string{"service": "c"}, want: 0},
+		{name: "untyped metric", path: "/metrics", metric: "requests", want: 7},
+		{name: "gauge", path: "/metrics", metric: "in_flight", want: 3},
+		{name: "missing metric", path: "/metrics", metric: "missing", wantErr: true},
+		{name: "invalid metrics", path: "/invalid", metric: "http_requests_total", wantErr: true},
+		{name: "metrics endpoint not found", path: "/missing", metric: "http_requests_total", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := scrapeRequestCount(context.Background(), server.Client(), server.URL+tc.path, tc.metric, tc.matchLabels)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("scrapeRequestCount() error = %v, want error %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("scrapeRequestCount() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
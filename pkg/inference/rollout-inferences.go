@@ -0,0 +1,57 @@
+package inference
+
+import (
+	"context"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/k8sresources"
+	"github.com/samber/lo"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CreateInference creates the inference workload generated by GeneratePresetInference or GenerateTemplateInference.
+// Its readiness is reported by GetInferenceStatus.
+func CreateInference(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, workloadObj client.Object, kubeClient client.Client) error {
+	klog.InfoS("CreateInference", "workspace", klog.KObj(workspaceObj), "workload", klog.KObj(workloadObj))
+
+	if stsObj, ok := workloadObj.(*appsv1.StatefulSet); ok {
+		return createDistributedPresetInference(ctx, workspaceObj, stsObj, kubeClient)
+	}
+	return k8sresources.CreateDeployment(ctx, workloadObj.(*appsv1.Deployment), kubeClient)
+}
+
+// RolloutInference starts rolling the existing inference deployment out to the desired one.
+// Its progress is reported by GetInferenceStatus.
+func RolloutInference(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, desiredObj *appsv1.Deployment,
+	kubeClient client.Client) error {
+	klog.InfoS("RolloutInference", "workspace", klog.KObj(workspaceObj), "specHash", k8sresources.GetSpecHash(desiredObj))
+	return k8sresources.UpdateDeployment(ctx, desiredObj, kubeClient)
+}
+
+// DeleteInference starts the foreground deletion of an inference workload that cannot be updated in place, e.g.,
+// a statefulset whose ranks must restart together. It does not wait for the deletion: it returns true once the
+// workload and its pods are gone. The deletion event of the workload brings the workspace back.
+func DeleteInference(ctx context.Context, workloadObj client.Object, kubeClient client.Client) (bool, error) {
+	if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(workloadObj), workloadObj); err != nil {
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	if !workloadObj.GetDeletionTimestamp().IsZero() {
+		return false, nil
+	}
+
+	klog.InfoS("DeleteInference", "workload", klog.KObj(workloadObj))
+	err := kubeClient.Delete(ctx, workloadObj, &client.DeleteOptions{
+		PropagationPolicy: lo.ToPtr(metav1.DeletePropagationForeground),
+	})
+	if apierrors.IsNotFound(err) {
+		return true, nil
+	}
+	return false, err
+}
@@ -0,0 +1,29 @@
+package inference
+
+import (
+	"context"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/k8sresources"
+	"github.com/kdm/pkg/utils"
+	"github.com/samber/lo"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Replicas returns the number of inference replicas of the workspace, which is one per workspace node.
+func Replicas(workspaceObj *kdmv1alpha1.Workspace) int {
+	return utils.GetNodeCount(workspaceObj)
+}
+
+// ScaleInference sets the replicas of an existing inference deployment to Replicas.
+func ScaleInference(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, depObj *appsv1.Deployment, kubeClient client.Client) error {
+	replicas := Replicas(workspaceObj)
+	if lo.FromPtr(depObj.Spec.Replicas) == int32(replicas) {
+		return nil
+	}
+	klog.InfoS("ScaleInference", "workspace", klog.KObj(workspaceObj), "from", lo.FromPtr(depObj.Spec.Replicas), "to", replicas)
+
+	return k8sresources.ScaleDeployment(ctx, depObj.Name, depObj.Namespace, replicas, kubeClient)
+}
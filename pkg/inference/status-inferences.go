@@ -0,0 +1,181 @@
+package inference
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/samber/lo"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	reasonInferenceReady      = "WorkspaceInferenceStatusSuccess"
+	reasonInferenceRollingOut = "WorkspaceInferenceRollingOut"
+	reasonProgressDeadline    = "ProgressDeadlineExceeded"
+	reasonUnschedulable       = "Unschedulable"
+	reasonOOMKilled           = "OOMKilled"
+)
+
+// stuckContainerReasons are the waiting reasons of containers that will not become ready without user intervention.
+var stuckContainerReasons = []string{
+	"ImagePullBackOff",
+	"ErrImagePull",
+	"CrashLoopBackOff",
+	"CreateContainerConfigError",
+	"InvalidImageName",
+}
+
+// InferenceStatus is the observed state of an inference workload.
+type InferenceStatus struct {
+	Status  metav1.ConditionStatus
+	Reason  string
+	Message string
+	// Replicas and ReadyReplicas count the replicas of the model. A distributed model is a single replica
+	// which is ready when the pods on all nodes are.
+	Replicas      int32
+	ReadyReplicas int32
+}
+
+// IsReady returns true if all replicas of the model are up to date and ready.
+func (s *InferenceStatus) IsReady() bool {
+	return s.Status == metav1.ConditionTrue
+}
+
+// GetDeletingStatus reports that the inference workload replacing the given one waits for it to be deleted.
+func GetDeletingStatus(workloadObj client.Object) *InferenceStatus {
+	return &InferenceStatus{
+		Status:  metav1.ConditionUnknown,
+		Reason:  reasonInferenceRollingOut,
+		Message: fmt.Sprintf("waiting for %s to be deleted", workloadObj.GetName()),
+	}
+}
+
+// GetInferenceStatus reports whether the inference workload is ready. When it is not, the reason pods are stuck,
+// e.g., ImagePullBackOff, OOMKilled, Unschedulable or CrashLoopBackOff, is surfaced instead of a generic message.
+func GetInferenceStatus(ctx context.Context, workloadObj client.Object, kubeClient client.Client) (*InferenceStatus, error) {
+	if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(workloadObj), workloadObj); err != nil {
+		return nil, err
+	}
+
+	var status *InferenceStatus
+	var selector *metav1.LabelSelector
+	switch obj := workloadObj.(type) {
+	case *appsv1.Deployment:
+		status = getDeploymentStatus(obj)
+		selector = obj.Spec.Selector
+	case *appsv1.StatefulSet:
+		status = getStatefulSetStatus(obj)
+		selector = obj.Spec.Selector
+	default:
+		return nil, fmt.Errorf("unsupported inference workload %T", workloadObj)
+	}
+	if status.IsReady() || status.Status == metav1.ConditionFalse {
+		return status, nil
+	}
+
+	reason, message, err := getStuckPodReason(ctx, workloadObj.GetNamespace(), selector, kubeClient)
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		status.Status = metav1.ConditionFalse
+		status.Reason = reason
+		status.Message = message
+	}
+	klog.InfoS("GetInferenceStatus", "workload", klog.KObj(workloadObj), "status", status.Status, "reason", status.Reason)
+	return status, nil
+}
+
+func getDeploymentStatus(depObj *appsv1.Deployment) *InferenceStatus {
+	replicas := lo.FromPtr(depObj.Spec.Replicas)
+	status := &InferenceStatus{
+		Replicas:      replicas,
+		ReadyReplicas: depObj.Status.ReadyReplicas,
+	}
+
+	for _, condition := range depObj.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Reason == reasonProgressDeadline {
+			status.Status = metav1.ConditionFalse
+			status.Reason = reasonProgressDeadline
+			status.Message = condition.Message
+			return status
+		}
+	}
+
+	// Replicas of the old template are still running while Status.Replicas exceeds the desired replicas.
+	if depObj.Status.ObservedGeneration < depObj.Generation || depObj.Status.UpdatedReplicas < replicas ||
+		depObj.Status.ReadyReplicas < replicas || depObj.Status.Replicas > replicas {
+		status.Status = metav1.ConditionUnknown
+		status.Reason = reasonInferenceRollingOut
+		status.Message = fmt.Sprintf("%d of %d replicas have been updated, %d are ready",
+			depObj.Status.UpdatedReplicas, replicas, depObj.Status.ReadyReplicas)
+		return status
+	}
+
+	status.Status = metav1.ConditionTrue
+	status.Reason = reasonInferenceReady
+	status.Message = "Inference has been deployed successfully"
+	return status
+}
+
+func getStatefulSetStatus(stsObj *appsv1.StatefulSet) *InferenceStatus {
+	nodeCount := lo.FromPtr(stsObj.Spec.Replicas)
+	status := &InferenceStatus{Replicas: 1}
+
+	if stsObj.Status.ObservedGeneration < stsObj.Generation || stsObj.Status.ReadyReplicas < nodeCount {
+		status.Status = metav1.ConditionUnknown
+		status.Reason = reasonInferenceRollingOut
+		status.Message = fmt.Sprintf("%d of %d ranks are ready", stsObj.Status.ReadyReplicas, nodeCount)
+		return status
+	}
+
+	status.ReadyReplicas = 1
+	status.Status = metav1.ConditionTrue
+	status.Reason = reasonInferenceReady
+	status.Message = "Inference has been deployed successfully"
+	return status
+}
+
+// getStuckPodReason returns the reason and message of the first pod of the workload that cannot make progress,
+// or an empty reason if all pods are still starting.
+func getStuckPodReason(ctx context.Context, namespace string, selector *metav1.LabelSelector,
+	kubeClient client.Client) (string, string, error) {
+	if selector == nil {
+		return "", "", nil
+	}
+	podList := &corev1.PodList{}
+	if err := kubeClient.List(ctx, podList, client.InNamespace(namespace), client.MatchingLabels(selector.MatchLabels)); err != nil {
+		return "", "", err
+	}
+
+	for i := range podList.Items {
+		podObj := &podList.Items[i]
+		if !podObj.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		for _, condition := range podObj.Status.Conditions {
+			if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse &&
+				condition.Reason == corev1.PodReasonUnschedulable {
+				return reasonUnschedulable, fmt.Sprintf("pod %s cannot be scheduled: %s", podObj.Name, condition.Message), nil
+			}
+		}
+
+		for _, containerStatus := range podObj.Status.ContainerStatuses {
+			// A container killed for running out of memory is restarted into CrashLoopBackOff, the OOM is the cause.
+			if terminated := containerStatus.LastTerminationState.Terminated; terminated != nil && terminated.Reason == reasonOOMKilled {
+				return reasonOOMKilled, fmt.Sprintf("container %s of pod %s has been killed for running out of memory",
+					containerStatus.Name, podObj.Name), nil
+			}
+			if waiting := containerStatus.State.Waiting; waiting != nil && lo.Contains(stuckContainerReasons, waiting.Reason) {
+				return waiting.Reason, fmt.Sprintf("container %s of pod %s is in %s: %s",
+					containerStatus.Name, podObj.Name, waiting.Reason, waiting.Message), nil
+			}
+		}
+	}
+	return "", "", nil
+}
@@ -0,0 +1,14 @@
+package inference
+
+import (
+	"context"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/k8sresources"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// GenerateTemplateInference generates the inference deployment from the pod template in the workspace.
+func GenerateTemplateInference(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace) *appsv1.Deployment {
+	return k8sresources.GenerateDeploymentManifestWithPodTemplate(ctx, workspaceObj, Replicas(workspaceObj), tolerations)
+}
@@ -2,12 +2,17 @@ package k8sresources
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
 
 	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
 	"github.com/samber/lo"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -39,50 +44,290 @@ func GetDeployment(ctx context.Context, name, namespace string, kubeClient clien
 	return dep, nil
 }
 
+// UpdateDeployment replaces the pod template, replicas, strategy and annotations of the existing deployment
+// with the ones of the given deployment.
+func UpdateDeployment(ctx context.Context, deploymentObj *appsv1.Deployment, kubeClient client.Client) error {
+	klog.InfoS("UpdateDeployment", "deployment", klog.KObj(deploymentObj))
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		dep := &appsv1.Deployment{}
+		if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(deploymentObj), dep, &client.GetOptions{}); err != nil {
+			return err
+		}
+		dep.Annotations = lo.Assign(dep.Annotations, deploymentObj.Annotations)
+		dep.Spec.Template = deploymentObj.Spec.Template
+		dep.Spec.Replicas = deploymentObj.Spec.Replicas
+		dep.Spec.Strategy = deploymentObj.Spec.Strategy
+		return kubeClient.Update(ctx, dep, &client.UpdateOptions{})
+	})
+}
+
+// GenerateRollingUpdateStrategy returns the rollout strategy of an inference deployment. Every replica holds a whole
+// GPU node, so a surge replica is only started when a spare GPU node can run it. Otherwise the old replicas are
+// replaced one at a time.
+func GenerateRollingUpdateStrategy(hasSpareNode bool) appsv1.DeploymentStrategy {
+	maxSurge, maxUnavailable := intstr.FromInt(0), intstr.FromInt(1)
+	if hasSpareNode {
+		maxSurge, maxUnavailable = intstr.FromInt(1), intstr.FromInt(0)
+	}
+	return appsv1.DeploymentStrategy{
+		Type: appsv1.RollingUpdateDeploymentStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateDeployment{
+			MaxSurge:       &maxSurge,
+			MaxUnavailable: &maxUnavailable,
+		},
+	}
+}
+
+// ScaleDeployment sets the number of replicas of the deployment.
+func ScaleDeployment(ctx context.Context, name, namespace string, replicas int, kubeClient client.Client) error {
+	klog.InfoS("ScaleDeployment", "deploymentName", name, "deploymentNamespace", namespace, "replicas", replicas)
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		dep := &appsv1.Deployment{}
+		if err := kubeClient.Get(ctx, client.ObjectKey{Name: name, Namespace: namespace}, dep, &client.GetOptions{}); err != nil {
+			return err
+		}
+		dep.Spec.Replicas = lo.ToPtr(int32(replicas))
+		return kubeClient.Update(ctx, dep, &client.UpdateOptions{})
+	})
+}
+
 func GenerateDeploymentManifest(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, imageName string,
 	replicas int, commands []string, containerPorts []corev1.ContainerPort,
 	livenessProbe, readinessProbe *corev1.Probe, resourceRequirements corev1.ResourceRequirements,
 	volumeMount []corev1.VolumeMount, tolerations []corev1.Toleration, volumes []corev1.Volume) *appsv1.Deployment {
 	klog.InfoS("GenerateDeploymentManifest", "workspace", klog.KObj(workspaceObj), "image", imageName)
 
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: v1.ObjectMeta{
+			Labels: generatePodLabels(workspaceObj, nil),
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:           workspaceObj.Name,
+					Image:          imageName,
+					Command:        commands,
+					Resources:      resourceRequirements,
+					LivenessProbe:  livenessProbe,
+					ReadinessProbe: readinessProbe,
+					Ports:          containerPorts,
+					VolumeMounts:   volumeMount,
+				},
+			},
+			Tolerations:  tolerations,
+			Volumes:      volumes,
+			NodeSelector: workspaceObj.Resource.LabelSelector.MatchLabels,
+		},
+	}
+
 	return &appsv1.Deployment{
 		ObjectMeta: v1.ObjectMeta{
-			Name:      workspaceObj.Name,
-			Namespace: workspaceObj.Namespace,
+			Name:        workspaceObj.Name,
+			Namespace:   workspaceObj.Namespace,
+			Annotations: generateSpecHashAnnotations(template),
 			OwnerReferences: []v1.OwnerReference{
 				{
 					APIVersion: kdmv1alpha1.GroupVersion.String(),
 					Kind:       "Workspace",
 					UID:        workspaceObj.UID,
 					Name:       workspaceObj.Name,
+					Controller: lo.ToPtr(true),
 				},
 			},
 		},
 		Spec: appsv1.DeploymentSpec{
 			Replicas: lo.ToPtr(int32(replicas)),
 			Selector: workspaceObj.Resource.LabelSelector,
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: v1.ObjectMeta{
-					Labels: workspaceObj.Resource.LabelSelector.MatchLabels,
+			Template: template,
+		},
+	}
+}
+
+// GenerateDeploymentManifestWithPodTemplate generates a deployment from the pod template supplied in the workspace inference spec.
+// The workspace labels, node selector and tolerations are added on top of the ones in the template.
+func GenerateDeploymentManifestWithPodTemplate(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace,
+	replicas int, tolerations []corev1.Toleration) *appsv1.Deployment {
+	klog.InfoS("GenerateDeploymentManifestWithPodTemplate", "workspace", klog.KObj(workspaceObj))
+
+	templateCopy := workspaceObj.Inference.Template.DeepCopy()
+	templateCopy.Labels = generatePodLabels(workspaceObj, templateCopy.Labels)
+	templateCopy.Spec.NodeSelector = lo.Assign(templateCopy.Spec.NodeSelector, workspaceObj.Resource.LabelSelector.MatchLabels)
+	for i := range tolerations {
+		if !lo.Contains(templateCopy.Spec.Tolerations, tolerations[i]) {
+			templateCopy.Spec.Tolerations = append(templateCopy.Spec.Tolerations, tolerations[i])
+		}
+	}
+
+	return &appsv1.Deployment{
+		ObjectMeta: v1.ObjectMeta{
+			Name:        workspaceObj.Name,
+			Namespace:   workspaceObj.Namespace,
+			Annotations: generateSpecHashAnnotations(*templateCopy),
+			OwnerReferences: []v1.OwnerReference{
+				{
+					APIVersion: kdmv1alpha1.GroupVersion.String(),
+					Kind:       "Workspace",
+					UID:        workspaceObj.UID,
+					Name:       workspaceObj.Name,
+					Controller: lo.ToPtr(true),
+				},
+			},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: lo.ToPtr(int32(replicas)),
+			Selector: workspaceObj.Resource.LabelSelector,
+			Template: *templateCopy,
+		},
+	}
+}
+
+// ActivatorName returns the name of the activator deployment of the workspace.
+func ActivatorName(workspaceObj *kdmv1alpha1.Workspace) string {
+	return workspaceObj.Name + "-activator"
+}
+
+const (
+	// ActivationTokenAudience is the audience of the service account tokens the activators authenticate to the
+	// activation server with.
+	ActivationTokenAudience = "kdm-activation"
+	// activationTokenDir is where the activation token is projected in the activator pods.
+	activationTokenDir = "/var/run/secrets/kdm.io/activation"
+	// activationTokenExpirationSeconds is the lifetime of the activation token, the kubelet renews it before.
+	activationTokenExpirationSeconds = 3600
+)
+
+// GenerateActivatorLabels returns the labels of the activator pods of the workspace, which the workspace service
+// selects while the workspace has no ready inference replica.
+func GenerateActivatorLabels(workspaceObj *kdmv1alpha1.Workspace) map[string]string {
+	return map[string]string{
+		kdmv1alpha1.LabelWorkspaceActivator: workspaceObj.Name,
+	}
+}
+
+// GenerateActivatorDeploymentManifest generates the deployment of the activator holding the requests to the workspace
+// while it is resumed. The activator listens on the target port of the workspace service and runs on any node.
+func GenerateActivatorDeploymentManifest(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, imageName,
+	activationURL string) *appsv1.Deployment {
+	klog.InfoS("GenerateActivatorDeploymentManifest", "workspace", klog.KObj(workspaceObj), "image", imageName)
+
+	port := GetTargetPort(workspaceObj)
+	activationTimeout := lo.FromPtr(workspaceObj.Spec.ScaleToZero.ActivationTimeout)
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: v1.ObjectMeta{
+			Labels: GenerateActivatorLabels(workspaceObj),
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:    "activator",
+					Image:   imageName,
+					Command: []string{"/activator"},
+					Args: []string{
+						fmt.Sprintf("--workspace=%s", workspaceObj.Name),
+						fmt.Sprintf("--namespace=%s", workspaceObj.Namespace),
+						fmt.Sprintf("--port=%d", port),
+						fmt.Sprintf("--activation-url=%s", activationURL),
+						fmt.Sprintf("--activation-timeout=%s", activationTimeout.Duration),
+						fmt.Sprintf("--token-file=%s/token", activationTokenDir),
+					},
+					Ports: []corev1.ContainerPort{{ContainerPort: port, Protocol: corev1.ProtocolTCP}},
+					ReadinessProbe: &corev1.Probe{
+						ProbeHandler: corev1.ProbeHandler{
+							TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(int(port))},
+						},
+					},
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse("10m"),
+							corev1.ResourceMemory: resource.MustParse("32Mi"),
+						},
+						Limits: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse("500m"),
+							corev1.ResourceMemory: resource.MustParse("128Mi"),
+						},
+					},
+					SecurityContext: &corev1.SecurityContext{
+						RunAsNonRoot:             lo.ToPtr(true),
+						AllowPrivilegeEscalation: lo.ToPtr(false),
+						Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+					},
+					VolumeMounts: []corev1.VolumeMount{
+						{Name: "activation-token", MountPath: activationTokenDir, ReadOnly: true},
+					},
 				},
-				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
-						{
-							Name:           workspaceObj.Name,
-							Image:          imageName,
-							Command:        commands,
-							Resources:      resourceRequirements,
-							LivenessProbe:  livenessProbe,
-							ReadinessProbe: readinessProbe,
-							Ports:          containerPorts,
-							VolumeMounts:   volumeMount,
+			},
+			// The activator only talks to the controller and to the inference pods, its token is only valid for the
+			// activation server.
+			ServiceAccountName:           ActivatorName(workspaceObj),
+			AutomountServiceAccountToken: lo.ToPtr(false),
+			Volumes: []corev1.Volume{
+				{
+					Name: "activation-token",
+					VolumeSource: corev1.VolumeSource{
+						Projected: &corev1.ProjectedVolumeSource{
+							Sources: []corev1.VolumeProjection{
+								{
+									ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+										Audience:          ActivationTokenAudience,
+										ExpirationSeconds: lo.ToPtr(int64(activationTokenExpirationSeconds)),
+										Path:              "token",
+									},
+								},
+							},
 						},
 					},
-					Tolerations:  tolerations,
-					Volumes:      volumes,
-					NodeSelector: workspaceObj.Resource.LabelSelector.MatchLabels,
 				},
 			},
 		},
 	}
+
+	return &appsv1.Deployment{
+		ObjectMeta: v1.ObjectMeta{
+			Name:        ActivatorName(workspaceObj),
+			Namespace:   workspaceObj.Namespace,
+			Annotations: generateSpecHashAnnotations(template),
+			OwnerReferences: []v1.OwnerReference{
+				{
+					APIVersion: kdmv1alpha1.GroupVersion.String(),
+					Kind:       "Workspace",
+					UID:        workspaceObj.UID,
+					Name:       workspaceObj.Name,
+					Controller: lo.ToPtr(true),
+				},
+			},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: lo.ToPtr(int32(1)),
+			Selector: &v1.LabelSelector{MatchLabels: GenerateActivatorLabels(workspaceObj)},
+			Template: template,
+		},
+	}
+}
+
+// generatePodLabels returns the given labels merged with the inference pod selector of the workspace.
+func generatePodLabels(workspaceObj *kdmv1alpha1.Workspace, labels map[string]string) map[string]string {
+	return lo.Assign(labels, GenerateInferenceSelector(workspaceObj))
+}
+
+// GenerateInferenceSelector returns the labels selecting the inference pods of the workspace: the workspace label
+// selector, the workspace name label and the inference label, which the training pods of the workspace do not have.
+func GenerateInferenceSelector(workspaceObj *kdmv1alpha1.Workspace) map[string]string {
+	return lo.Assign(workspaceObj.Resource.LabelSelector.MatchLabels, map[string]string{
+		kdmv1alpha1.LabelWorkspaceName:      workspaceObj.Name,
+		kdmv1alpha1.LabelWorkspaceInference: workspaceObj.Name,
+	})
+}
+
+// GetSpecHash returns the pod template hash recorded on an inference workload.
+func GetSpecHash(obj client.Object) string {
+	return obj.GetAnnotations()[kdmv1alpha1.AnnotationSpecHash]
+}
+
+// generateSpecHashAnnotations records the hash of the pod template, so that changes of the workspace can be
+// detected by comparing the hash of the desired template with the one of the existing workload.
+func generateSpecHashAnnotations(template corev1.PodTemplateSpec) map[string]string {
+	data, _ := json.Marshal(template)
+	return map[string]string{
+		kdmv1alpha1.AnnotationSpecHash: fmt.Sprintf("%x", sha256.Sum256(data))[:16],
+	}
 }
@@ -0,0 +1,118 @@
+package k8sresources
+
+import (
+	"context"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/samber/lo"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func CreateJob(ctx context.Context, jobObj *batchv1.Job, kubeClient client.Client) error {
+	klog.InfoS("CreateJob", "job", klog.KObj(jobObj))
+	return retry.OnError(retry.DefaultBackoff, func(err error) bool {
+		return true
+	}, func() error {
+		return kubeClient.Create(ctx, jobObj, &client.CreateOptions{})
+	})
+}
+
+func DeleteJob(ctx context.Context, jobObj *batchv1.Job, kubeClient client.Client) error {
+	klog.InfoS("DeleteJob", "job", klog.KObj(jobObj))
+	return retry.OnError(retry.DefaultBackoff, func(err error) bool {
+		return !apierrors.IsNotFound(err)
+	}, func() error {
+		return kubeClient.Delete(ctx, jobObj, &client.DeleteOptions{
+			PropagationPolicy: lo.ToPtr(v1.DeletePropagationForeground),
+		})
+	})
+}
+
+func GetJob(ctx context.Context, name, namespace string, kubeClient client.Client) (*batchv1.Job, error) {
+	klog.InfoS("GetJob", "jobName", name, "jobNamespace", namespace)
+
+	job := &batchv1.Job{}
+	err := kubeClient.Get(ctx, client.ObjectKey{Name: name, Namespace: namespace}, job, &client.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// GenerateIndexedJobManifest generates an indexed job running one pod on each of the given nodes.
+// The pods get stable host names <job name>-<index> under the given headless service.
+func GenerateIndexedJobManifest(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, jobName, serviceName string,
+	nodeNames []string, labels map[string]string, container corev1.Container, tolerations []corev1.Toleration,
+	volumes []corev1.Volume) *batchv1.Job {
+	klog.InfoS("GenerateIndexedJobManifest", "workspace", klog.KObj(workspaceObj), "job", jobName, "image", container.Image)
+
+	podCount := int32(len(nodeNames))
+	return &batchv1.Job{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      jobName,
+			Namespace: workspaceObj.Namespace,
+			Labels:    labels,
+			OwnerReferences: []v1.OwnerReference{
+				{
+					APIVersion: kdmv1alpha1.GroupVersion.String(),
+					Kind:       "Workspace",
+					UID:        workspaceObj.UID,
+					Name:       workspaceObj.Name,
+					Controller: lo.ToPtr(true),
+				},
+			},
+		},
+		Spec: batchv1.JobSpec{
+			CompletionMode: lo.ToPtr(batchv1.IndexedCompletion),
+			Completions:    lo.ToPtr(podCount),
+			Parallelism:    lo.ToPtr(podCount),
+			BackoffLimit:   lo.ToPtr(int32(0)),
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: v1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					Subdomain:     serviceName,
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers:    []corev1.Container{container},
+					Tolerations:   tolerations,
+					Volumes:       volumes,
+					Affinity: &corev1.Affinity{
+						NodeAffinity: &corev1.NodeAffinity{
+							RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+								NodeSelectorTerms: []corev1.NodeSelectorTerm{
+									{
+										MatchFields: []corev1.NodeSelectorRequirement{
+											{
+												Key:      v1.ObjectNameField,
+												Operator: corev1.NodeSelectorOpIn,
+												Values:   nodeNames,
+											},
+										},
+									},
+								},
+							},
+						},
+						PodAntiAffinity: &corev1.PodAntiAffinity{
+							RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
+								{
+									LabelSelector: &v1.LabelSelector{
+										MatchLabels: labels,
+									},
+									TopologyKey: corev1.LabelHostname,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
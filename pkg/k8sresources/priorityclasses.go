@@ -0,0 +1,34 @@
+package k8sresources
+
+import (
+	"context"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetPriorityClass gets the PriorityClass with the given name.
+func GetPriorityClass(ctx context.Context, name string, kubeClient client.Client) (*schedulingv1.PriorityClass, error) {
+	klog.InfoS("GetPriorityClass", "priorityClassName", name)
+	priorityClass := &schedulingv1.PriorityClass{}
+	if err := kubeClient.Get(ctx, client.ObjectKey{Name: name}, priorityClass, &client.GetOptions{}); err != nil {
+		return nil, err
+	}
+	return priorityClass, nil
+}
+
+// ResolveWorkspacePriority returns the priority of the workspace: the value of its PriorityClass. It returns nil when
+// the workspace has no PriorityClass.
+func ResolveWorkspacePriority(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, kubeClient client.Client) (*int32, error) {
+	if workspaceObj.Resource.PriorityClassName == "" {
+		return nil, nil
+	}
+	priorityClass, err := GetPriorityClass(ctx, workspaceObj.Resource.PriorityClassName, kubeClient)
+	if err != nil {
+		return nil, err
+	}
+	value := priorityClass.Value
+	return &value, nil
+}
@@ -0,0 +1,47 @@
+package k8sresources
+
+import (
+	"context"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func CreateServiceAccount(ctx context.Context, serviceAccountObj *v1.ServiceAccount, kubeClient client.Client) error {
+	klog.InfoS("CreateServiceAccount", "serviceAccount", klog.KObj(serviceAccountObj))
+	return retry.OnError(retry.DefaultBackoff, func(err error) bool {
+		return true
+	}, func() error {
+		return kubeClient.Create(ctx, serviceAccountObj, &client.CreateOptions{})
+	})
+}
+
+// GenerateActivatorServiceAccountManifest generates the service account of the activator of the workspace. The
+// activator authenticates to the activation server with a token of this service account, which only allows it to
+// activate its own workspace.
+func GenerateActivatorServiceAccountManifest(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace) *v1.ServiceAccount {
+	klog.InfoS("GenerateActivatorServiceAccountManifest", "workspace", klog.KObj(workspaceObj))
+
+	return &v1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ActivatorName(workspaceObj),
+			Namespace: workspaceObj.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: kdmv1alpha1.GroupVersion.String(),
+					Kind:       "Workspace",
+					UID:        workspaceObj.UID,
+					Name:       workspaceObj.Name,
+					Controller: lo.ToPtr(true),
+				},
+			},
+		},
+		// The token of the activator is projected with the activation audience only.
+		AutomountServiceAccountToken: lo.ToPtr(false),
+	}
+}
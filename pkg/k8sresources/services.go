@@ -2,9 +2,13 @@ package k8sresources
 
 import (
 	"context"
+	"fmt"
 
 	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/samber/lo"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/util/retry"
@@ -21,6 +25,17 @@ func CreateService(ctx context.Context, serviceObj *v1.Service, kubeClient clien
 	})
 }
 
+func DeleteService(ctx context.Context, serviceObj *v1.Service, kubeClient client.Client) error {
+	klog.InfoS("DeleteService", "service", klog.KObj(serviceObj))
+	return retry.OnError(retry.DefaultBackoff, func(err error) bool {
+		return !apierrors.IsNotFound(err)
+	}, func() error {
+		return kubeClient.Delete(ctx, serviceObj, &client.DeleteOptions{
+			PropagationPolicy: lo.ToPtr(metav1.DeletePropagationForeground),
+		})
+	})
+}
+
 func GetService(ctx context.Context, name, namespace string, kubeClient client.Client) (*v1.Service, error) {
 	klog.InfoS("GetService", "serviceName", name, "serviceNamespace", namespace)
 
@@ -38,9 +53,19 @@ func GetService(ctx context.Context, name, namespace string, kubeClient client.C
 	return svc, nil
 }
 
-func GenerateServiceManifest(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, serviceType v1.ServiceType) *v1.Service {
+// GenerateServiceManifest generates the service exposing the inference workload. When the workload is a statefulset
+// sharded across several nodes, only the pod with ordinal 0 serves requests and the service selects just that pod.
+func GenerateServiceManifest(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, serviceType v1.ServiceType,
+	isStatefulSet bool) *v1.Service {
 	klog.InfoS("GenerateServiceManifest", "workspace", klog.KObj(workspaceObj), "serviceType", serviceType)
 
+	selector := GenerateInferenceSelector(workspaceObj)
+	if isStatefulSet {
+		selector = lo.Assign(selector, map[string]string{
+			appsv1.StatefulSetPodNameLabel: fmt.Sprintf("%s-0", workspaceObj.Name),
+		})
+	}
+
 	return &v1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      workspaceObj.Name,
@@ -51,6 +76,7 @@ func GenerateServiceManifest(ctx context.Context, workspaceObj *kdmv1alpha1.Work
 					Kind:       "Workspace",
 					UID:        workspaceObj.UID,
 					Name:       workspaceObj.Name,
+					Controller: lo.ToPtr(true),
 				},
 			},
 		},
@@ -60,10 +86,57 @@ func GenerateServiceManifest(ctx context.Context, workspaceObj *kdmv1alpha1.Work
 				{
 					Protocol:   v1.ProtocolTCP,
 					Port:       80,
-					TargetPort: intstr.FromInt(5000),
+					TargetPort: intstr.FromInt(int(GetTargetPort(workspaceObj))),
+				},
+			},
+			Selector: selector,
+		},
+	}
+}
+
+// GetTargetPort returns the first container port of the custom inference template, or 5000 which is used by preset models.
+func GetTargetPort(workspaceObj *kdmv1alpha1.Workspace) int32 {
+	if workspaceObj.Inference.Template != nil {
+		for _, container := range workspaceObj.Inference.Template.Spec.Containers {
+			if len(container.Ports) != 0 {
+				return container.Ports[0].ContainerPort
+			}
+		}
+	}
+	return 5000
+}
+
+// GenerateHeadlessServiceManifest generates a headless service that gives the selected pods stable DNS names.
+func GenerateHeadlessServiceManifest(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, serviceName string,
+	selector map[string]string, port int32) *v1.Service {
+	klog.InfoS("GenerateHeadlessServiceManifest", "workspace", klog.KObj(workspaceObj), "service", serviceName)
+
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      serviceName,
+			Namespace: workspaceObj.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: kdmv1alpha1.GroupVersion.String(),
+					Kind:       "Workspace",
+					UID:        workspaceObj.UID,
+					Name:       workspaceObj.Name,
+					Controller: lo.ToPtr(true),
+				},
+			},
+		},
+		Spec: v1.ServiceSpec{
+			ClusterIP: v1.ClusterIPNone,
+			Ports: []v1.ServicePort{
+				{
+					Protocol:   v1.ProtocolTCP,
+					Port:       port,
+					TargetPort: intstr.FromInt(int(port)),
 				},
 			},
-			Selector: workspaceObj.Resource.LabelSelector.MatchLabels,
+			Selector: selector,
+			// The rendezvous endpoint must resolve before the pods are ready.
+			PublishNotReadyAddresses: true,
 		},
 	}
 }
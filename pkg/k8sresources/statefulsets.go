@@ -0,0 +1,132 @@
+package k8sresources
+
+import (
+	"context"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/samber/lo"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func CreateStatefulSet(ctx context.Context, statefulSetObj *appsv1.StatefulSet, kubeClient client.Client) error {
+	klog.InfoS("CreateStatefulSet", "statefulset", klog.KObj(statefulSetObj))
+	return retry.OnError(retry.DefaultBackoff, func(err error) bool {
+		return true
+	}, func() error {
+		return kubeClient.Create(ctx, statefulSetObj, &client.CreateOptions{})
+	})
+}
+
+func GetStatefulSet(ctx context.Context, name, namespace string, kubeClient client.Client) (*appsv1.StatefulSet, error) {
+	klog.InfoS("GetStatefulSet", "statefulSetName", name, "statefulSetNamespace", namespace)
+
+	sts := &appsv1.StatefulSet{}
+	err := kubeClient.Get(ctx, client.ObjectKey{Name: name, Namespace: namespace}, sts, &client.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return sts, nil
+}
+
+func DeleteStatefulSet(ctx context.Context, statefulSetObj *appsv1.StatefulSet, kubeClient client.Client) error {
+	klog.InfoS("DeleteStatefulSet", "statefulset", klog.KObj(statefulSetObj))
+	return retry.OnError(retry.DefaultBackoff, func(err error) bool {
+		return !apierrors.IsNotFound(err)
+	}, func() error {
+		return kubeClient.Delete(ctx, statefulSetObj, &client.DeleteOptions{
+			PropagationPolicy: lo.ToPtr(v1.DeletePropagationForeground),
+		})
+	})
+}
+
+// GenerateStatefulSetManifest generates a statefulset running one pod on each of the given nodes.
+// The pods get stable host names <workspace name>-<ordinal> under the given headless service.
+func GenerateStatefulSetManifest(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, imageName, serviceName string,
+	nodeNames []string, commands []string, containerPorts []corev1.ContainerPort,
+	livenessProbe, readinessProbe *corev1.Probe, resourceRequirements corev1.ResourceRequirements,
+	volumeMount []corev1.VolumeMount, tolerations []corev1.Toleration, volumes []corev1.Volume) *appsv1.StatefulSet {
+	klog.InfoS("GenerateStatefulSetManifest", "workspace", klog.KObj(workspaceObj), "image", imageName)
+
+	podLabels := generatePodLabels(workspaceObj, nil)
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: v1.ObjectMeta{
+			Labels: podLabels,
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:           workspaceObj.Name,
+					Image:          imageName,
+					Command:        commands,
+					Resources:      resourceRequirements,
+					LivenessProbe:  livenessProbe,
+					ReadinessProbe: readinessProbe,
+					Ports:          containerPorts,
+					VolumeMounts:   volumeMount,
+				},
+			},
+			Tolerations:  tolerations,
+			Volumes:      volumes,
+			NodeSelector: workspaceObj.Resource.LabelSelector.MatchLabels,
+			Affinity: &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+						NodeSelectorTerms: []corev1.NodeSelectorTerm{
+							{
+								MatchFields: []corev1.NodeSelectorRequirement{
+									{
+										Key:      v1.ObjectNameField,
+										Operator: corev1.NodeSelectorOpIn,
+										Values:   nodeNames,
+									},
+								},
+							},
+						},
+					},
+				},
+				PodAntiAffinity: &corev1.PodAntiAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
+						{
+							LabelSelector: &v1.LabelSelector{
+								MatchLabels: podLabels,
+							},
+							TopologyKey: corev1.LabelHostname,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return &appsv1.StatefulSet{
+		ObjectMeta: v1.ObjectMeta{
+			Name:        workspaceObj.Name,
+			Namespace:   workspaceObj.Namespace,
+			Annotations: generateSpecHashAnnotations(template),
+			OwnerReferences: []v1.OwnerReference{
+				{
+					APIVersion: kdmv1alpha1.GroupVersion.String(),
+					Kind:       "Workspace",
+					UID:        workspaceObj.UID,
+					Name:       workspaceObj.Name,
+					Controller: lo.ToPtr(true),
+				},
+			},
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:    lo.ToPtr(int32(len(nodeNames))),
+			ServiceName: serviceName,
+			Selector:    workspaceObj.Resource.LabelSelector,
+			// All ranks must start together for the torchrun rendezvous to complete.
+			PodManagementPolicy: appsv1.ParallelPodManagement,
+			Template:            template,
+		},
+	}
+}
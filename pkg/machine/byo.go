@@ -0,0 +1,47 @@
+package machine
+
+import (
+	"context"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// byoProvisioner is used when the cluster brings its own GPU nodes. It never provisions nodes, workspaces only
+// run on the existing nodes that match their label selector and instance type.
+type byoProvisioner struct{}
+
+// Provision always fails, the missing nodes have to be added to the cluster.
+func (p *byoProvisioner) Provision(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace) (string, error) {
+	return "", errNodeProvisioningDisabled
+}
+
+// Status reports the nodes as ready, they are never provisioned.
+func (p *byoProvisioner) Status(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, node *ProvisionedNode) (MachinePhase, string, error) {
+	return MachinePhaseReady, "", nil
+}
+
+// Delete leaves the node in the cluster.
+func (p *byoProvisioner) Delete(ctx context.Context, node *ProvisionedNode) error {
+	return nil
+}
+
+// Retain leaves the node in the cluster.
+func (p *byoProvisioner) Retain(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, node *ProvisionedNode) error {
+	return nil
+}
+
+// Reassign does nothing, the node only needs to be relabeled.
+func (p *byoProvisioner) Reassign(ctx context.Context, from, to *kdmv1alpha1.Workspace, node *ProvisionedNode) error {
+	return nil
+}
+
+// ListForWorkspace returns no nodes, none are provisioned for workspaces.
+func (p *byoProvisioner) ListForWorkspace(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace) ([]*ProvisionedNode, error) {
+	return nil, nil
+}
+
+// WatchedObject returns nil, no objects are created for the nodes.
+func (p *byoProvisioner) WatchedObject() client.Object {
+	return nil
+}
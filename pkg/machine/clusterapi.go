@@ -0,0 +1,304 @@
+package machine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/utils"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// LabelMachineDeploymentTemplate marks the Cluster API MachineDeployments the workspace MachineDeployments are
+	// copied from. The template is selected by its node.kubernetes.io/instance-type label.
+	LabelMachineDeploymentTemplate = kdmv1alpha1.KDMPrefix + "machine-deployment-template"
+
+	LabelClusterName                = "cluster.x-k8s.io/cluster-name"
+	LabelMachineDeploymentName      = "cluster.x-k8s.io/deployment-name"
+	AnnotationDeleteMachine         = "cluster.x-k8s.io/delete-machine"
+	clusterAPIMachinePhaseRunning   = "Running"
+	clusterAPIMachinePhaseFailed    = "Failed"
+	clusterAPIMachineDeploymentHash = 8
+)
+
+var (
+	// MachineDeploymentGVK is the kind of the Cluster API MachineDeployments. The Cluster API is not vendored,
+	// its objects are handled as unstructured objects.
+	MachineDeploymentGVK = schema.GroupVersionKind{Group: "cluster.x-k8s.io", Version: "v1beta1", Kind: "MachineDeployment"}
+	// ClusterAPIMachineGVK is the kind of the Cluster API Machines.
+	ClusterAPIMachineGVK = schema.GroupVersionKind{Group: "cluster.x-k8s.io", Version: "v1beta1", Kind: "Machine"}
+)
+
+// clusterAPIProvisioner provisions nodes by scaling a Cluster API MachineDeployment of the workspace, which is copied
+// from the template MachineDeployment of the workspace instance type. The nodes get the workspace labels once
+// their machine is running.
+type clusterAPIProvisioner struct {
+	namespace  string
+	kubeClient client.Client
+}
+
+// machineDeploymentName returns the name of the MachineDeployment of the workspace for an instance type.
+func machineDeploymentName(workspaceObj *kdmv1alpha1.Workspace, instanceType string) string {
+	hash := sha256.Sum256([]byte(instanceType))
+	return fmt.Sprintf("%s-%s-%s", workspaceObj.Namespace, workspaceObj.Name,
+		hex.EncodeToString(hash[:])[:clusterAPIMachineDeploymentHash])
+}
+
+// getMachineDeploymentTemplate returns the template MachineDeployment of the instance type.
+func (p *clusterAPIProvisioner) getMachineDeploymentTemplate(ctx context.Context, instanceType string) (*unstructured.Unstructured, error) {
+	templateList := newUnstructuredList(MachineDeploymentGVK)
+	if err := p.kubeClient.List(ctx, templateList, client.InNamespace(p.namespace), client.MatchingLabels{
+		LabelMachineDeploymentTemplate: "true",
+		v1.LabelInstanceTypeStable:     instanceType,
+	}); err != nil {
+		return nil, err
+	}
+	if len(templateList.Items) == 0 {
+		return nil, fmt.Errorf("no MachineDeployment template for instance type %s in namespace %s",
+			instanceType, p.namespace)
+	}
+	return &templateList.Items[0], nil
+}
+
+// GenerateMachineDeploymentManifest generates the MachineDeployment of the workspace from the template MachineDeployment
+// of an instance type.
+func GenerateMachineDeploymentManifest(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, instanceType string,
+	templateObj *unstructured.Unstructured) *unstructured.Unstructured {
+	klog.InfoS("GenerateMachineDeploymentManifest", "workspace", klog.KObj(workspaceObj), "template", klog.KObj(templateObj))
+
+	name := machineDeploymentName(workspaceObj, instanceType)
+	clusterName, _, _ := unstructured.NestedString(templateObj.Object, "spec", "clusterName")
+	selectorLabels := map[string]string{
+		LabelClusterName:           clusterName,
+		LabelMachineDeploymentName: name,
+	}
+	workspaceLabels := generateWorkspaceLabels(workspaceObj)
+
+	mdObj := newUnstructured(MachineDeploymentGVK)
+	mdObj.SetName(name)
+	mdObj.SetNamespace(templateObj.GetNamespace())
+	// The workspace lives in another namespace, so it cannot own the MachineDeployment; it is deleted by Delete.
+	mdObj.SetLabels(lo.Assign(workspaceLabels, map[string]string{
+		LabelClusterName:           clusterName,
+		v1.LabelInstanceTypeStable: instanceType,
+	}))
+
+	spec, _, _ := unstructured.NestedMap(templateObj.Object, "spec")
+	templateLabels, _, _ := unstructured.NestedStringMap(spec, "template", "metadata", "labels")
+	_ = unstructured.SetNestedField(spec, int64(0), "replicas")
+	_ = unstructured.SetNestedStringMap(spec, selectorLabels, "selector", "matchLabels")
+	_ = unstructured.SetNestedStringMap(spec, lo.Assign(templateLabels, workspaceLabels, selectorLabels), "template", "metadata", "labels")
+	mdObj.Object["spec"] = spec
+	return mdObj
+}
+
+// Provision adds a replica to the MachineDeployment of the workspace instance type, creating it from the template if needed.
+func (p *clusterAPIProvisioner) Provision(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace) (string, error) {
+	instanceType := utils.GetInstanceType(workspaceObj)
+	mdObj := newUnstructured(MachineDeploymentGVK)
+	mdKey := client.ObjectKey{Name: machineDeploymentName(workspaceObj, instanceType), Namespace: p.namespace}
+	err := p.kubeClient.Get(ctx, mdKey, mdObj)
+	if apierrors.IsNotFound(err) {
+		templateObj, err := p.getMachineDeploymentTemplate(ctx, instanceType)
+		if err != nil {
+			return "", err
+		}
+		mdObj = GenerateMachineDeploymentManifest(ctx, workspaceObj, instanceType, templateObj)
+		klog.InfoS("CreateMachineDeployment", "machineDeployment", klog.KObj(mdObj))
+		if err := p.kubeClient.Create(ctx, mdObj, &client.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+			return "", err
+		}
+	} else if err != nil {
+		return "", err
+	}
+
+	replicas, err := p.scaleMachineDeployment(ctx, mdKey, 1)
+	if err != nil {
+		return "", err
+	}
+	// The machine of the new replica is created later by Cluster API.
+	return pendingReplicaName(mdKey.Name, replicas), nil
+}
+
+// pendingReplicaName returns the name a replica of the MachineDeployment is tracked by until it has a machine, so that
+// the pending replicas of a MachineDeployment have different names.
+func pendingReplicaName(mdName string, replica int64) string {
+	return fmt.Sprintf("%s-replica-%d", mdName, replica)
+}
+
+// scaleMachineDeployment changes the replicas of the MachineDeployment by delta and returns the new number of
+// replicas. The MachineDeployment is deleted once it has no replicas left.
+func (p *clusterAPIProvisioner) scaleMachineDeployment(ctx context.Context, mdKey client.ObjectKey, delta int64) (int64, error) {
+	klog.InfoS("ScaleMachineDeployment", "machineDeployment", mdKey, "delta", delta)
+	mdObj := newUnstructured(MachineDeploymentGVK)
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		if err := p.kubeClient.Get(ctx, mdKey, mdObj); err != nil {
+			return err
+		}
+		replicas, _, _ := unstructured.NestedInt64(mdObj.Object, "spec", "replicas")
+		if err := unstructured.SetNestedField(mdObj.Object, lo.Max([]int64{replicas + delta, 0}), "spec", "replicas"); err != nil {
+			return err
+		}
+		return p.kubeClient.Update(ctx, mdObj, &client.UpdateOptions{})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	replicas, _, _ := unstructured.NestedInt64(mdObj.Object, "spec", "replicas")
+	if replicas == 0 {
+		klog.InfoS("DeleteMachineDeployment", "machineDeployment", mdKey)
+		return 0, client.IgnoreNotFound(p.kubeClient.Delete(ctx, mdObj, &client.DeleteOptions{}))
+	}
+	return replicas, nil
+}
+
+// Status returns the provisioning phase of a Cluster API machine and, for failed machines, the reason. The node of a
+// running machine is labeled for the workspace before it is reported ready.
+func (p *clusterAPIProvisioner) Status(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, node *ProvisionedNode) (MachinePhase, string, error) {
+	machineObj := node.Object.(*unstructured.Unstructured)
+	// A replica that has no machine yet is tracked by its MachineDeployment.
+	if machineObj.GroupVersionKind() != ClusterAPIMachineGVK {
+		return MachinePhaseProvisioning, "", nil
+	}
+
+	phase, _, _ := unstructured.NestedString(machineObj.Object, "status", "phase")
+	switch {
+	case phase == clusterAPIMachinePhaseFailed:
+		message, _, _ := unstructured.NestedString(machineObj.Object, "status", "failureMessage")
+		return MachinePhaseFailed, message, nil
+	case phase == clusterAPIMachinePhaseRunning && node.NodeName != "":
+		if err := p.labelNode(ctx, workspaceObj, node.NodeName); err != nil {
+			return "", "", err
+		}
+		return MachinePhaseReady, "", nil
+	default:
+		return MachinePhaseProvisioning, "", nil
+	}
+}
+
+// labelNode adds the workspace labels to the node, so that it matches the workspace label selector.
+func (p *clusterAPIProvisioner) labelNode(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, nodeName string) error {
+	nodeObj := &v1.Node{}
+	if err := p.kubeClient.Get(ctx, client.ObjectKey{Name: nodeName}, nodeObj); err != nil {
+		return err
+	}
+	workspaceLabels := generateWorkspaceLabels(workspaceObj)
+	if lo.EveryBy(lo.Entries(workspaceLabels), func(entry lo.Entry[string, string]) bool {
+		return nodeObj.Labels[entry.Key] == entry.Value
+	}) {
+		return nil
+	}
+	klog.InfoS("LabelNode", "node", nodeName, "workspace", klog.KObj(workspaceObj))
+	patch := client.MergeFrom(nodeObj.DeepCopy())
+	nodeObj.Labels = lo.Assign(nodeObj.Labels, workspaceLabels)
+	return p.kubeClient.Patch(ctx, nodeObj, patch)
+}
+
+// Delete marks a Cluster API machine for deletion and scales its MachineDeployment down, so that exactly this
+// machine is removed.
+func (p *clusterAPIProvisioner) Delete(ctx context.Context, node *ProvisionedNode) error {
+	machineObj := node.Object.(*unstructured.Unstructured)
+	if machineObj.GroupVersionKind() == MachineDeploymentGVK {
+		_, err := p.scaleMachineDeployment(ctx, client.ObjectKeyFromObject(machineObj), -1)
+		return err
+	}
+
+	klog.InfoS("DeleteClusterAPIMachine", "machine", klog.KObj(machineObj))
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		if err := p.kubeClient.Get(ctx, client.ObjectKeyFromObject(machineObj), machineObj); err != nil {
+			return err
+		}
+		machineObj.SetAnnotations(lo.Assign(machineObj.GetAnnotations(), map[string]string{AnnotationDeleteMachine: "true"}))
+		return p.kubeClient.Update(ctx, machineObj, &client.UpdateOptions{})
+	})
+	if err != nil {
+		return err
+	}
+	_, err = p.scaleMachineDeployment(ctx, client.ObjectKey{
+		Name:      machineObj.GetLabels()[LabelMachineDeploymentName],
+		Namespace: machineObj.GetNamespace(),
+	}, -1)
+	return err
+}
+
+// Retain keeps the machines of the workspace. The MachineDeployments are not owned by the workspace, so
+// there is nothing to detach.
+func (p *clusterAPIProvisioner) Retain(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, node *ProvisionedNode) error {
+	return nil
+}
+
+// Reassign fails, the machines belong to a MachineDeployment of the workspace which keeps the replica count.
+func (p *clusterAPIProvisioner) Reassign(ctx context.Context, from, to *kdmv1alpha1.Workspace, node *ProvisionedNode) error {
+	return errReassignNotSupported
+}
+
+// ListForWorkspace lists the Cluster API machines of the MachineDeployments of the workspace. Replicas that do not
+// have a machine yet are listed as their MachineDeployment, so that they are not requested twice, each one under its
+// own name.
+func (p *clusterAPIProvisioner) ListForWorkspace(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace) ([]*ProvisionedNode, error) {
+	klog.InfoS("ListClusterAPIMachines", "workspace", klog.KObj(workspaceObj))
+	mdList := newUnstructuredList(MachineDeploymentGVK)
+	if err := p.kubeClient.List(ctx, mdList, client.InNamespace(p.namespace),
+		client.MatchingLabels{kdmv1alpha1.LabelWorkspaceName: workspaceObj.Name}); err != nil {
+		return nil, err
+	}
+
+	var nodes []*ProvisionedNode
+	for i := range mdList.Items {
+		mdObj := &mdList.Items[i]
+		if !belongsToWorkspace(mdObj, workspaceObj) {
+			continue
+		}
+		instanceType := mdObj.GetLabels()[v1.LabelInstanceTypeStable]
+
+		machineList := newUnstructuredList(ClusterAPIMachineGVK)
+		if err := p.kubeClient.List(ctx, machineList, client.InNamespace(p.namespace),
+			client.MatchingLabels{LabelMachineDeploymentName: mdObj.GetName()}); err != nil {
+			return nil, err
+		}
+		activeMachines := int64(0)
+		for j := range machineList.Items {
+			machineObj := &machineList.Items[j]
+			nodeName, _, _ := unstructured.NestedString(machineObj.Object, "status", "nodeRef", "name")
+			_, markedForDeletion := machineObj.GetAnnotations()[AnnotationDeleteMachine]
+			deleting := machineObj.GetDeletionTimestamp() != nil || markedForDeletion
+			if !deleting {
+				activeMachines++
+			}
+			nodes = append(nodes, &ProvisionedNode{
+				Name:         machineObj.GetName(),
+				InstanceType: instanceType,
+				NodeName:     nodeName,
+				Deleting:     deleting,
+				Object:       machineObj,
+			})
+		}
+
+		replicas, _, _ := unstructured.NestedInt64(mdObj.Object, "spec", "replicas")
+		for j := activeMachines; j < replicas; j++ {
+			nodes = append(nodes, &ProvisionedNode{
+				Name:         pendingReplicaName(mdObj.GetName(), j+1),
+				InstanceType: instanceType,
+				Object:       mdObj,
+			})
+		}
+	}
+	return nodes, nil
+}
+
+// WatchedObject returns an empty Cluster API machine. The machines get the workspace labels from the template of
+// their MachineDeployment.
+func (p *clusterAPIProvisioner) WatchedObject() client.Object {
+	return newUnstructured(ClusterAPIMachineGVK)
+}
@@ -0,0 +1,236 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/k8sresources"
+	"github.com/kdm/pkg/sku"
+	"github.com/kdm/pkg/utils"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// LabelFakeNode marks the nodes created by the fake provisioner.
+	LabelFakeNode = kdmv1alpha1.KDMPrefix + "fake-node"
+	// AnnotationKwokNode hands the fake nodes over to kwok, which keeps them ready and runs their pods.
+	AnnotationKwokNode = "kwok.x-k8s.io/node"
+	// DefaultFakeNodeDelay is how long the fake provisioner takes to provision a node by default.
+	DefaultFakeNodeDelay = 10 * time.Second
+)
+
+// fakeProvisioner creates fake GPU nodes, so that workspaces can be run on kind or envtest without a cloud or GPUs.
+// The nodes are requested in memory and created once the delay has elapsed. On kind, the nodes are only kept ready
+// if kwok is installed; on envtest, no controller marks them not ready.
+type fakeProvisioner struct {
+	delay         time.Duration
+	instanceTypes *sku.CatalogSource
+	clock         clock.PassiveClock
+	kubeClient    client.Client
+
+	mu sync.Mutex
+	// requested holds the nodes that have been provisioned but not created yet, keyed by node name.
+	requested map[string]*fakeNodeRequest
+}
+
+type fakeNodeRequest struct {
+	workspace    client.ObjectKey
+	instanceType string
+	labels       map[string]string
+	requestedAt  time.Time
+}
+
+func newFakeProvisioner(delay time.Duration, instanceTypes *sku.CatalogSource, kubeClient client.Client) *fakeProvisioner {
+	return &fakeProvisioner{
+		delay:         delay,
+		instanceTypes: instanceTypes,
+		clock:         clock.RealClock{},
+		kubeClient:    kubeClient,
+		requested:     map[string]*fakeNodeRequest{},
+	}
+}
+
+// GenerateFakeNodeManifest generates a ready node with the GPUs of the instance type in the catalog, the nvidia
+// accelerator label and the instance type label.
+func GenerateFakeNodeManifest(nodeName, instanceType string, catalog *sku.Catalog, workspaceLabels map[string]string) *v1.Node {
+	gpuCount := 1
+	if t, found := catalog.Get(instanceType); found {
+		gpuCount = t.GPUCount
+	}
+	capacity := v1.ResourceList{
+		v1.ResourceCPU:              resource.MustParse("32"),
+		v1.ResourceMemory:           resource.MustParse("256Gi"),
+		v1.ResourceEphemeralStorage: resource.MustParse("1Ti"),
+		v1.ResourcePods:             resource.MustParse("110"),
+		v1.ResourceName(k8sresources.CapacityNvidiaGPU): resource.MustParse(strconv.Itoa(gpuCount)),
+	}
+
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: nodeName,
+			Labels: lo.Assign(workspaceLabels, map[string]string{
+				LabelFakeNode:                             "true",
+				v1.LabelHostname:                          nodeName,
+				v1.LabelInstanceTypeStable:                instanceType,
+				v1.LabelArchStable:                        "amd64",
+				v1.LabelOSStable:                          "linux",
+				k8sresources.LabelKeyNvidia:               k8sresources.LabelValueNvidia,
+				k8sresources.LabelKeyCustomGPUProvisioner: "fake",
+			}),
+			Annotations: map[string]string{
+				AnnotationKwokNode: "fake",
+			},
+		},
+		Spec: v1.NodeSpec{
+			Taints: []v1.Taint{
+				{
+					Key:    "sku",
+					Value:  GPUString,
+					Effect: v1.TaintEffectNoSchedule,
+				},
+			},
+		},
+		Status: v1.NodeStatus{
+			Capacity:    capacity,
+			Allocatable: capacity,
+			Conditions: []v1.NodeCondition{
+				{
+					Type:               v1.NodeReady,
+					Status:             v1.ConditionTrue,
+					Reason:             "KubeletReady",
+					Message:            "fake node is ready",
+					LastHeartbeatTime:  metav1.Now(),
+					LastTransitionTime: metav1.Now(),
+				},
+			},
+			Phase: v1.NodeRunning,
+		},
+	}
+}
+
+// Provision requests a fake node for the workspace. The node is created by Status once the delay has elapsed.
+func (p *fakeProvisioner) Provision(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	nodeName := fmt.Sprint("fakenode", rand.Intn(100_000))
+	klog.InfoS("ProvisionFakeNode", "workspace", klog.KObj(workspaceObj), "node", nodeName, "delay", p.delay)
+	p.requested[nodeName] = &fakeNodeRequest{
+		workspace:    client.ObjectKeyFromObject(workspaceObj),
+		instanceType: utils.GetInstanceType(workspaceObj),
+		labels:       generateWorkspaceLabels(workspaceObj),
+		requestedAt:  p.clock.Now(),
+	}
+	return nodeName, nil
+}
+
+// Status creates the fake node once the delay has elapsed and reports it ready.
+func (p *fakeProvisioner) Status(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, node *ProvisionedNode) (MachinePhase, string, error) {
+	if node.NodeName != "" {
+		return MachinePhaseReady, "", nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	request, found := p.requested[node.Name]
+	if !found || p.clock.Since(request.requestedAt) < p.delay {
+		return MachinePhaseProvisioning, "", nil
+	}
+
+	catalog, err := p.instanceTypes.Load(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	nodeObj := GenerateFakeNodeManifest(node.Name, request.instanceType, catalog, request.labels)
+	status := nodeObj.Status
+	klog.InfoS("CreateFakeNode", "node", nodeObj.Name, "workspace", request.workspace)
+	if err := p.kubeClient.Create(ctx, nodeObj, &client.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return "", "", err
+	}
+	// The status is not set on create.
+	nodeObj.Status = status
+	if err := p.kubeClient.Status().Update(ctx, nodeObj); err != nil {
+		return "", "", err
+	}
+	delete(p.requested, node.Name)
+	return MachinePhaseReady, "", nil
+}
+
+// Delete deletes the fake node, or forgets it if it has not been created yet.
+func (p *fakeProvisioner) Delete(ctx context.Context, node *ProvisionedNode) error {
+	p.mu.Lock()
+	delete(p.requested, node.Name)
+	p.mu.Unlock()
+
+	if node.Object == nil {
+		return nil
+	}
+	klog.InfoS("DeleteFakeNode", "node", node.Name)
+	return p.kubeClient.Delete(ctx, node.Object, &client.DeleteOptions{})
+}
+
+// Retain keeps the fake node, it is not owned by the workspace.
+func (p *fakeProvisioner) Retain(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, node *ProvisionedNode) error {
+	return nil
+}
+
+// Reassign does nothing, the fake nodes are listed by the workspace labels of the node which is relabeled.
+func (p *fakeProvisioner) Reassign(ctx context.Context, from, to *kdmv1alpha1.Workspace, node *ProvisionedNode) error {
+	return nil
+}
+
+// ListForWorkspace lists the fake nodes of the workspace, including the ones that have not been created yet.
+func (p *fakeProvisioner) ListForWorkspace(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace) ([]*ProvisionedNode, error) {
+	nodeList := &v1.NodeList{}
+	if err := p.kubeClient.List(ctx, nodeList, client.MatchingLabels{
+		LabelFakeNode:                  "true",
+		kdmv1alpha1.LabelWorkspaceName: workspaceObj.Name,
+	}); err != nil {
+		return nil, err
+	}
+
+	var nodes []*ProvisionedNode
+	for i := range nodeList.Items {
+		nodeObj := &nodeList.Items[i]
+		if !belongsToWorkspace(nodeObj, workspaceObj) {
+			continue
+		}
+		nodes = append(nodes, &ProvisionedNode{
+			Name:         nodeObj.Name,
+			InstanceType: nodeObj.Labels[v1.LabelInstanceTypeStable],
+			NodeName:     nodeObj.Name,
+			Deleting:     !nodeObj.DeletionTimestamp.IsZero(),
+			Object:       nodeObj,
+		})
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for nodeName, request := range p.requested {
+		if request.workspace != client.ObjectKeyFromObject(workspaceObj) {
+			continue
+		}
+		nodes = append(nodes, &ProvisionedNode{
+			Name:         nodeName,
+			InstanceType: request.instanceType,
+		})
+	}
+	return nodes, nil
+}
+
+// WatchedObject returns nil, the fake nodes are created while the workspace is reconciled.
+func (p *fakeProvisioner) WatchedObject() client.Object {
+	return nil
+}
@@ -4,46 +4,43 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
-	"time"
 
 	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
 	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/utils"
 	"github.com/samber/lo"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
-	"k8s.io/utils/clock"
 	"knative.dev/pkg/apis"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
-	ProvisionerName               = "default"
+	// DefaultProvisionerName is the Karpenter provisioner the machines are created for by default.
+	DefaultProvisionerName        = "default"
 	LabelGPUProvisionerCustom     = "gpu-provisioner.sh/machine-type"
 	LabelProvisionerName          = "karpenter.sh/provisioner-name"
 	GPUString                     = "gpu"
 	ErrorInstanceTypesUnavailable = "all requested instance types were unavailable during launch"
 )
 
-var (
-	//	machineStatusCheckInterval is the interval to check the machine status.
-	machineStatusCheckInterval = 180 * time.Second
-)
+// machineProvisioner provisions nodes with karpenter-core v1alpha5 Machine objects.
+type machineProvisioner struct {
+	provisionerName string
+	kubeClient      client.Client
+}
 
 // GenerateMachineManifest generates a machine object from	the given workspace.
-func GenerateMachineManifest(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace) *v1alpha5.Machine {
+func GenerateMachineManifest(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, provisionerName string) *v1alpha5.Machine {
 	klog.InfoS("GenerateMachineManifest", "workspace", klog.KObj(workspaceObj))
 
 	machineName := fmt.Sprint("machine", rand.Intn(100_000))
-	machineLabels := map[string]string{
-		LabelProvisionerName:           ProvisionerName,
-		kdmv1alpha1.LabelWorkspaceName: workspaceObj.Name,
-	}
-	if workspaceObj.Resource.LabelSelector != nil &&
-		len(workspaceObj.Resource.LabelSelector.MatchLabels) != 0 {
-		machineLabels = lo.Assign(machineLabels, workspaceObj.Resource.LabelSelector.MatchLabels)
-	}
+	machineLabels := lo.Assign(generateWorkspaceLabels(workspaceObj), map[string]string{
+		LabelProvisionerName: provisionerName,
+	})
 
 	return &v1alpha5.Machine{
 		ObjectMeta: metav1.ObjectMeta{
@@ -67,12 +64,12 @@ func GenerateMachineManifest(ctx context.Context, workspaceObj *kdmv1alpha1.Work
 				{
 					Key:      v1.LabelInstanceTypeStable,
 					Operator: v1.NodeSelectorOpIn,
-					Values:   []string{workspaceObj.Resource.InstanceType},
+					Values:   []string{utils.GetInstanceType(workspaceObj)},
 				},
 				{
 					Key:      LabelProvisionerName,
 					Operator: v1.NodeSelectorOpIn,
-					Values:   []string{ProvisionerName},
+					Values:   []string{provisionerName},
 				},
 				{
 					Key:      LabelGPUProvisionerCustom,
@@ -101,128 +98,106 @@ func GenerateMachineManifest(ctx context.Context, workspaceObj *kdmv1alpha1.Work
 	}
 }
 
-// CreateMachine creates a machine object.
-func CreateMachine(ctx context.Context, machineObj *v1alpha5.Machine, kubeClient client.Client) error {
+// Provision creates a machine for the workspace. It does not wait for the machine to be launched.
+func (p *machineProvisioner) Provision(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace) (string, error) {
+	machineObj := GenerateMachineManifest(ctx, workspaceObj, p.provisionerName)
 	klog.InfoS("CreateMachine", "machine", klog.KObj(machineObj))
-	return retry.OnError(retry.DefaultBackoff, func(err error) bool {
-		return err.Error() != ErrorInstanceTypesUnavailable
+	err := retry.OnError(retry.DefaultBackoff, func(err error) bool {
+		return !apierrors.IsAlreadyExists(err)
 	}, func() error {
-		err := kubeClient.Create(ctx, machineObj, &client.CreateOptions{})
-		if err != nil {
-			return err
-		}
-		time.Sleep(1 * time.Second)
-
-		updatedObj := &v1alpha5.Machine{}
-		err = kubeClient.Get(ctx, client.ObjectKey{Name: machineObj.Name, Namespace: machineObj.Namespace}, updatedObj, &client.GetOptions{})
-
-		// if SKU is not available, then exit.
-		_, conditionFound := lo.Find(updatedObj.GetConditions(), func(condition apis.Condition) bool {
-			return condition.Type == v1alpha5.MachineLaunched &&
-				condition.Status == v1.ConditionFalse && condition.Message == ErrorInstanceTypesUnavailable
-		})
-		if conditionFound {
-			klog.Error(ErrorInstanceTypesUnavailable, "reconcile will not continue")
-			return fmt.Errorf(ErrorInstanceTypesUnavailable)
-		}
-		return err
+		return p.kubeClient.Create(ctx, machineObj, &client.CreateOptions{})
 	})
+	if err != nil {
+		return "", err
+	}
+	return machineObj.Name, nil
 }
 
-// CheckOngoingProvisioningMachines Check if the there are any machines in provisioning condition. If so, then subtract them from the remaining nodes we need to create.
-func CheckOngoingProvisioningMachines(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, kubeClient client.Client) (int, error) {
-	klog.InfoS("CheckOngoingProvisioningMachines", "workspace", klog.KObj(workspaceObj))
-	machines, err := ListMachines(ctx, workspaceObj, kubeClient)
-	if err != nil {
-		return 0, err
+// Status returns the provisioning phase of the machine and, for failed machines, the reason.
+func (p *machineProvisioner) Status(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, node *ProvisionedNode) (MachinePhase, string, error) {
+	machineObj := node.Object.(*v1alpha5.Machine)
+	// if SKU is not available, the machine will never be launched.
+	_, launchFailed := lo.Find(machineObj.GetConditions(), func(condition apis.Condition) bool {
+		return condition.Type == v1alpha5.MachineLaunched &&
+			condition.Status == v1.ConditionFalse && condition.Message == ErrorInstanceTypesUnavailable
+	})
+	if launchFailed {
+		return MachinePhaseFailed, ErrorInstanceTypesUnavailable, nil
 	}
 
-	machinesProvisioningCount := 0
-	for i := range machines.Items {
-		// check if the machine is being created for the requested workspace.
-		if machines.Items[i].ObjectMeta.Labels != nil {
-			labels := machines.Items[i].ObjectMeta.Labels
-			if val, exists := labels[kdmv1alpha1.LabelWorkspaceName]; exists && val == workspaceObj.Name {
-
-				// check if the machine is being created has the requested workspace instance type.
-				_, machineInstanceType := lo.Find(machines.Items[i].Spec.Requirements, func(requirement v1.NodeSelectorRequirement) bool {
-					return requirement.Key == v1.LabelInstanceTypeStable &&
-						requirement.Operator == v1.NodeSelectorOpIn &&
-						lo.Contains(requirement.Values, workspaceObj.Resource.InstanceType)
-				})
-				if machineInstanceType {
-					_, found := lo.Find(machines.Items[i].GetConditions(), func(condition apis.Condition) bool {
-						return condition.Type == v1alpha5.MachineInitialized && condition.Status == v1.ConditionFalse
-					})
-
-					if found || machines.Items[i].GetConditions() == nil { // checking conditions==nil is a workaround for conditions delaying to set on the machine object.
-						//wait until	machine is initialized.
-						err := CheckMachineStatus(ctx, &machines.Items[i], kubeClient)
-						if err != nil {
-							return 0, err
-						}
-						machinesProvisioningCount++
-					}
-				}
-
-			}
-		}
+	_, ready := lo.Find(machineObj.GetConditions(), func(condition apis.Condition) bool {
+		return condition.Type == apis.ConditionReady && condition.Status == v1.ConditionTrue
+	})
+	if ready && machineObj.Status.NodeName != "" {
+		return MachinePhaseReady, "", nil
 	}
-	return machinesProvisioningCount, nil
+	return MachinePhaseProvisioning, "", nil
 }
 
-// ListMachines list all machine	objects in the cluster.
-func ListMachines(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, kubeClient client.Client) (*v1alpha5.MachineList, error) {
+// Delete deletes a machine object, which releases the node it provisioned.
+func (p *machineProvisioner) Delete(ctx context.Context, node *ProvisionedNode) error {
+	klog.InfoS("DeleteMachine", "machine", klog.KObj(node.Object))
+	return retry.OnError(retry.DefaultBackoff, func(err error) bool {
+		return !apierrors.IsNotFound(err)
+	}, func() error {
+		return p.kubeClient.Delete(ctx, node.Object, &client.DeleteOptions{})
+	})
+}
+
+// Retain removes the workspace owner reference from the machine.
+func (p *machineProvisioner) Retain(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, node *ProvisionedNode) error {
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		return removeWorkspaceOwnerReference(ctx, node.Object, workspaceObj, p.kubeClient)
+	})
+}
+
+// Reassign moves the machine to another workspace by replacing its workspace labels and owner reference.
+func (p *machineProvisioner) Reassign(ctx context.Context, from, to *kdmv1alpha1.Workspace, node *ProvisionedNode) error {
+	klog.InfoS("ReassignMachine", "machine", klog.KObj(node.Object), "from", klog.KObj(from), "to", klog.KObj(to))
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		return reassignWorkspaceObject(ctx, node.Object, from, to, p.kubeClient)
+	})
+}
+
+// ListForWorkspace lists the machine objects created for the workspace.
+func (p *machineProvisioner) ListForWorkspace(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace) ([]*ProvisionedNode, error) {
 	klog.InfoS("ListMachines", "workspace", klog.KObj(workspaceObj))
 	machineList := &v1alpha5.MachineList{}
 
 	err := retry.OnError(retry.DefaultBackoff, func(err error) bool {
 		return true
 	}, func() error {
-		return kubeClient.List(ctx, machineList, &client.ListOptions{})
+		return p.kubeClient.List(ctx, machineList, client.MatchingLabels{kdmv1alpha1.LabelWorkspaceName: workspaceObj.Name})
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	return machineList, nil
-}
-
-// CheckMachineStatus checks the status of the machine. If the machine is not ready, then it will wait for the machine to be ready.
-// If the machine is not ready after the timeout, then it will return an error.
-// if	the machine is ready, then it will return nil.
-func CheckMachineStatus(ctx context.Context, machineObj *v1alpha5.Machine, kubeClient client.Client) error {
-	klog.InfoS("CheckMachineStatus", "machine", klog.KObj(machineObj))
-	timeClock := clock.RealClock{}
-	tick := timeClock.NewTicker(machineStatusCheckInterval)
-	defer tick.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-
-		case <-tick.C():
-			return fmt.Errorf("check machine status timed out. machine %s is not ready", machineObj.Name)
-
-		default:
-			time.Sleep(1 * time.Second)
-			err := kubeClient.Get(ctx, client.ObjectKey{Name: machineObj.Name, Namespace: machineObj.Namespace}, machineObj, &client.GetOptions{})
-			if err != nil {
-				return err
-			}
-
-			// if machine is not ready, then continue.
-			_, conditionFound := lo.Find(machineObj.GetConditions(), func(condition apis.Condition) bool {
-				return condition.Type == apis.ConditionReady &&
-					condition.Status == v1.ConditionTrue
-			})
-			if !conditionFound {
-				continue
-			}
-
-			klog.InfoS("machine status is ready", "machine", machineObj.Name)
-			return nil
+	var nodes []*ProvisionedNode
+	for i := range machineList.Items {
+		machineObj := &machineList.Items[i]
+		if !belongsToWorkspace(machineObj, workspaceObj) {
+			continue
 		}
+		instanceType := ""
+		if requirement, found := lo.Find(machineObj.Spec.Requirements, func(requirement v1.NodeSelectorRequirement) bool {
+			return requirement.Key == v1.LabelInstanceTypeStable && requirement.Operator == v1.NodeSelectorOpIn &&
+				len(requirement.Values) == 1
+		}); found {
+			instanceType = requirement.Values[0]
+		}
+		nodes = append(nodes, &ProvisionedNode{
+			Name:         machineObj.Name,
+			InstanceType: instanceType,
+			NodeName:     machineObj.Status.NodeName,
+			Deleting:     !machineObj.DeletionTimestamp.IsZero(),
+			Object:       machineObj,
+		})
 	}
+	return nodes, nil
+}
+
+// WatchedObject returns an empty machine.
+func (p *machineProvisioner) WatchedObject() client.Object {
+	return &v1alpha5.Machine{}
 }
@@ -0,0 +1,212 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/utils"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultNodePoolName is the Karpenter node pool the node claims are created for by default.
+	DefaultNodePoolName = "default"
+	// DefaultNodeClassName is the Karpenter node class referenced by the node claims by default.
+	DefaultNodeClassName = "default"
+	LabelNodePoolName    = "karpenter.sh/nodepool"
+
+	nodeClaimConditionLaunched          = "Launched"
+	nodeClaimConditionReady             = "Ready"
+	nodeClaimReasonInsufficientCapacity = "InsufficientCapacity"
+	nodeClaimReasonLaunchFailed         = "LaunchFailed"
+)
+
+// NodeClaimGVK is the kind of the Karpenter v1beta1 node claims. The Karpenter v1beta1 API is not vendored,
+// the node claims are handled as unstructured objects.
+var NodeClaimGVK = schema.GroupVersionKind{Group: "karpenter.sh", Version: "v1beta1", Kind: "NodeClaim"}
+
+// nodeClaimProvisioner provisions nodes with Karpenter v1beta1 NodeClaim objects.
+type nodeClaimProvisioner struct {
+	nodePoolName  string
+	nodeClassName string
+	kubeClient    client.Client
+}
+
+// GenerateNodeClaimManifest generates a node claim object from the given workspace.
+func GenerateNodeClaimManifest(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, nodePoolName, nodeClassName string) *unstructured.Unstructured {
+	klog.InfoS("GenerateNodeClaimManifest", "workspace", klog.KObj(workspaceObj))
+
+	nodeClaimObj := newUnstructured(NodeClaimGVK)
+	nodeClaimObj.SetName(fmt.Sprint("nodeclaim", rand.Intn(100_000)))
+	nodeClaimObj.SetLabels(lo.Assign(generateWorkspaceLabels(workspaceObj), map[string]string{
+		LabelNodePoolName: nodePoolName,
+	}))
+	nodeClaimObj.SetOwnerReferences([]metav1.OwnerReference{
+		{
+			APIVersion: kdmv1alpha1.GroupVersion.String(),
+			Kind:       "Workspace",
+			UID:        workspaceObj.UID,
+			Name:       workspaceObj.Name,
+		},
+	})
+	nodeClaimObj.Object["spec"] = map[string]interface{}{
+		"nodeClassRef": map[string]interface{}{
+			"name": nodeClassName,
+		},
+		"requirements": []interface{}{
+			nodeSelectorRequirement(v1.LabelInstanceTypeStable, utils.GetInstanceType(workspaceObj)),
+			nodeSelectorRequirement(LabelNodePoolName, nodePoolName),
+			nodeSelectorRequirement(LabelGPUProvisionerCustom, GPUString),
+			nodeSelectorRequirement(v1.LabelArchStable, "amd64"),
+			nodeSelectorRequirement(v1.LabelOSStable, "linux"),
+		},
+		"taints": []interface{}{
+			map[string]interface{}{
+				"key":    "sku",
+				"value":  GPUString,
+				"effect": string(v1.TaintEffectNoSchedule),
+			},
+		},
+	}
+	return nodeClaimObj
+}
+
+func nodeSelectorRequirement(key, value string) map[string]interface{} {
+	return map[string]interface{}{
+		"key":      key,
+		"operator": string(v1.NodeSelectorOpIn),
+		"values":   []interface{}{value},
+	}
+}
+
+// Provision creates a node claim for the workspace. It does not wait for the node claim to be launched.
+func (p *nodeClaimProvisioner) Provision(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace) (string, error) {
+	nodeClaimObj := GenerateNodeClaimManifest(ctx, workspaceObj, p.nodePoolName, p.nodeClassName)
+	klog.InfoS("CreateNodeClaim", "nodeClaim", klog.KObj(nodeClaimObj))
+	err := retry.OnError(retry.DefaultBackoff, func(err error) bool {
+		return !apierrors.IsAlreadyExists(err)
+	}, func() error {
+		return p.kubeClient.Create(ctx, nodeClaimObj, &client.CreateOptions{})
+	})
+	if err != nil {
+		return "", err
+	}
+	return nodeClaimObj.GetName(), nil
+}
+
+// Status returns the provisioning phase of the node claim and, for node claims that cannot be launched, the reason.
+func (p *nodeClaimProvisioner) Status(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, node *ProvisionedNode) (MachinePhase, string, error) {
+	nodeClaimObj := node.Object.(*unstructured.Unstructured)
+	conditions, _, _ := unstructured.NestedSlice(nodeClaimObj.Object, "status", "conditions")
+
+	var ready bool
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		conditionType, _ := condition["type"].(string)
+		status, _ := condition["status"].(string)
+		reason, _ := condition["reason"].(string)
+		message, _ := condition["message"].(string)
+		switch {
+		case conditionType == nodeClaimConditionLaunched && status == string(v1.ConditionFalse) &&
+			(reason == nodeClaimReasonInsufficientCapacity || reason == nodeClaimReasonLaunchFailed):
+			return MachinePhaseFailed, message, nil
+		case conditionType == nodeClaimConditionReady && status == string(v1.ConditionTrue):
+			ready = true
+		}
+	}
+	if ready && node.NodeName != "" {
+		return MachinePhaseReady, "", nil
+	}
+	return MachinePhaseProvisioning, "", nil
+}
+
+// Delete deletes a node claim, which releases the node it provisioned.
+func (p *nodeClaimProvisioner) Delete(ctx context.Context, node *ProvisionedNode) error {
+	klog.InfoS("DeleteNodeClaim", "nodeClaim", klog.KObj(node.Object))
+	return retry.OnError(retry.DefaultBackoff, func(err error) bool {
+		return !apierrors.IsNotFound(err)
+	}, func() error {
+		return p.kubeClient.Delete(ctx, node.Object, &client.DeleteOptions{})
+	})
+}
+
+// Retain removes the workspace owner reference from the node claim.
+func (p *nodeClaimProvisioner) Retain(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, node *ProvisionedNode) error {
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		return removeWorkspaceOwnerReference(ctx, node.Object, workspaceObj, p.kubeClient)
+	})
+}
+
+// Reassign moves the node claim to another workspace by replacing its workspace labels and owner reference.
+func (p *nodeClaimProvisioner) Reassign(ctx context.Context, from, to *kdmv1alpha1.Workspace, node *ProvisionedNode) error {
+	klog.InfoS("ReassignNodeClaim", "nodeClaim", klog.KObj(node.Object), "from", klog.KObj(from), "to", klog.KObj(to))
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		return reassignWorkspaceObject(ctx, node.Object, from, to, p.kubeClient)
+	})
+}
+
+// ListForWorkspace lists the node claims created for the workspace.
+func (p *nodeClaimProvisioner) ListForWorkspace(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace) ([]*ProvisionedNode, error) {
+	klog.InfoS("ListNodeClaims", "workspace", klog.KObj(workspaceObj))
+	nodeClaimList := newUnstructuredList(NodeClaimGVK)
+
+	err := retry.OnError(retry.DefaultBackoff, func(err error) bool {
+		return true
+	}, func() error {
+		return p.kubeClient.List(ctx, nodeClaimList, client.MatchingLabels{kdmv1alpha1.LabelWorkspaceName: workspaceObj.Name})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []*ProvisionedNode
+	for i := range nodeClaimList.Items {
+		nodeClaimObj := &nodeClaimList.Items[i]
+		if !belongsToWorkspace(nodeClaimObj, workspaceObj) {
+			continue
+		}
+		nodeName, _, _ := unstructured.NestedString(nodeClaimObj.Object, "status", "nodeName")
+		nodes = append(nodes, &ProvisionedNode{
+			Name:         nodeClaimObj.GetName(),
+			InstanceType: nodeClaimInstanceType(nodeClaimObj),
+			NodeName:     nodeName,
+			Deleting:     nodeClaimObj.GetDeletionTimestamp() != nil,
+			Object:       nodeClaimObj,
+		})
+	}
+	return nodes, nil
+}
+
+// nodeClaimInstanceType returns the instance type the node claim requires.
+func nodeClaimInstanceType(nodeClaimObj *unstructured.Unstructured) string {
+	requirements, _, _ := unstructured.NestedSlice(nodeClaimObj.Object, "spec", "requirements")
+	for _, r := range requirements {
+		requirement, ok := r.(map[string]interface{})
+		if !ok || requirement["key"] != v1.LabelInstanceTypeStable || requirement["operator"] != string(v1.NodeSelectorOpIn) {
+			continue
+		}
+		if values, ok := requirement["values"].([]interface{}); ok && len(values) == 1 {
+			instanceType, _ := values[0].(string)
+			return instanceType
+		}
+	}
+	return ""
+}
+
+// WatchedObject returns an empty node claim.
+func (p *nodeClaimProvisioner) WatchedObject() client.Object {
+	return newUnstructured(NodeClaimGVK)
+}
@@ -0,0 +1,223 @@
+package machine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/sku"
+	"github.com/kdm/pkg/utils"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ProvisionerKindMachine provisions nodes with karpenter-core v1alpha5 Machine objects.
+	ProvisionerKindMachine = "machine"
+	// ProvisionerKindNodeClaim provisions nodes with Karpenter v1beta1 NodeClaim objects.
+	ProvisionerKindNodeClaim = "nodeclaim"
+	// ProvisionerKindClusterAPI provisions nodes by scaling Cluster API MachineDeployments.
+	ProvisionerKindClusterAPI = "clusterapi"
+	// ProvisionerKindBYO never provisions nodes, workspaces only run on existing nodes that match their label selector.
+	ProvisionerKindBYO = "byo"
+	// ProvisionerKindFake creates fake GPU nodes for local development and tests.
+	ProvisionerKindFake = "fake"
+)
+
+// errNodeProvisioningDisabled is returned by Provision when the provisioner does not create nodes.
+var errNodeProvisioningDisabled = errors.New("node provisioning is disabled, the nodes of the workspace have to be added to the cluster")
+
+// IsNodeProvisioningDisabled returns true if the error means that the provisioner does not create nodes.
+func IsNodeProvisioningDisabled(err error) bool {
+	return errors.Is(err, errNodeProvisioningDisabled)
+}
+
+// errReassignNotSupported is returned by Reassign when the nodes of the provisioner cannot change workspace.
+var errReassignNotSupported = errors.New("the provisioned nodes cannot be reassigned to another workspace")
+
+// IsReassignNotSupported returns true if the error means that the provisioner cannot reassign nodes.
+func IsReassignNotSupported(err error) bool {
+	return errors.Is(err, errReassignNotSupported)
+}
+
+// NodeProvisioner provisions the GPU nodes the workspaces run on.
+type NodeProvisioner interface {
+	// Provision requests a new node for the workspace and returns the name of the object tracking it.
+	// It does not wait for the node to join the cluster, the progress is observed with Status.
+	Provision(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace) (string, error)
+	// Status returns the provisioning phase of a node and, for failed nodes, the reason.
+	Status(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, node *ProvisionedNode) (MachinePhase, string, error)
+	// Delete releases a node provisioned for a workspace.
+	Delete(ctx context.Context, node *ProvisionedNode) error
+	// Retain detaches a node from the workspace, so that deleting the workspace does not release it.
+	Retain(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, node *ProvisionedNode) error
+	// Reassign transfers a node provisioned for a workspace to another workspace, e.g., when it is preempted.
+	// The node itself is relabeled with ReassignNode.
+	Reassign(ctx context.Context, from, to *kdmv1alpha1.Workspace, node *ProvisionedNode) error
+	// ListForWorkspace lists the nodes provisioned for the workspace, including the ones being deleted.
+	ListForWorkspace(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace) ([]*ProvisionedNode, error)
+	// WatchedObject returns an empty object of the kind tracking the provisioned nodes, or nil if the provisioner
+	// does not create objects. The objects carry the workspace labels.
+	WatchedObject() client.Object
+}
+
+// ProvisionedNode is a node provisioned for a workspace.
+type ProvisionedNode struct {
+	// Name is the name of the object tracking the node, e.g., the machine.
+	Name string
+	// InstanceType is the instance type the node was requested with.
+	InstanceType string
+	// NodeName is the name of the node once it has joined the cluster.
+	NodeName string
+	// Deleting is true if the node is being released.
+	Deleting bool
+	// Object is the object tracking the node.
+	Object client.Object
+}
+
+// MachinePhase is the provisioning phase of a node provisioned for a workspace.
+type MachinePhase string
+
+const (
+	// MachinePhaseProvisioning means the node has been requested but it is not ready yet.
+	MachinePhaseProvisioning MachinePhase = "Provisioning"
+	// MachinePhaseReady means the node has joined the cluster and is ready.
+	MachinePhaseReady MachinePhase = "Ready"
+	// MachinePhaseFailed means the node cannot be provisioned, e.g., the instance type is unavailable.
+	MachinePhaseFailed MachinePhase = "Failed"
+)
+
+// ProvisionerOptions configures the node provisioners.
+type ProvisionerOptions struct {
+	// KarpenterProvisionerName is the Karpenter provisioner the machines are created for.
+	KarpenterProvisionerName string
+	// KarpenterNodePoolName is the Karpenter node pool the node claims are created for.
+	KarpenterNodePoolName string
+	// KarpenterNodeClassName is the Karpenter node class referenced by the node claims.
+	KarpenterNodeClassName string
+	// ClusterAPINamespace is the namespace of the Cluster API MachineDeployments.
+	ClusterAPINamespace string
+	// FakeNodeDelay is how long the fake provisioner takes to create a node.
+	FakeNodeDelay time.Duration
+	// InstanceTypes is the catalog the GPUs of the fake nodes are taken from.
+	InstanceTypes *sku.CatalogSource
+}
+
+// NewNodeProvisioner returns the node provisioner of the given kind.
+func NewNodeProvisioner(kind string, opts ProvisionerOptions, kubeClient client.Client) (NodeProvisioner, error) {
+	switch kind {
+	case ProvisionerKindMachine:
+		return &machineProvisioner{provisionerName: opts.KarpenterProvisionerName, kubeClient: kubeClient}, nil
+	case ProvisionerKindNodeClaim:
+		return &nodeClaimProvisioner{nodePoolName: opts.KarpenterNodePoolName, nodeClassName: opts.KarpenterNodeClassName,
+			kubeClient: kubeClient}, nil
+	case ProvisionerKindClusterAPI:
+		return &clusterAPIProvisioner{namespace: opts.ClusterAPINamespace, kubeClient: kubeClient}, nil
+	case ProvisionerKindBYO:
+		return &byoProvisioner{}, nil
+	case ProvisionerKindFake:
+		return newFakeProvisioner(opts.FakeNodeDelay, opts.InstanceTypes, kubeClient), nil
+	default:
+		return nil, fmt.Errorf("unsupported node provisioner %q, must be one of %v", kind,
+			[]string{ProvisionerKindMachine, ProvisionerKindNodeClaim, ProvisionerKindClusterAPI, ProvisionerKindBYO, ProvisionerKindFake})
+	}
+}
+
+// ActiveNodes returns the nodes requested with an instance type accepted by the workspace that are not being deleted.
+func ActiveNodes(workspaceObj *kdmv1alpha1.Workspace, nodes []*ProvisionedNode) []*ProvisionedNode {
+	instanceTypes := utils.GetInstanceTypes(workspaceObj)
+	return lo.Filter(nodes, func(node *ProvisionedNode, _ int) bool {
+		return lo.Contains(instanceTypes, node.InstanceType) && !node.Deleting
+	})
+}
+
+// generateWorkspaceLabels returns the labels of the objects provisioning nodes for the workspace. The nodes
+// need the labels of the workspace label selector to be selected for the workspace.
+func generateWorkspaceLabels(workspaceObj *kdmv1alpha1.Workspace) map[string]string {
+	workspaceLabels := map[string]string{
+		kdmv1alpha1.LabelWorkspaceName:      workspaceObj.Name,
+		kdmv1alpha1.LabelWorkspaceNamespace: workspaceObj.Namespace,
+	}
+	if workspaceObj.Resource.LabelSelector != nil &&
+		len(workspaceObj.Resource.LabelSelector.MatchLabels) != 0 {
+		workspaceLabels = lo.Assign(workspaceLabels, workspaceObj.Resource.LabelSelector.MatchLabels)
+	}
+	return workspaceLabels
+}
+
+// belongsToWorkspace returns true if the object was created for the workspace. Objects created before the namespace
+// label was added only carry the workspace name.
+func belongsToWorkspace(obj client.Object, workspaceObj *kdmv1alpha1.Workspace) bool {
+	objLabels := obj.GetLabels()
+	namespace, found := objLabels[kdmv1alpha1.LabelWorkspaceNamespace]
+	return objLabels[kdmv1alpha1.LabelWorkspaceName] == workspaceObj.Name && (!found || namespace == workspaceObj.Namespace)
+}
+
+// removeWorkspaceOwnerReference removes the owner reference to the workspace from the object.
+func removeWorkspaceOwnerReference(ctx context.Context, obj client.Object, workspaceObj *kdmv1alpha1.Workspace,
+	kubeClient client.Client) error {
+	if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return err
+	}
+	obj.SetOwnerReferences(lo.Reject(obj.GetOwnerReferences(), func(ref metav1.OwnerReference, _ int) bool {
+		return ref.UID == workspaceObj.UID
+	}))
+	return kubeClient.Update(ctx, obj, &client.UpdateOptions{})
+}
+
+// reassignWorkspaceObject replaces the workspace labels and the workspace owner reference of the object tracking a
+// node, so that the node is listed for the new workspace.
+func reassignWorkspaceObject(ctx context.Context, obj client.Object, from, to *kdmv1alpha1.Workspace,
+	kubeClient client.Client) error {
+	if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return err
+	}
+	obj.SetLabels(replaceWorkspaceLabels(obj.GetLabels(), from, to))
+	obj.SetOwnerReferences(lo.Map(obj.GetOwnerReferences(), func(ref metav1.OwnerReference, _ int) metav1.OwnerReference {
+		if ref.UID == from.UID {
+			ref.UID, ref.Name = to.UID, to.Name
+		}
+		return ref
+	}))
+	return kubeClient.Update(ctx, obj, &client.UpdateOptions{})
+}
+
+// replaceWorkspaceLabels removes the workspace labels of one workspace and adds the ones of another.
+func replaceWorkspaceLabels(objLabels map[string]string, from, to *kdmv1alpha1.Workspace) map[string]string {
+	return lo.Assign(lo.OmitByKeys(objLabels, lo.Keys(generateWorkspaceLabels(from))), generateWorkspaceLabels(to))
+}
+
+// ReassignNode moves a node from one workspace to another by replacing the workspace labels, so that the node
+// matches the label selector of the new workspace only.
+func ReassignNode(ctx context.Context, nodeName string, from, to *kdmv1alpha1.Workspace, kubeClient client.Client) error {
+	klog.InfoS("ReassignNode", "node", nodeName, "from", klog.KObj(from), "to", klog.KObj(to))
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		nodeObj := &v1.Node{}
+		if err := kubeClient.Get(ctx, client.ObjectKey{Name: nodeName}, nodeObj); err != nil {
+			return err
+		}
+		nodeObj.Labels = replaceWorkspaceLabels(nodeObj.Labels, from, to)
+		return kubeClient.Update(ctx, nodeObj, &client.UpdateOptions{})
+	})
+}
+
+// newUnstructured returns an empty object of the given kind, for the APIs that are not vendored.
+func newUnstructured(gvk schema.GroupVersionKind) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	return obj
+}
+
+func newUnstructuredList(gvk schema.GroupVersionKind) *unstructured.UnstructuredList {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	return list
+}
@@ -0,0 +1,166 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/sku"
+	"github.com/kdm/pkg/utils"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// errQuotaExceeded is wrapped by the errors of Check when a workspace does not fit in a quota.
+var errQuotaExceeded = errors.New("GPU quota exceeded")
+
+// IsQuotaExceeded returns true if the error means that a workspace does not fit in a GPU quota.
+func IsQuotaExceeded(err error) bool {
+	return errors.Is(err, errQuotaExceeded)
+}
+
+// WorkspaceUsage returns the GPUs and nodes requested by the workspace. The GPUs per node are the most GPUs of the
+// instance types the workspace accepts; instance types missing from the catalog are not counted. Suspended
+// workspaces request nothing.
+func WorkspaceUsage(wObj *kdmv1alpha1.Workspace, catalog *sku.Catalog) kdmv1alpha1.GPUQuotaUsage {
+	if wObj.Spec.Suspend {
+		return kdmv1alpha1.GPUQuotaUsage{}
+	}
+	nodeCount := utils.GetNodeCount(wObj)
+	gpuCount := 0
+	for _, name := range utils.GetInstanceTypes(wObj) {
+		if instanceType, found := catalog.Get(name); found {
+			gpuCount = lo.Max([]int{gpuCount, instanceType.GPUCount})
+		}
+	}
+	return kdmv1alpha1.GPUQuotaUsage{
+		GPUs:  int32(gpuCount * nodeCount),
+		Nodes: int32(nodeCount),
+	}
+}
+
+// Selects returns true if the quota limits the workspaces of the namespace.
+func Selects(quotaObj *kdmv1alpha1.GPUQuota, namespaceObj *corev1.Namespace) (bool, error) {
+	if quotaObj.Spec.NamespaceSelector == nil {
+		return true, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(quotaObj.Spec.NamespaceSelector)
+	if err != nil {
+		return false, fmt.Errorf("invalid namespace selector of GPU quota %s: %w", quotaObj.Name, err)
+	}
+	return selector.Matches(labels.Set(namespaceObj.Labels)), nil
+}
+
+// ListForNamespace returns the quotas limiting the workspaces of the namespace.
+func ListForNamespace(ctx context.Context, kubeClient client.Client, namespace string) ([]*kdmv1alpha1.GPUQuota, error) {
+	quotaList := &kdmv1alpha1.GPUQuotaList{}
+	if err := kubeClient.List(ctx, quotaList); err != nil {
+		return nil, err
+	}
+	if len(quotaList.Items) == 0 {
+		return nil, nil
+	}
+	namespaceObj := &corev1.Namespace{}
+	if err := kubeClient.Get(ctx, client.ObjectKey{Name: namespace}, namespaceObj); err != nil {
+		return nil, err
+	}
+
+	var quotas []*kdmv1alpha1.GPUQuota
+	for i := range quotaList.Items {
+		selected, err := Selects(&quotaList.Items[i], namespaceObj)
+		if err != nil {
+			return nil, err
+		}
+		if selected {
+			quotas = append(quotas, &quotaList.Items[i])
+		}
+	}
+	return quotas, nil
+}
+
+// Usage returns the GPUs and nodes requested by the workspaces limited by the quota, and their number.
+// Only the workspaces holding nodes or provisioning machines are counted: queued workspaces do not use the quota, so
+// that they cannot keep each other from being admitted. The workspace with the excluded UID is not counted.
+func Usage(ctx context.Context, kubeClient client.Client, catalog *sku.Catalog, quotaObj *kdmv1alpha1.GPUQuota,
+	excluded types.UID) (kdmv1alpha1.GPUQuotaUsage, int, error) {
+	var used kdmv1alpha1.GPUQuotaUsage
+	namespaceList := &corev1.NamespaceList{}
+	if err := kubeClient.List(ctx, namespaceList); err != nil {
+		return used, 0, err
+	}
+
+	workspaceCount := 0
+	for i := range namespaceList.Items {
+		selected, err := Selects(quotaObj, &namespaceList.Items[i])
+		if err != nil {
+			return used, 0, err
+		}
+		if !selected {
+			continue
+		}
+		workspaceList := &kdmv1alpha1.WorkspaceList{}
+		if err := kubeClient.List(ctx, workspaceList, client.InNamespace(namespaceList.Items[i].Name)); err != nil {
+			return used, 0, err
+		}
+		for j := range workspaceList.Items {
+			if workspaceList.Items[j].UID == excluded || !holdsNodes(&workspaceList.Items[j]) {
+				continue
+			}
+			usage := WorkspaceUsage(&workspaceList.Items[j], catalog)
+			used.GPUs += usage.GPUs
+			used.Nodes += usage.Nodes
+			workspaceCount++
+		}
+	}
+	return used, workspaceCount, nil
+}
+
+// holdsNodes returns true if the workspace has been admitted: it has nodes or machines being provisioned.
+func holdsNodes(wObj *kdmv1alpha1.Workspace) bool {
+	return len(wObj.Status.WorkerNodes) != 0 || len(wObj.Status.ProvisioningMachines) != 0
+}
+
+// Check returns an error wrapping errQuotaExceeded if the workspace uses an instance type that one of the quotas of
+// its namespace does not allow, or if it requests more GPUs or nodes than the quota has left.
+func Check(ctx context.Context, kubeClient client.Client, catalog *sku.Catalog, wObj *kdmv1alpha1.Workspace) error {
+	quotas, err := ListForNamespace(ctx, kubeClient, wObj.Namespace)
+	if err != nil {
+		return err
+	}
+
+	requested := WorkspaceUsage(wObj, catalog)
+	for _, quotaObj := range quotas {
+		if len(quotaObj.Spec.AllowedInstanceTypes) != 0 {
+			for _, instanceType := range utils.GetInstanceTypes(wObj) {
+				if !lo.Contains(quotaObj.Spec.AllowedInstanceTypes, instanceType) {
+					return fmt.Errorf("%w: instance type %s is not allowed by GPU quota %s, allowed instance types are %v",
+						errQuotaExceeded, instanceType, quotaObj.Name, quotaObj.Spec.AllowedInstanceTypes)
+				}
+			}
+		}
+		if quotaObj.Spec.MaxGPUs == nil && quotaObj.Spec.MaxNodes == nil {
+			continue
+		}
+
+		used, _, err := Usage(ctx, kubeClient, catalog, quotaObj, wObj.UID)
+		if err != nil {
+			return err
+		}
+		klog.InfoS("CheckGPUQuota", "workspace", klog.KObj(wObj), "quota", quotaObj.Name, "used", used, "requested", requested)
+		if maxGPUs := quotaObj.Spec.MaxGPUs; maxGPUs != nil && used.GPUs+requested.GPUs > *maxGPUs {
+			return fmt.Errorf("%w: GPU quota %s allows %d GPUs, %d are used by other workspaces and the workspace requests %d",
+				errQuotaExceeded, quotaObj.Name, *maxGPUs, used.GPUs, requested.GPUs)
+		}
+		if maxNodes := quotaObj.Spec.MaxNodes; maxNodes != nil && used.Nodes+requested.Nodes > *maxNodes {
+			return fmt.Errorf("%w: GPU quota %s allows %d nodes, %d are used by other workspaces and the workspace requests %d",
+				errQuotaExceeded, quotaObj.Name, *maxNodes, used.Nodes, requested.Nodes)
+		}
+	}
+	return nil
+}
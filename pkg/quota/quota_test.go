@@ -0,0 +1,190 @@
+package quota
+
+import (
+	"context"
+	"testing"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/sku"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var testCatalog = sku.NewCatalog([]sku.InstanceType{
+	{Name: "gpu1", GPUCount: 1},
+	{Name: "gpu4", GPUCount: 4},
+})
+
+func newFakeClient(t *testing.T, objs ...client.Object) client.Client {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add the client-go types to the scheme: %v", err)
+	}
+	if err := kdmv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add the kdm types to the scheme: %v", err)
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+}
+
+func newNamespace(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+// newWorkspace returns a workspace of count nodes of the instance type. A workspace with nodes holds them, a
+// workspace without nodes is queued.
+func newWorkspace(namespace, name, instanceType string, count int, withNodes bool) *kdmv1alpha1.Workspace {
+	wObj := &kdmv1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, UID: types.UID(namespace + "/" + name)},
+		Resource: kdmv1alpha1.ResourceSpec{
+			Count:        lo.ToPtr(count),
+			InstanceType: instanceType,
+		},
+	}
+	if withNodes {
+		for i := 0; i < count; i++ {
+			wObj.Status.WorkerNodes = append(wObj.Status.WorkerNodes, name+"-node")
+		}
+	}
+	return wObj
+}
+
+func TestWorkspaceUsage(t *testing.T) {
+	suspended := newWorkspace("default", "suspended", "gpu4", 2, true)
+	suspended.Spec.Suspend = true
+	replicas := newWorkspace("default", "replicas", "gpu1", 1, true)
+	replicas.Spec.Replicas = lo.ToPtr(int32(3))
+	fallback := newWorkspace("default", "fallback", "gpu1", 2, true)
+	fallback.Resource.FallbackInstanceTypes = []string{"gpu4"}
+	selected := newWorkspace("default", "selected", "", 1, true)
+	selected.Status.SelectedInstanceType = "gpu4"
+
+	testCases := []struct {
+		name      string
+		workspace *kdmv1alpha1.Workspace
+		want      kdmv1alpha1.GPUQuotaUsage
+	}{
+		{name: "instance type", workspace: newWorkspace("default", "workspace", "gpu4", 2, true),
+			want: kdmv1alpha1.GPUQuotaUsage{GPUs: 8, Nodes: 2}},
+		{name: "unknown instance type", workspace: newWorkspace("default", "workspace", "unknown", 2, true),
+			want: kdmv1alpha1.GPUQuotaUsage{GPUs: 0, Nodes: 2}},
+		{name: "suspended", workspace: suspended, want: kdmv1alpha1.GPUQuotaUsage{}},
+		{name: "replicas take precedence over count", workspace: replicas,
+			want: kdmv1alpha1.GPUQuotaUsage{GPUs: 3, Nodes: 3}},
+		{name: "largest fallback instance type", workspace: fallback,
+			want: kdmv1alpha1.GPUQuotaUsage{GPUs: 8, Nodes: 2}},
+		{name: "selected instance type", workspace: selected,
+			want: kdmv1alpha1.GPUQuotaUsage{GPUs: 4, Nodes: 1}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := WorkspaceUsage(tc.workspace, testCatalog); got != tc.want {
+				t.Errorf("WorkspaceUsage() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUsage(t *testing.T) {
+	provisioning := newWorkspace("team-a", "provisioning", "gpu1", 1, false)
+	provisioning.Status.ProvisioningMachines = []string{"machine"}
+	suspended := newWorkspace("team-a", "suspended", "gpu4", 1, true)
+	suspended.Spec.Suspend = true
+	excluded := newWorkspace("team-a", "excluded", "gpu4", 1, true)
+	kubeClient := newFakeClient(t,
+		newNamespace("team-a", map[string]string{"team": "a"}),
+		newNamespace("team-b", map[string]string{"team": "b"}),
+		newWorkspace("team-a", "running", "gpu4", 2, true),
+		newWorkspace("team-a", "queued", "gpu4", 2, false),
+		provisioning,
+		suspended,
+		excluded,
+		newWorkspace("team-b", "other-team", "gpu4", 1, true),
+	)
+
+	testCases := []struct {
+		name           string
+		quota          *kdmv1alpha1.GPUQuota
+		want           kdmv1alpha1.GPUQuotaUsage
+		wantWorkspaces int
+	}{
+		{
+			name: "selected namespace",
+			quota: &kdmv1alpha1.GPUQuota{Spec: kdmv1alpha1.GPUQuotaSpec{
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+			}},
+			want:           kdmv1alpha1.GPUQuotaUsage{GPUs: 9, Nodes: 3},
+			wantWorkspaces: 3,
+		},
+		{
+			name:           "all namespaces",
+			quota:          &kdmv1alpha1.GPUQuota{},
+			want:           kdmv1alpha1.GPUQuotaUsage{GPUs: 13, Nodes: 4},
+			wantWorkspaces: 4,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, workspaces, err := Usage(context.Background(), kubeClient, testCatalog, tc.quota, excluded.UID)
+			if err != nil {
+				t.Fatalf("Usage() error = %v", err)
+			}
+			if got != tc.want || workspaces != tc.wantWorkspaces {
+				t.Errorf("Usage() = %+v, %d workspaces, want %+v, %d workspaces", got, workspaces, tc.want, tc.wantWorkspaces)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	quotaObj := &kdmv1alpha1.GPUQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-a"},
+		Spec: kdmv1alpha1.GPUQuotaSpec{
+			NamespaceSelector:    &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+			MaxGPUs:              lo.ToPtr(int32(8)),
+			MaxNodes:             lo.ToPtr(int32(3)),
+			AllowedInstanceTypes: []string{"gpu1", "gpu4"},
+		},
+	}
+	objs := []client.Object{
+		quotaObj,
+		newNamespace("team-a", map[string]string{"team": "a"}),
+		newNamespace("team-b", map[string]string{"team": "b"}),
+		newWorkspace("team-a", "running", "gpu4", 1, true),
+		newWorkspace("team-a", "queued", "gpu4", 2, false),
+	}
+
+	testCases := []struct {
+		name         string
+		workspace    *kdmv1alpha1.Workspace
+		wantExceeded bool
+	}{
+		{name: "fits", workspace: newWorkspace("team-a", "workspace", "gpu4", 1, false)},
+		{name: "too many GPUs", workspace: newWorkspace("team-a", "workspace", "gpu4", 2, false), wantExceeded: true},
+		{name: "too many nodes", workspace: newWorkspace("team-a", "workspace", "gpu1", 3, false), wantExceeded: true},
+		{name: "instance type not allowed", workspace: newWorkspace("team-a", "workspace", "gpu8", 1, false), wantExceeded: true},
+		{name: "already counted workspace is not counted twice", workspace: newWorkspace("team-a", "running", "gpu4", 2, true)},
+		{name: "namespace without quota", workspace: newWorkspace("team-b", "workspace", "gpu8", 4, false)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kubeClient := newFakeClient(t, objs...)
+			err := Check(context.Background(), kubeClient, testCatalog, tc.workspace)
+			if err != nil && !IsQuotaExceeded(err) {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if exceeded := IsQuotaExceeded(err); exceeded != tc.wantExceeded {
+				t.Errorf("Check() error = %v, want exceeded %t", err, tc.wantExceeded)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronField is the range and the names of the values of a field of a cron expression.
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField     = cronField{name: "minute", min: 0, max: 59}
+	hourField       = cronField{name: "hour", min: 0, max: 23}
+	dayOfMonthField = cronField{name: "day of month", min: 1, max: 31}
+	monthField      = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Sunday is both 0 and 7.
+	dayOfWeekField = cronField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// maxSearchYears bounds the search of the next time of expressions that never match, e.g., February 30th.
+const maxSearchYears = 5
+
+// maxClockShift is the most the clocks of a time zone are set back by a daylight saving time change.
+const maxClockShift = 2 * time.Hour
+
+// Cron is a parsed cron expression in the standard five field format: minute, hour, day of month, month and
+// day of week. Fields accept *, values, names of months and days, ranges, lists and steps, e.g., "*/15 8-20 * * mon-fri".
+type Cron struct {
+	minute, hour, dayOfMonth, month, dayOfWeek uint64
+	// A day matches either the day of month or the day of week when both are restricted, like in cron.
+	dayOfMonthAny, dayOfWeekAny bool
+	location                    *time.Location
+}
+
+// ParseCron parses a cron expression evaluated in the given location.
+func ParseCron(expr string, location *time.Location) (*Cron, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields, got %d", expr, len(fields))
+	}
+
+	c := &Cron{location: location}
+	var err error
+	if c.minute, err = parseCronField(fields[0], minuteField); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+	if c.hour, err = parseCronField(fields[1], hourField); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+	if c.dayOfMonth, err = parseCronField(fields[2], dayOfMonthField); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+	if c.month, err = parseCronField(fields[3], monthField); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+	if c.dayOfWeek, err = parseCronField(fields[4], dayOfWeekField); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+	if c.dayOfWeek&(1<<7) != 0 {
+		c.dayOfWeek |= 1
+	}
+	c.dayOfMonthAny = strings.HasPrefix(fields[2], "*")
+	c.dayOfWeekAny = strings.HasPrefix(fields[4], "*")
+	return c, nil
+}
+
+// parseCronField returns the bit set of the values matched by a comma separated list of ranges.
+func parseCronField(expr string, field cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expr, ",") {
+		rangeExpr, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rangeExpr = part[:i]
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q of the %s field", part[i+1:], field.name)
+			}
+		}
+
+		var low, high int
+		switch {
+		case rangeExpr == "*":
+			low, high = field.min, field.max
+		case strings.Contains(rangeExpr, "-"):
+			bounds := strings.SplitN(rangeExpr, "-", 2)
+			var err error
+			if low, err = parseCronValue(bounds[0], field); err != nil {
+				return 0, err
+			}
+			if high, err = parseCronValue(bounds[1], field); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid range %q of the %s field", rangeExpr, field.name)
+			}
+		default:
+			value, err := parseCronValue(rangeExpr, field)
+			if err != nil {
+				return 0, err
+			}
+			// A single value with a step runs until the end of the range, e.g., 5/15 is 5,20,35,50.
+			low, high = value, value
+			if strings.Contains(part, "/") {
+				high = field.max
+			}
+		}
+		for value := low; value <= high; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+func parseCronValue(expr string, field cronField) (int, error) {
+	if value, found := field.names[strings.ToLower(expr)]; found {
+		return value, nil
+	}
+	value, err := strconv.Atoi(expr)
+	if err != nil || value < field.min || value > field.max {
+		return 0, fmt.Errorf("invalid value %q of the %s field, must be between %d and %d", expr, field.name, field.min, field.max)
+	}
+	return value, nil
+}
+
+// Next returns the first time matching the expression strictly after t, or the zero time if the expression does
+// not match within the next years. The expression matches the wall clock of the location: the times skipped when
+// the clocks are set forward do not match, the times repeated when they are set back match the first time only.
+func (c *Cron) Next(t time.Time) time.Time {
+	wall := wallClock(t.In(c.location)).Truncate(time.Minute)
+	limit := wall.Year() + maxSearchYears
+	for wall = c.nextWallClock(wall.Add(time.Minute), limit); !wall.IsZero(); wall = c.nextWallClock(wall.Add(time.Minute), limit) {
+		if next, found := c.fromWallClock(wall); found && next.After(t) {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// Prev returns the last time matching the expression at or before t, or the zero time if the expression did not
+// match within the previous years. Times match like in Next.
+func (c *Cron) Prev(t time.Time) time.Time {
+	// After the clocks are set back, the wall clock times up to the shift later have already passed.
+	wall := wallClock(t.In(c.location)).Truncate(time.Minute).Add(maxClockShift)
+	limit := wall.Year() - maxSearchYears
+	for wall = c.prevWallClock(wall, limit); !wall.IsZero(); wall = c.prevWallClock(wall.Add(-time.Minute), limit) {
+		if prev, found := c.fromWallClock(wall); found && !prev.After(t) {
+			return prev
+		}
+	}
+	return time.Time{}
+}
+
+// nextWallClock returns the first wall clock time matching the expression at or after wall, or the zero time after
+// the limit year. Wall clock times are in UTC, which has no daylight saving time.
+func (c *Cron) nextWallClock(wall time.Time, limit int) time.Time {
+	for wall.Year() <= limit {
+		if c.month&(1<<uint(wall.Month())) == 0 {
+			wall = time.Date(wall.Year(), wall.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.matchesDay(wall) {
+			wall = time.Date(wall.Year(), wall.Month(), wall.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if c.hour&(1<<uint(wall.Hour())) == 0 {
+			wall = time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour()+1, 0, 0, 0, time.UTC)
+			continue
+		}
+		if c.minute&(1<<uint(wall.Minute())) == 0 {
+			wall = wall.Add(time.Minute)
+			continue
+		}
+		return wall
+	}
+	return time.Time{}
+}
+
+// prevWallClock returns the last wall clock time matching the expression at or before wall, or the zero time before
+// the limit year.
+func (c *Cron) prevWallClock(wall time.Time, limit int) time.Time {
+	for wall.Year() >= limit {
+		if c.month&(1<<uint(wall.Month())) == 0 {
+			wall = time.Date(wall.Year(), wall.Month(), 1, 0, 0, 0, 0, time.UTC).Add(-time.Minute)
+			continue
+		}
+		if !c.matchesDay(wall) {
+			wall = time.Date(wall.Year(), wall.Month(), wall.Day(), 0, 0, 0, 0, time.UTC).Add(-time.Minute)
+			continue
+		}
+		if c.hour&(1<<uint(wall.Hour())) == 0 {
+			wall = time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), 0, 0, 0, time.UTC).Add(-time.Minute)
+			continue
+		}
+		if c.minute&(1<<uint(wall.Minute())) == 0 {
+			wall = wall.Add(-time.Minute)
+			continue
+		}
+		return wall
+	}
+	return time.Time{}
+}
+
+// fromWallClock returns the first time the wall clock of the location shows the given wall clock time, or false if
+// it never does because the clocks are set forward over it.
+func (c *Cron) fromWallClock(wall time.Time) (time.Time, bool) {
+	var first time.Time
+	// The offsets in effect a day before and a day after cover the daylight saving time change around the time.
+	for _, probe := range []time.Duration{-24 * time.Hour, 24 * time.Hour} {
+		_, offset := wall.Add(probe).In(c.location).Zone()
+		t := wall.Add(-time.Duration(offset) * time.Second).In(c.location)
+		if wallClock(t).Equal(wall) && (first.IsZero() || t.Before(first)) {
+			first = t
+		}
+	}
+	return first, !first.IsZero()
+}
+
+// wallClock returns the wall clock time of t in UTC.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+func (c *Cron) matchesDay(t time.Time) bool {
+	dayOfMonth := c.dayOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := c.dayOfWeek&(1<<uint(t.Weekday())) != 0
+	if c.dayOfMonthAny || c.dayOfWeekAny {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
@@ -0,0 +1,161 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("failed to load location %s: %v", name, err)
+	}
+	return location
+}
+
+func mustParseTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatalf("failed to parse time %s: %v", value, err)
+	}
+	return parsed
+}
+
+func TestParseCron(t *testing.T) {
+	testCases := []struct {
+		name    string
+		expr    string
+		wantErr bool
+	}{
+		{name: "every minute", expr: "* * * * *"},
+		{name: "values, ranges, lists and steps", expr: "*/15 8-20 1,15 1-12/2 mon-fri"},
+		{name: "names are case insensitive", expr: "0 0 * JAN Sun"},
+		{name: "single value with a step", expr: "5/15 * * * *"},
+		{name: "sunday as 7", expr: "0 0 * * 7"},
+		{name: "too few fields", expr: "* * * *", wantErr: true},
+		{name: "too many fields", expr: "* * * * * *", wantErr: true},
+		{name: "minute out of range", expr: "60 * * * *", wantErr: true},
+		{name: "hour out of range", expr: "0 24 * * *", wantErr: true},
+		{name: "day of month zero", expr: "0 0 0 * *", wantErr: true},
+		{name: "unknown month name", expr: "0 0 * foo *", wantErr: true},
+		{name: "day of week out of range", expr: "0 0 * * 8", wantErr: true},
+		{name: "reversed range", expr: "0 20-8 * * *", wantErr: true},
+		{name: "zero step", expr: "*/0 * * * *", wantErr: true},
+		{name: "invalid step", expr: "*/x * * * *", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseCron(tc.expr, time.UTC)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("ParseCron(%q) error = %v, wantErr %v", tc.expr, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expr     string
+		location string
+		from     string
+		want     string
+	}{
+		{name: "weekday morning skips the weekend", expr: "0 8 * * 1-5", location: "UTC",
+			from: "2024-01-05T09:00:00Z", want: "2024-01-08T08:00:00Z"},
+		{name: "strictly after a matching time", expr: "0 8 * * *", location: "UTC",
+			from: "2024-01-05T08:00:00Z", want: "2024-01-06T08:00:00Z"},
+		{name: "seconds are truncated", expr: "*/15 * * * *", location: "UTC",
+			from: "2024-01-05T10:07:30Z", want: "2024-01-05T10:15:00Z"},
+		{name: "leap day", expr: "0 0 29 2 *", location: "UTC",
+			from: "2024-03-01T00:00:00Z", want: "2028-02-29T00:00:00Z"},
+		{name: "never matches", expr: "0 0 30 2 *", location: "UTC",
+			from: "2024-01-01T00:00:00Z", want: ""},
+		{name: "day of month or day of week", expr: "0 0 13 * fri", location: "UTC",
+			from: "2024-01-01T00:00:00Z", want: "2024-01-05T00:00:00Z"},
+		{name: "sunday as 7", expr: "0 0 * * 7", location: "UTC",
+			from: "2024-01-01T00:00:00Z", want: "2024-01-07T00:00:00Z"},
+		{name: "time zone", expr: "0 8 * * *", location: "Europe/Berlin",
+			from: "2024-01-05T09:00:00+01:00", want: "2024-01-06T08:00:00+01:00"},
+		{name: "time skipped when the clocks are set forward does not match", expr: "30 2 * * *", location: "Europe/Berlin",
+			from: "2024-03-30T12:00:00+01:00", want: "2024-04-01T02:30:00+02:00"},
+		{name: "time after the clocks are set forward", expr: "0 3 * * *", location: "Europe/Berlin",
+			from: "2024-03-31T01:00:00+01:00", want: "2024-03-31T03:00:00+02:00"},
+		{name: "time repeated when the clocks are set back matches the first time", expr: "30 2 * * *", location: "Europe/Berlin",
+			from: "2024-10-26T12:00:00+02:00", want: "2024-10-27T02:30:00+02:00"},
+		{name: "time repeated when the clocks are set back does not match again", expr: "30 2 * * *", location: "Europe/Berlin",
+			from: "2024-10-27T02:30:00+02:00", want: "2024-10-28T02:30:00+01:00"},
+		{name: "repeated hour is skipped", expr: "*/30 * * * *", location: "Europe/Berlin",
+			from: "2024-10-27T02:30:00+02:00", want: "2024-10-27T03:00:00+01:00"},
+		{name: "time skipped in New York", expr: "0 2 * * *", location: "America/New_York",
+			from: "2024-03-09T12:00:00-05:00", want: "2024-03-11T02:00:00-04:00"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cron, err := ParseCron(tc.expr, mustLoadLocation(t, tc.location))
+			if err != nil {
+				t.Fatalf("ParseCron(%q) error = %v", tc.expr, err)
+			}
+			got := cron.Next(mustParseTime(t, tc.from))
+			if tc.want == "" {
+				if !got.IsZero() {
+					t.Errorf("Next(%s) = %s, want the zero time", tc.from, got)
+				}
+				return
+			}
+			if want := mustParseTime(t, tc.want); !got.Equal(want) {
+				t.Errorf("Next(%s) = %s, want %s", tc.from, got, want)
+			}
+		})
+	}
+}
+
+func TestCronPrev(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expr     string
+		location string
+		from     string
+		want     string
+	}{
+		{name: "weekday morning skips the weekend", expr: "0 8 * * 1-5", location: "UTC",
+			from: "2024-01-08T07:00:00Z", want: "2024-01-05T08:00:00Z"},
+		{name: "at a matching time", expr: "0 8 * * *", location: "UTC",
+			from: "2024-01-05T08:00:00Z", want: "2024-01-05T08:00:00Z"},
+		{name: "seconds are truncated", expr: "*/15 * * * *", location: "UTC",
+			from: "2024-01-05T10:07:30Z", want: "2024-01-05T10:00:00Z"},
+		{name: "previous year", expr: "0 0 1 jan *", location: "UTC",
+			from: "2024-06-01T00:00:00Z", want: "2024-01-01T00:00:00Z"},
+		{name: "never matches", expr: "0 0 30 2 *", location: "UTC",
+			from: "2024-01-01T00:00:00Z", want: ""},
+		{name: "time skipped when the clocks are set forward does not match", expr: "30 2 * * *", location: "Europe/Berlin",
+			from: "2024-03-31T12:00:00+02:00", want: "2024-03-30T02:30:00+01:00"},
+		{name: "time repeated when the clocks are set back matches the first time", expr: "30 2 * * *", location: "Europe/Berlin",
+			from: "2024-10-27T02:45:00+01:00", want: "2024-10-27T02:30:00+02:00"},
+		{name: "later wall clock time that has passed before the clocks are set back", expr: "45 2 * * *", location: "Europe/Berlin",
+			from: "2024-10-27T02:10:00+01:00", want: "2024-10-27T02:45:00+02:00"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cron, err := ParseCron(tc.expr, mustLoadLocation(t, tc.location))
+			if err != nil {
+				t.Fatalf("ParseCron(%q) error = %v", tc.expr, err)
+			}
+			got := cron.Prev(mustParseTime(t, tc.from))
+			if tc.want == "" {
+				if !got.IsZero() {
+					t.Errorf("Prev(%s) = %s, want the zero time", tc.from, got)
+				}
+				return
+			}
+			if want := mustParseTime(t, tc.want); !got.Equal(want) {
+				t.Errorf("Prev(%s) = %s, want %s", tc.from, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package schedule
+
+import (
+	"fmt"
+	"time"
+	// The time zones are embedded, the controller image has no zoneinfo database.
+	_ "time/tzdata"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Lookback is how far back the scheduled actions are applied when a workspace has not been started or stopped by its
+// schedule yet, or the controller has not run for a while. A weekly schedule is in effect at once.
+const Lookback = 7 * 24 * time.Hour
+
+// Schedule is the parsed start and stop schedule of a workspace.
+type Schedule struct {
+	start, stop *Cron
+}
+
+// Parse parses the cron expressions and the time zone of the schedule of a workspace.
+func Parse(spec *kdmv1alpha1.WorkspaceSchedule) (*Schedule, error) {
+	location, err := time.LoadLocation(spec.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", spec.TimeZone, err)
+	}
+	start, err := ParseCron(spec.Start, location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start: %w", err)
+	}
+	stop, err := ParseCron(spec.Stop, location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stop: %w", err)
+	}
+	return &Schedule{start: start, stop: stop}, nil
+}
+
+// Next returns the first scheduled action strictly after t, or nil if there is none. A start and a stop at the same
+// time stop the workspace.
+func (s *Schedule) Next(t time.Time) *kdmv1alpha1.ScheduledAction {
+	start, stop := s.start.Next(t), s.stop.Next(t)
+	switch {
+	case start.IsZero() && stop.IsZero():
+		return nil
+	case stop.IsZero() || (!start.IsZero() && start.Before(stop)):
+		return newScheduledAction(kdmv1alpha1.ScheduledActionStart, start)
+	default:
+		return newScheduledAction(kdmv1alpha1.ScheduledActionStop, stop)
+	}
+}
+
+// Last returns the last scheduled action after the given time and up to now, or nil if there is none.
+// Actions older than Lookback are ignored. A start and a stop at the same time stop the workspace.
+func (s *Schedule) Last(after, now time.Time) *kdmv1alpha1.ScheduledAction {
+	if lookback := now.Add(-Lookback); after.Before(lookback) {
+		after = lookback
+	}
+	var last *kdmv1alpha1.ScheduledAction
+	start, stop := s.start.Prev(now), s.stop.Prev(now)
+	switch {
+	case start.IsZero() && stop.IsZero():
+		return nil
+	case stop.IsZero() || start.After(stop):
+		last = newScheduledAction(kdmv1alpha1.ScheduledActionStart, start)
+	default:
+		last = newScheduledAction(kdmv1alpha1.ScheduledActionStop, stop)
+	}
+	if !last.Time.After(after) {
+		return nil
+	}
+	return last
+}
+
+func newScheduledAction(action kdmv1alpha1.ScheduledActionType, t time.Time) *kdmv1alpha1.ScheduledAction {
+	return &kdmv1alpha1.ScheduledAction{
+		Action: action,
+		Time:   metav1.NewTime(t),
+	}
+}
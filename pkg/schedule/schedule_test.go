@@ -0,0 +1,62 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+)
+
+func TestScheduleLast(t *testing.T) {
+	testCases := []struct {
+		name       string
+		start      string
+		stop       string
+		timeZone   string
+		after      string
+		now        string
+		wantAction kdmv1alpha1.ScheduledActionType
+		wantTime   string
+	}{
+		{name: "last start", start: "0 8 * * 1-5", stop: "0 20 * * 1-5", timeZone: "UTC",
+			now: "2024-01-08T09:00:00Z", wantAction: kdmv1alpha1.ScheduledActionStart, wantTime: "2024-01-08T08:00:00Z"},
+		{name: "last stop over the weekend", start: "0 8 * * 1-5", stop: "0 20 * * 1-5", timeZone: "UTC",
+			now: "2024-01-06T12:00:00Z", wantAction: kdmv1alpha1.ScheduledActionStop, wantTime: "2024-01-05T20:00:00Z"},
+		{name: "already applied", start: "0 8 * * 1-5", stop: "0 20 * * 1-5", timeZone: "UTC",
+			after: "2024-01-08T08:00:00Z", now: "2024-01-08T09:00:00Z"},
+		{name: "within the lookback", start: "0 8 1 1 *", stop: "0 20 1 1 *", timeZone: "UTC",
+			now: "2024-01-08T09:00:00Z", wantAction: kdmv1alpha1.ScheduledActionStop, wantTime: "2024-01-01T20:00:00Z"},
+		{name: "older than the lookback", start: "0 8 1 1 *", stop: "0 20 1 1 *", timeZone: "UTC",
+			now: "2024-01-09T09:00:00Z"},
+		{name: "start and stop at the same time stop", start: "0 8 * * *", stop: "0 8 * * *", timeZone: "UTC",
+			now: "2024-01-08T09:00:00Z", wantAction: kdmv1alpha1.ScheduledActionStop, wantTime: "2024-01-08T08:00:00Z"},
+		{name: "repeated time is applied once", start: "30 2 * * *", stop: "0 23 * * *", timeZone: "Europe/Berlin",
+			after: "2024-10-27T02:30:00+02:00", now: "2024-10-27T02:40:00+01:00"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sched, err := Parse(&kdmv1alpha1.WorkspaceSchedule{Start: tc.start, Stop: tc.stop, TimeZone: tc.timeZone})
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			var after time.Time
+			if tc.after != "" {
+				after = mustParseTime(t, tc.after)
+			}
+			got := sched.Last(after, mustParseTime(t, tc.now))
+			if tc.wantTime == "" {
+				if got != nil {
+					t.Errorf("Last() = %s at %s, want nil", got.Action, got.Time)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatalf("Last() = nil, want %s at %s", tc.wantAction, tc.wantTime)
+			}
+			if want := mustParseTime(t, tc.wantTime); got.Action != tc.wantAction || !got.Time.Time.Equal(want) {
+				t.Errorf("Last() = %s at %s, want %s at %s", got.Action, got.Time, tc.wantAction, want)
+			}
+		})
+	}
+}
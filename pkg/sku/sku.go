@@ -0,0 +1,160 @@
+package sku
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// CatalogConfigMapKey is the key of the instance types in the catalog ConfigMap.
+const CatalogConfigMapKey = "instanceTypes"
+
+// InstanceType describes the capacity of a GPU instance type.
+type InstanceType struct {
+	Name string `json:"name"`
+	// GPUCount is the number of nvidia.com/gpu of the instance type.
+	GPUCount int `json:"gpuCount"`
+	// GPUMemory is the memory of a single GPU.
+	GPUMemory resource.Quantity `json:"gpuMemory"`
+	CPU       resource.Quantity `json:"cpu"`
+	Memory    resource.Quantity `json:"memory"`
+	// Disk is the local temporary storage of the instance type.
+	Disk resource.Quantity `json:"disk"`
+	// PricePerHour is only used to rank the instance types that fit a workload, the cheapest first.
+	PricePerHour float64 `json:"pricePerHour"`
+}
+
+// Requirements are the per node requirements of a workload.
+type Requirements struct {
+	GPUCount int
+	// MinGPUMemory is the minimum memory of a single GPU. Zero means no requirement.
+	MinGPUMemory resource.Quantity
+	// Disk is the local storage used by the workload. Zero means no requirement.
+	Disk resource.Quantity
+}
+
+// Fits returns an error describing the first requirement the instance type does not meet.
+func (t *InstanceType) Fits(req Requirements) error {
+	if t.GPUCount < req.GPUCount {
+		return fmt.Errorf("instance type %s has %d GPUs but %d GPUs are required per node", t.Name, t.GPUCount, req.GPUCount)
+	}
+	if !req.MinGPUMemory.IsZero() && t.GPUMemory.Cmp(req.MinGPUMemory) < 0 {
+		return fmt.Errorf("instance type %s has %s of GPU memory but %s are required", t.Name, t.GPUMemory.String(), req.MinGPUMemory.String())
+	}
+	if !req.Disk.IsZero() && !t.Disk.IsZero() && t.Disk.Cmp(req.Disk) < 0 {
+		return fmt.Errorf("instance type %s has %s of disk but %s are required", t.Name, t.Disk.String(), req.Disk.String())
+	}
+	return nil
+}
+
+func gpuInstanceType(name string, gpuCount int, gpuMemory, cpu, memory, disk string, pricePerHour float64) InstanceType {
+	return InstanceType{
+		Name:         name,
+		GPUCount:     gpuCount,
+		GPUMemory:    resource.MustParse(gpuMemory),
+		CPU:          resource.MustParse(cpu),
+		Memory:       resource.MustParse(memory),
+		Disk:         resource.MustParse(disk),
+		PricePerHour: pricePerHour,
+	}
+}
+
+// builtinInstanceTypes are the supported GPU instance types, with approximate pay-as-you-go prices in USD.
+var builtinInstanceTypes = []InstanceType{
+	gpuInstanceType("Standard_NC6s_v3", 1, "16Gi", "6", "112Gi", "736Gi", 3.06),
+	gpuInstanceType("Standard_NC12s_v3", 2, "16Gi", "12", "224Gi", "1474Gi", 6.12),
+	gpuInstanceType("Standard_NC24s_v3", 4, "16Gi", "24", "448Gi", "2948Gi", 12.24),
+	gpuInstanceType("Standard_NC24rs_v3", 4, "16Gi", "24", "448Gi", "2948Gi", 13.46),
+	gpuInstanceType("Standard_NC4as_T4_v3", 1, "16Gi", "4", "28Gi", "180Gi", 0.526),
+	gpuInstanceType("Standard_NC8as_T4_v3", 1, "16Gi", "8", "56Gi", "360Gi", 0.752),
+	gpuInstanceType("Standard_NC16as_T4_v3", 1, "16Gi", "16", "110Gi", "360Gi", 1.204),
+	gpuInstanceType("Standard_NC64as_T4_v3", 4, "16Gi", "64", "440Gi", "2880Gi", 4.352),
+	gpuInstanceType("Standard_NC24ads_A100_v4", 1, "80Gi", "24", "220Gi", "958Gi", 3.673),
+	gpuInstanceType("Standard_NC48ads_A100_v4", 2, "80Gi", "48", "440Gi", "1916Gi", 7.346),
+	gpuInstanceType("Standard_NC96ads_A100_v4", 4, "80Gi", "96", "880Gi", "3832Gi", 14.692),
+	gpuInstanceType("Standard_ND96asr_v4", 8, "40Gi", "96", "900Gi", "6000Gi", 27.197),
+	gpuInstanceType("Standard_ND96amsr_A100_v4", 8, "80Gi", "96", "1900Gi", "6400Gi", 32.77),
+}
+
+// Catalog is the set of instance types workspaces can run on.
+type Catalog struct {
+	instanceTypes map[string]InstanceType
+}
+
+// NewCatalog returns a catalog of the given instance types.
+func NewCatalog(instanceTypes []InstanceType) *Catalog {
+	c := &Catalog{instanceTypes: map[string]InstanceType{}}
+	for _, t := range instanceTypes {
+		c.instanceTypes[t.Name] = t
+	}
+	return c
+}
+
+// BuiltinCatalog returns the catalog of the built-in instance types.
+func BuiltinCatalog() *Catalog {
+	return NewCatalog(builtinInstanceTypes)
+}
+
+// Get returns the instance type with the given name, and false if it is not in the catalog.
+func (c *Catalog) Get(name string) (InstanceType, bool) {
+	t, found := c.instanceTypes[name]
+	return t, found
+}
+
+// Select returns the cheapest instance type that fits the requirements.
+func (c *Catalog) Select(req Requirements) (InstanceType, error) {
+	var candidates []InstanceType
+	for _, t := range c.instanceTypes {
+		if t.Fits(req) == nil {
+			candidates = append(candidates, t)
+		}
+	}
+	if len(candidates) == 0 {
+		return InstanceType{}, fmt.Errorf("no instance type in the catalog has %d GPUs with at least %s of memory",
+			req.GPUCount, req.MinGPUMemory.String())
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].PricePerHour != candidates[j].PricePerHour {
+			return candidates[i].PricePerHour < candidates[j].PricePerHour
+		}
+		return candidates[i].Name < candidates[j].Name
+	})
+	return candidates[0], nil
+}
+
+// CatalogSource loads the catalog: the built-in instance types overridden and extended by the ones in a ConfigMap.
+type CatalogSource struct {
+	// Reader reads the ConfigMap. It should not be a cached client, which would watch all ConfigMaps of the cluster.
+	Reader client.Reader
+	// ConfigMap is the key of the ConfigMap. An empty name means only the built-in instance types are used.
+	ConfigMap client.ObjectKey
+}
+
+// Load returns the current catalog. A missing ConfigMap is not an error, the built-in catalog is returned.
+func (s *CatalogSource) Load(ctx context.Context) (*Catalog, error) {
+	if s == nil || s.Reader == nil || s.ConfigMap.Name == "" {
+		return BuiltinCatalog(), nil
+	}
+
+	cm := &corev1.ConfigMap{}
+	if err := s.Reader.Get(ctx, s.ConfigMap, cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			klog.InfoS("instance type catalog ConfigMap not found, using the built-in catalog", "configMap", s.ConfigMap)
+			return BuiltinCatalog(), nil
+		}
+		return nil, err
+	}
+
+	var overrides []InstanceType
+	if err := yaml.Unmarshal([]byte(cm.Data[CatalogConfigMapKey]), &overrides); err != nil {
+		return nil, fmt.Errorf("invalid instance type catalog in ConfigMap %s: %w", s.ConfigMap, err)
+	}
+	return NewCatalog(append(append([]InstanceType{}, builtinInstanceTypes...), overrides...)), nil
+}
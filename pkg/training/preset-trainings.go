@@ -0,0 +1,169 @@
+package training
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/inference"
+	"github.com/kdm/pkg/k8sresources"
+	"github.com/samber/lo"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// LabelTrainingJob is the label the headless service of the training job selects the training pods by. The
+	// training pods also have the workspace name and namespace labels, but not the inference label the inference
+	// service selects, so that they are never selected by it.
+	LabelTrainingJob = kdmv1alpha1.KDMPrefix + "training-job"
+
+	DataVolumeMountPath   = "/mnt/data"
+	OutputVolumeMountPath = "/mnt/output"
+	RendezvousPort        = int32(29500)
+
+	// CustomVolumeMountDir is the directory the custom volumes of the preset are mounted in, each one at its name.
+	CustomVolumeMountDir = "/mnt/volumes"
+)
+
+// JobName returns the name of the training job and its headless service.
+func JobName(workspaceObj *kdmv1alpha1.Workspace) string {
+	return workspaceObj.Name + "-training"
+}
+
+// CreatePresetTraining creates an indexed job that fine-tunes the preset model with torchrun, running one pod on
+// each node in Status.WorkerNodes, and the headless service used for the torchrun rendezvous.
+func CreatePresetTraining(ctx context.Context, workspaceObj *kdmv1alpha1.Workspace, preset inference.Preset,
+	kubeClient client.Client) error {
+	klog.InfoS("CreatePresetTraining", "workspace", klog.KObj(workspaceObj), "preset", preset.Name())
+
+	trainingParam, err := inference.GetTrainingParameters(preset)
+	if err != nil {
+		return err
+	}
+	nodeNames := workspaceObj.Status.WorkerNodes
+	if len(nodeNames) == 0 {
+		return fmt.Errorf("workspace %s has no worker nodes to run the training job", workspaceObj.Name)
+	}
+
+	jobName := JobName(workspaceObj)
+	selector := map[string]string{
+		LabelTrainingJob: jobName,
+	}
+	labels := lo.Assign(selector, map[string]string{
+		kdmv1alpha1.LabelWorkspaceName:      workspaceObj.Name,
+		kdmv1alpha1.LabelWorkspaceNamespace: workspaceObj.Namespace,
+	})
+
+	serviceObj := k8sresources.GenerateHeadlessServiceManifest(ctx, workspaceObj, jobName, selector, RendezvousPort)
+	if err := k8sresources.CreateService(ctx, serviceObj, kubeClient); err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	volumes, volumeMounts := buildVolumes(workspaceObj)
+	gpuQuantity := resource.MustParse(strconv.Itoa(trainingParam.GPUCountPerNode))
+	container := corev1.Container{
+		Name:    jobName,
+		Image:   trainingParam.Image,
+		Command: buildCommand(trainingParam, workspaceObj, jobName, len(nodeNames)),
+		Resources: corev1.ResourceRequirements{
+			Limits: corev1.ResourceList{
+				corev1.ResourceName(k8sresources.CapacityNvidiaGPU): gpuQuantity,
+			},
+			Requests: corev1.ResourceList{
+				corev1.ResourceName(k8sresources.CapacityNvidiaGPU): gpuQuantity,
+			},
+		},
+		Ports: []corev1.ContainerPort{{
+			ContainerPort: RendezvousPort,
+		}},
+		VolumeMounts: volumeMounts,
+	}
+
+	jobObj := k8sresources.GenerateIndexedJobManifest(ctx, workspaceObj, jobName, jobName, nodeNames, labels,
+		container, inference.GPUTolerations(), volumes)
+	return k8sresources.CreateJob(ctx, jobObj, kubeClient)
+}
+
+// GetTrainingStatus reports the progress of the training job as a condition status, reason and message.
+func GetTrainingStatus(jobObj *batchv1.Job) (metav1.ConditionStatus, string, string) {
+	for _, condition := range jobObj.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return metav1.ConditionTrue, "trainingSucceeded", "training job has completed, the checkpoint is written to the output volume"
+		case batchv1.JobFailed:
+			return metav1.ConditionFalse, "trainingFailed", fmt.Sprintf("training job has failed: %s", condition.Message)
+		}
+	}
+	return metav1.ConditionUnknown, "trainingRunning", fmt.Sprintf("training job is running: %d active, %d of %d pods succeeded",
+		jobObj.Status.Active, jobObj.Status.Succeeded, lo.FromPtr(jobObj.Spec.Completions))
+}
+
+func buildVolumes(workspaceObj *kdmv1alpha1.Workspace) ([]corev1.Volume, []corev1.VolumeMount) {
+	volumes := []corev1.Volume{inference.SharedMemoryVolume()}
+	volumeMounts := []corev1.VolumeMount{{
+		Name:      inference.SharedMemoryVolumeName,
+		MountPath: "/dev/shm",
+	}}
+
+	if dataVolume := workspaceObj.Training.DataVolume; dataVolume != nil {
+		volumes = append(volumes, *dataVolume)
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      dataVolume.Name,
+			MountPath: DataVolumeMountPath,
+			ReadOnly:  true,
+		})
+	}
+	if outputVolume := workspaceObj.Training.OutputVolume; outputVolume != nil {
+		volumes = append(volumes, *outputVolume)
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      outputVolume.Name,
+			MountPath: OutputVolumeMountPath,
+		})
+	}
+	for _, volume := range workspaceObj.Training.Preset.Volume {
+		if volume.Name == inference.SharedMemoryVolumeName {
+			continue
+		}
+		volumes = append(volumes, volume)
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      volume.Name,
+			MountPath: path.Join(CustomVolumeMountDir, volume.Name),
+		})
+	}
+	return volumes, volumeMounts
+}
+
+// buildCommand assembles the torchrun command. The rendezvous arguments point every node at the pod with index 0.
+func buildCommand(trainingParam *inference.PresetTrainingParam, workspaceObj *kdmv1alpha1.Workspace, jobName string, nodeCount int) []string {
+	torchRunParams := lo.Assign(trainingParam.TorchRunParams, map[string]string{
+		"nnodes":         strconv.Itoa(nodeCount),
+		"nproc_per_node": strconv.Itoa(trainingParam.GPUCountPerNode),
+		"node_rank":      "${JOB_COMPLETION_INDEX}",
+		"master_addr":    fmt.Sprintf("%s-0.%s", jobName, jobName),
+		"master_port":    strconv.Itoa(int(RendezvousPort)),
+	})
+	trainingParams := lo.Assign(trainingParam.TrainingParams, workspaceObj.Training.TrainingParams)
+
+	commandParts := []string{trainingParam.BaseCommand}
+	commandParts = append(commandParts, inference.BuildParams(torchRunParams)...)
+	commandParts = append(commandParts, trainingParam.TrainingFile)
+	commandParts = append(commandParts, inference.BuildParams(trainingParams)...)
+
+	return []string{
+		"/bin/sh",
+		"-c",
+		strings.Join(commandParts, " "),
+	}
+}
@@ -1,6 +1,45 @@
 package utils
 
+import (
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/samber/lo"
+)
+
 const (
 	// WorkspaceFinalizer is used to make sure that workspace controller handles garbage collection.
 	WorkspaceFinalizer = "kdm.io/workspace-finalizer"
 )
+
+// GetNodeCount returns the number of GPU nodes of the workspace. Spec.Replicas, set through the scale subresource,
+// takes precedence over Resource.Count.
+func GetNodeCount(workspaceObj *kdmv1alpha1.Workspace) int {
+	if workspaceObj.Spec.Replicas != nil {
+		return int(*workspaceObj.Spec.Replicas)
+	}
+	return lo.FromPtr(workspaceObj.Resource.Count)
+}
+
+// GetInstanceTypes returns the instance types accepted for the nodes of the workspace, the preferred one first.
+// Without Resource.InstanceType, the instance type selected by the controller in Status.SelectedInstanceType is
+// preferred. The fallback instance types follow.
+func GetInstanceTypes(workspaceObj *kdmv1alpha1.Workspace) []string {
+	preferred := workspaceObj.Resource.InstanceType
+	if preferred == "" {
+		preferred = workspaceObj.Status.SelectedInstanceType
+	}
+	instanceTypes := append([]string{preferred}, workspaceObj.Resource.FallbackInstanceTypes...)
+	return lo.Uniq(lo.Compact(instanceTypes))
+}
+
+// GetInstanceType returns the instance type new machines of the workspace are provisioned with. It is selected by the
+// controller in Status.InstanceType among the accepted instance types, the preferred one is used until then.
+func GetInstanceType(workspaceObj *kdmv1alpha1.Workspace) string {
+	instanceTypes := GetInstanceTypes(workspaceObj)
+	if lo.Contains(instanceTypes, workspaceObj.Status.InstanceType) {
+		return workspaceObj.Status.InstanceType
+	}
+	if len(instanceTypes) == 0 {
+		return ""
+	}
+	return instanceTypes[0]
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+)
+
+func newWorkspace(instanceType string, fallbackInstanceTypes []string, selectedInstanceType, currentInstanceType string) *kdmv1alpha1.Workspace {
+	return &kdmv1alpha1.Workspace{
+		Resource: kdmv1alpha1.ResourceSpec{
+			InstanceType:          instanceType,
+			FallbackInstanceTypes: fallbackInstanceTypes,
+		},
+		Status: kdmv1alpha1.WorkspaceStatus{
+			SelectedInstanceType: selectedInstanceType,
+			InstanceType:         currentInstanceType,
+		},
+	}
+}
+
+func TestGetInstanceTypes(t *testing.T) {
+	testCases := []struct {
+		name      string
+		workspace *kdmv1alpha1.Workspace
+		want      []string
+	}{
+		{name: "no instance type", workspace: newWorkspace("", nil, "", ""), want: []string{}},
+		{name: "instance type", workspace: newWorkspace("A", nil, "", ""), want: []string{"A"}},
+		{name: "instance type and fallbacks", workspace: newWorkspace("A", []string{"B", "C"}, "", ""),
+			want: []string{"A", "B", "C"}},
+		{name: "selected instance type", workspace: newWorkspace("", nil, "A", ""), want: []string{"A"}},
+		{name: "selected instance type and fallbacks", workspace: newWorkspace("", []string{"B"}, "A", ""),
+			want: []string{"A", "B"}},
+		{name: "current fallback instance type does not replace the selected one",
+			workspace: newWorkspace("", []string{"B"}, "A", "B"), want: []string{"A", "B"}},
+		{name: "instance type takes precedence over the selected one",
+			workspace: newWorkspace("A", []string{"B"}, "C", ""), want: []string{"A", "B"}},
+		{name: "fallbacks without instance type", workspace: newWorkspace("", []string{"B", "C"}, "", ""),
+			want: []string{"B", "C"}},
+		{name: "duplicates are removed", workspace: newWorkspace("", []string{"A", "B"}, "A", ""),
+			want: []string{"A", "B"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := GetInstanceTypes(tc.workspace); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("GetInstanceTypes() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGetInstanceType(t *testing.T) {
+	testCases := []struct {
+		name      string
+		workspace *kdmv1alpha1.Workspace
+		want      string
+	}{
+		{name: "no instance type", workspace: newWorkspace("", nil, "", ""), want: ""},
+		{name: "instance type before selection", workspace: newWorkspace("A", []string{"B"}, "", ""), want: "A"},
+		{name: "selected instance type before selection", workspace: newWorkspace("", []string{"B"}, "A", ""), want: "A"},
+		{name: "current fallback instance type", workspace: newWorkspace("", []string{"B"}, "A", "B"), want: "B"},
+		{name: "current instance type no longer accepted", workspace: newWorkspace("A", []string{"B"}, "", "C"), want: "A"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := GetInstanceType(tc.workspace); got != tc.want {
+				t.Errorf("GetInstanceType() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
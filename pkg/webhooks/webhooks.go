@@ -0,0 +1,27 @@
+package webhooks
+
+import (
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/sku"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// SetupWorkspaceWebhooksWithManager registers the workspace defaulting and validating webhooks with the manager.
+// The instance types of the workspaces are validated against the catalog.
+// The replicas set through the scale subresource are validated by the same validator.
+func SetupWorkspaceWebhooksWithManager(mgr ctrl.Manager, instanceTypes *sku.CatalogSource) error {
+	validator := &WorkspaceValidator{Client: mgr.GetClient(), InstanceTypes: instanceTypes}
+	if err := ctrl.NewWebhookManagedBy(mgr).
+		For(&kdmv1alpha1.Workspace{}).
+		WithDefaulter(&WorkspaceDefaulter{Client: mgr.GetClient()}).
+		WithValidator(validator).
+		Complete(); err != nil {
+		return err
+	}
+	mgr.GetWebhookServer().Register("/validate-kdm-io-v1alpha1-workspace-scale", &webhook.Admission{
+		Handler: &WorkspaceScaleValidator{Client: mgr.GetClient(), Decoder: admission.NewDecoder(mgr.GetScheme()), Validator: validator},
+	})
+	return nil
+}
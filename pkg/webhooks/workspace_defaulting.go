@@ -0,0 +1,91 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/inference"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+//+kubebuilder:webhook:path=/mutate-kdm-io-v1alpha1-workspace,mutating=true,failurePolicy=fail,sideEffects=None,groups=kdm.io,resources=workspaces,verbs=create;update,versions=v1alpha1,name=mworkspace.kdm.io,admissionReviewVersions=v1
+
+// WorkspaceDefaulter fills in the fields of a workspace that the controller would otherwise assume.
+// The instance type of preset workspaces without one is selected by the controller, in the status.
+type WorkspaceDefaulter struct {
+	Client client.Client
+}
+
+var _ webhook.CustomDefaulter = &WorkspaceDefaulter{}
+
+// Default implements webhook.CustomDefaulter.
+func (d *WorkspaceDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	wObj, ok := obj.(*kdmv1alpha1.Workspace)
+	if !ok {
+		return fmt.Errorf("expected a Workspace but got a %T", obj)
+	}
+	klog.InfoS("Default", "workspace", klog.KObj(wObj))
+	if !wObj.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	d.defaultLabelSelector(wObj)
+	d.defaultAnnotations(wObj)
+
+	if wObj.Inference.Preset.Name != "" && wObj.Inference.Template == nil {
+		preset, err := inference.ResolvePreset(ctx, wObj.Inference.Preset.Name, d.Client)
+		if err != nil {
+			// Unknown presets are rejected by the validating webhook.
+			klog.InfoS("skipping preset defaults", "workspace", klog.KObj(wObj), "preset", wObj.Inference.Preset.Name, "err", err)
+			return nil
+		}
+		d.defaultPreset(wObj, preset.GetInferenceParameters())
+	}
+	return nil
+}
+
+// defaultLabelSelector selects the workspace nodes by the workspace name label when no label selector is given.
+// Workspaces created with generateName have no name yet, they are rejected by the validation without a selector.
+func (d *WorkspaceDefaulter) defaultLabelSelector(wObj *kdmv1alpha1.Workspace) {
+	if wObj.Name == "" {
+		return
+	}
+	if wObj.Resource.LabelSelector == nil {
+		wObj.Resource.LabelSelector = &metav1.LabelSelector{}
+	}
+	if len(wObj.Resource.LabelSelector.MatchLabels) == 0 && len(wObj.Resource.LabelSelector.MatchExpressions) == 0 {
+		wObj.Resource.LabelSelector.MatchLabels = map[string]string{
+			kdmv1alpha1.LabelWorkspaceName: wObj.Name,
+		}
+	}
+}
+
+// defaultAnnotations sets the service type annotation to ClusterIP when it is missing.
+func (d *WorkspaceDefaulter) defaultAnnotations(wObj *kdmv1alpha1.Workspace) {
+	if _, found := wObj.GetAnnotations()[kdmv1alpha1.AnnotationServiceType]; found {
+		return
+	}
+	wObj.SetAnnotations(lo.Assign(wObj.GetAnnotations(), map[string]string{
+		kdmv1alpha1.AnnotationServiceType: kdmv1alpha1.ServiceTypeClusterIP,
+	}))
+}
+
+// defaultPreset adds the shared memory volume of the preset.
+func (d *WorkspaceDefaulter) defaultPreset(wObj *kdmv1alpha1.Workspace, inferenceParam *inference.PresetInferenceParam) {
+	if !inferenceParam.SharedMemory {
+		return
+	}
+	_, found := lo.Find(wObj.Inference.Preset.Volume, func(v corev1.Volume) bool {
+		return v.Name == inference.SharedMemoryVolumeName
+	})
+	if !found {
+		wObj.Inference.Preset.Volume = append([]corev1.Volume{inference.SharedMemoryVolume()}, wObj.Inference.Preset.Volume...)
+	}
+}
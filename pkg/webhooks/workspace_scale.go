@@ -0,0 +1,45 @@
+package webhooks
+
+import (
+	"context"
+	"net/http"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+//+kubebuilder:webhook:path=/validate-kdm-io-v1alpha1-workspace-scale,mutating=false,failurePolicy=fail,sideEffects=None,groups=kdm.io,resources=workspaces/scale,verbs=update,versions=v1alpha1,name=vworkspacescale.kdm.io,admissionReviewVersions=v1
+
+// WorkspaceScaleValidator validates the replicas set through the scale subresource, e.g., by kubectl scale or a
+// HorizontalPodAutoscaler, which the workspace webhooks are not called for. The scaled workspace is validated like
+// any other workspace update.
+type WorkspaceScaleValidator struct {
+	Client    client.Client
+	Decoder   *admission.Decoder
+	Validator *WorkspaceValidator
+}
+
+var _ admission.Handler = &WorkspaceScaleValidator{}
+
+// Handle implements admission.Handler.
+func (v *WorkspaceScaleValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	scale := &autoscalingv1.Scale{}
+	if err := v.Decoder.Decode(req, scale); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	oldWObj := &kdmv1alpha1.Workspace{}
+	if err := v.Client.Get(ctx, client.ObjectKey{Namespace: req.Namespace, Name: req.Name}, oldWObj); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	klog.InfoS("ValidateScale", "workspace", klog.KObj(oldWObj), "replicas", scale.Spec.Replicas)
+
+	wObj := oldWObj.DeepCopy()
+	wObj.Spec.Replicas = &scale.Spec.Replicas
+	if _, err := v.Validator.ValidateUpdate(ctx, oldWObj, wObj); err != nil {
+		return admission.Denied(err.Error())
+	}
+	return admission.Allowed("")
+}
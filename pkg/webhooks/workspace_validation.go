@@ -0,0 +1,359 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	kdmv1alpha1 "github.com/kdm/api/v1alpha1"
+	"github.com/kdm/pkg/inference"
+	"github.com/kdm/pkg/k8sresources"
+	"github.com/kdm/pkg/quota"
+	"github.com/kdm/pkg/schedule"
+	"github.com/kdm/pkg/sku"
+	"github.com/kdm/pkg/utils"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+//+kubebuilder:webhook:path=/validate-kdm-io-v1alpha1-workspace,mutating=false,failurePolicy=fail,sideEffects=None,groups=kdm.io,resources=workspaces,verbs=create;update,versions=v1alpha1,name=vworkspace.kdm.io,admissionReviewVersions=v1
+
+// WorkspaceValidator rejects workspaces that the controller cannot deploy.
+type WorkspaceValidator struct {
+	Client client.Client
+	// InstanceTypes is the catalog the instance types of the workspaces are validated against.
+	InstanceTypes *sku.CatalogSource
+}
+
+var _ webhook.CustomValidator = &WorkspaceValidator{}
+
+// ValidateCreate implements webhook.CustomValidator.
+func (v *WorkspaceValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	wObj, ok := obj.(*kdmv1alpha1.Workspace)
+	if !ok {
+		return nil, fmt.Errorf("expected a Workspace but got a %T", obj)
+	}
+	klog.InfoS("ValidateCreate", "workspace", klog.KObj(wObj))
+	if err := v.validateWorkspace(ctx, wObj); err != nil {
+		return nil, err
+	}
+	return nil, v.validateQuota(ctx, nil, wObj)
+}
+
+// ValidateUpdate implements webhook.CustomValidator.
+func (v *WorkspaceValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	wObj, ok := newObj.(*kdmv1alpha1.Workspace)
+	if !ok {
+		return nil, fmt.Errorf("expected a Workspace but got a %T", newObj)
+	}
+	klog.InfoS("ValidateUpdate", "workspace", klog.KObj(wObj))
+	// Deleting workspaces only get their finalizers removed, they must not be blocked by validation.
+	if !wObj.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+	oldWObj, _ := oldObj.(*kdmv1alpha1.Workspace)
+	if oldWObj != nil && !reflect.DeepEqual(oldWObj.Resource.LabelSelector, wObj.Resource.LabelSelector) {
+		// The label selector is the selector of the inference deployment, which is immutable.
+		return nil, field.Forbidden(field.NewPath("resource", "labelSelector"), "field is immutable")
+	}
+	if err := v.validateWorkspace(ctx, wObj); err != nil {
+		return nil, err
+	}
+	return nil, v.validateQuota(ctx, oldWObj, wObj)
+}
+
+// ValidateDelete implements webhook.CustomValidator.
+func (v *WorkspaceValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *WorkspaceValidator) validateWorkspace(ctx context.Context, wObj *kdmv1alpha1.Workspace) error {
+	errs := v.validateResource(wObj)
+	if wObj.Training.Preset.Name == "" || wObj.Inference.Preset.Name != "" || wObj.Inference.Template != nil {
+		errs = append(errs, v.validateInference(ctx, wObj)...)
+	}
+	errs = append(errs, v.validateTraining(ctx, wObj)...)
+	errs = append(errs, v.validatePriority(ctx, wObj)...)
+	errs = append(errs, v.validateSchedule(wObj)...)
+	errs = append(errs, v.validateLifetime(wObj)...)
+	errs = append(errs, v.validateScaleToZero(wObj)...)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs.ToAggregate()
+}
+
+// validateQuota rejects workspaces that do not fit in the GPU quotas of their namespace. Updates are only checked
+// when they request more GPUs or nodes or change the instance types, so that a lowered quota does not block the
+// updates of the existing workspaces.
+func (v *WorkspaceValidator) validateQuota(ctx context.Context, oldWObj, wObj *kdmv1alpha1.Workspace) error {
+	catalog, err := v.InstanceTypes.Load(ctx)
+	if err != nil {
+		klog.ErrorS(err, "skipping GPU quota validation, failed to load the instance type catalog", "workspace", klog.KObj(wObj))
+		return nil
+	}
+	wObj = v.withSelectedInstanceType(ctx, wObj, catalog)
+	if oldWObj != nil {
+		oldUsage, usage := quota.WorkspaceUsage(oldWObj, catalog), quota.WorkspaceUsage(wObj, catalog)
+		if usage.GPUs <= oldUsage.GPUs && usage.Nodes <= oldUsage.Nodes &&
+			reflect.DeepEqual(utils.GetInstanceTypes(oldWObj), utils.GetInstanceTypes(wObj)) {
+			return nil
+		}
+	}
+
+	if err := quota.Check(ctx, v.Client, catalog, wObj); err != nil {
+		if quota.IsQuotaExceeded(err) {
+			return field.Forbidden(field.NewPath("resource"), err.Error())
+		}
+		// The controller checks the quotas again before creating machines.
+		klog.ErrorS(err, "skipping GPU quota validation", "workspace", klog.KObj(wObj))
+	}
+	return nil
+}
+
+// withSelectedInstanceType returns the workspace with the instance type the controller selects for it when it has
+// none yet, so that new preset workspaces without an instance type use the quotas they will use once reconciled.
+func (v *WorkspaceValidator) withSelectedInstanceType(ctx context.Context, wObj *kdmv1alpha1.Workspace, catalog *sku.Catalog) *kdmv1alpha1.Workspace {
+	if len(utils.GetInstanceTypes(wObj)) != 0 || (wObj.Inference.Preset.Name == "" && wObj.Training.Preset.Name == "") {
+		return wObj
+	}
+	instanceType, err := inference.SelectInstanceType(ctx, wObj, catalog, v.Client)
+	if err != nil {
+		klog.InfoS("failed to select the instance type of the workspace for the GPU quotas", "workspace", klog.KObj(wObj), "err", err)
+		return wObj
+	}
+	wObj = wObj.DeepCopy()
+	wObj.Status.SelectedInstanceType = instanceType
+	return wObj
+}
+
+func (v *WorkspaceValidator) validateResource(wObj *kdmv1alpha1.Workspace) field.ErrorList {
+	resourcePath := field.NewPath("resource")
+	var errs field.ErrorList
+
+	if wObj.Resource.LabelSelector == nil && wObj.Name == "" {
+		errs = append(errs, field.Required(resourcePath.Child("labelSelector"), "required for workspaces created with generateName"))
+	} else if wObj.Resource.LabelSelector == nil {
+		errs = append(errs, field.Required(resourcePath.Child("labelSelector"), ""))
+	} else if len(wObj.Resource.LabelSelector.MatchLabels) == 0 {
+		errs = append(errs, field.Required(resourcePath.Child("labelSelector", "matchLabels"), "at least one label is required"))
+	}
+	if wObj.Resource.Count != nil && *wObj.Resource.Count < 1 {
+		errs = append(errs, field.Invalid(resourcePath.Child("count"), *wObj.Resource.Count, "must be at least 1"))
+	}
+	for i, instanceType := range wObj.Resource.FallbackInstanceTypes {
+		path := resourcePath.Child("fallbackInstanceTypes").Index(i)
+		if instanceType == "" {
+			errs = append(errs, field.Required(path, ""))
+		} else if instanceType == wObj.Resource.InstanceType || lo.Contains(wObj.Resource.FallbackInstanceTypes[:i], instanceType) {
+			errs = append(errs, field.Duplicate(path, instanceType))
+		}
+	}
+	return errs
+}
+
+// validatePriority checks that the PriorityClass of the workspace exists.
+func (v *WorkspaceValidator) validatePriority(ctx context.Context, wObj *kdmv1alpha1.Workspace) field.ErrorList {
+	if wObj.Resource.PriorityClassName == "" {
+		return nil
+	}
+	path := field.NewPath("resource", "priorityClassName")
+	_, err := k8sresources.GetPriorityClass(ctx, wObj.Resource.PriorityClassName, v.Client)
+	if apierrors.IsNotFound(err) {
+		return field.ErrorList{field.NotFound(path, wObj.Resource.PriorityClassName)}
+	}
+	if err != nil {
+		// The controller resolves the priority again.
+		klog.ErrorS(err, "skipping priority class validation", "workspace", klog.KObj(wObj))
+		return nil
+	}
+	return nil
+}
+
+// validateSchedule checks the cron expressions and the time zone of the schedule of the workspace.
+func (v *WorkspaceValidator) validateSchedule(wObj *kdmv1alpha1.Workspace) field.ErrorList {
+	spec := wObj.Spec.Schedule
+	if spec == nil {
+		return nil
+	}
+	schedulePath := field.NewPath("spec", "schedule")
+	var errs field.ErrorList
+	if _, err := time.LoadLocation(spec.TimeZone); err != nil {
+		errs = append(errs, field.Invalid(schedulePath.Child("timeZone"), spec.TimeZone, err.Error()))
+	}
+	if _, err := schedule.ParseCron(spec.Start, time.UTC); err != nil {
+		errs = append(errs, field.Invalid(schedulePath.Child("start"), spec.Start, err.Error()))
+	}
+	if _, err := schedule.ParseCron(spec.Stop, time.UTC); err != nil {
+		errs = append(errs, field.Invalid(schedulePath.Child("stop"), spec.Stop, err.Error()))
+	}
+	return errs
+}
+
+// validateLifetime checks the idle timeout of the workspace and where its request counter is scraped from.
+func (v *WorkspaceValidator) validateLifetime(wObj *kdmv1alpha1.Workspace) field.ErrorList {
+	specPath := field.NewPath("spec")
+	var errs field.ErrorList
+	if wObj.Spec.IdleTimeout != nil && wObj.Spec.IdleTimeout.Duration <= 0 {
+		errs = append(errs, field.Invalid(specPath.Child("idleTimeout"), wObj.Spec.IdleTimeout.Duration.String(), "must be positive"))
+	}
+	if metrics := wObj.Spec.RequestMetrics; metrics != nil && metrics.Service != "" {
+		for _, msg := range validation.IsDNS1035Label(metrics.Service) {
+			errs = append(errs, field.Invalid(specPath.Child("requestMetrics", "service"), metrics.Service, msg))
+		}
+	}
+	return errs
+}
+
+// validateScaleToZero checks that a workspace with scaleToZero serves requests.
+func (v *WorkspaceValidator) validateScaleToZero(wObj *kdmv1alpha1.Workspace) field.ErrorList {
+	spec := wObj.Spec.ScaleToZero
+	if spec == nil {
+		return nil
+	}
+	scaleToZeroPath := field.NewPath("spec", "scaleToZero")
+	if wObj.Inference.Preset.Name == "" && wObj.Inference.Template == nil {
+		return field.ErrorList{field.Forbidden(scaleToZeroPath, "only workspaces running an inference can scale to zero")}
+	}
+	if spec.ActivationTimeout != nil && spec.ActivationTimeout.Duration <= 0 {
+		return field.ErrorList{field.Invalid(scaleToZeroPath.Child("activationTimeout"), spec.ActivationTimeout.Duration.String(), "must be positive")}
+	}
+	return nil
+}
+
+func (v *WorkspaceValidator) validateInference(ctx context.Context, wObj *kdmv1alpha1.Workspace) field.ErrorList {
+	inferencePath := field.NewPath("inference")
+	presetSet := wObj.Inference.Preset.Name != ""
+	templateSet := wObj.Inference.Template != nil
+
+	if presetSet && templateSet {
+		return field.ErrorList{field.Forbidden(inferencePath, "preset and template cannot be set at the same time")}
+	}
+	if !presetSet && !templateSet {
+		return field.ErrorList{field.Required(inferencePath, "either preset or template must be set")}
+	}
+	if templateSet {
+		if len(wObj.Inference.Template.Spec.Containers) == 0 {
+			return field.ErrorList{field.Required(inferencePath.Child("template", "spec", "containers"), "")}
+		}
+		return nil
+	}
+
+	presetPath := inferencePath.Child("preset", "name")
+	preset, err := inference.ResolvePreset(ctx, wObj.Inference.Preset.Name, v.Client)
+	if err != nil {
+		return field.ErrorList{field.Invalid(presetPath, wObj.Inference.Preset.Name, err.Error())}
+	}
+
+	inferenceParam := preset.GetInferenceParameters()
+	if inferenceParam.DistributedInference && wObj.Spec.Replicas != nil {
+		return field.ErrorList{field.Forbidden(field.NewPath("spec", "replicas"),
+			fmt.Sprintf("preset %s is sharded across the workspace nodes and cannot be scaled by replicas, use resource.count", preset.Name()))}
+	}
+	nodeCount := lo.FromPtr(wObj.Resource.Count)
+	if inferenceParam.DistributedInference && nodeCount > 1 && inferenceParam.GPUCountPerReplica%nodeCount != 0 {
+		return field.ErrorList{field.Invalid(field.NewPath("resource", "count"), nodeCount,
+			fmt.Sprintf("preset %s requires %d GPUs which cannot be split evenly across the nodes", preset.Name(), inferenceParam.GPUCountPerReplica))}
+	}
+
+	req, err := inference.InferenceRequirements(inferenceParam, nodeCount)
+	if err != nil {
+		return field.ErrorList{field.Invalid(presetPath, wObj.Inference.Preset.Name, err.Error())}
+	}
+	return v.validateInstanceType(ctx, wObj, preset, req)
+}
+
+// validateInstanceType checks that the instance type and the fallback instance types of the workspace meet the per
+// node requirements of the preset. Empty instance types are selected by the controller and instance types missing
+// from the catalog are not checked.
+func (v *WorkspaceValidator) validateInstanceType(ctx context.Context, wObj *kdmv1alpha1.Workspace, preset inference.Preset, req sku.Requirements) field.ErrorList {
+	if wObj.Resource.InstanceType == "" && len(wObj.Resource.FallbackInstanceTypes) == 0 {
+		return nil
+	}
+	catalog, err := v.InstanceTypes.Load(ctx)
+	if err != nil {
+		klog.ErrorS(err, "skipping instance type validation, failed to load the instance type catalog", "workspace", klog.KObj(wObj))
+		return nil
+	}
+
+	resourcePath := field.NewPath("resource")
+	var errs field.ErrorList
+	checkInstanceType := func(path *field.Path, name string) {
+		instanceType, found := catalog.Get(name)
+		if !found {
+			klog.InfoS("skipping validation of unknown instance type", "workspace", klog.KObj(wObj), "instanceType", name)
+			return
+		}
+		if err := instanceType.Fits(req); err != nil {
+			errs = append(errs, field.Invalid(path, name, fmt.Sprintf("%v for preset %s", err, preset.Name())))
+		}
+	}
+	if wObj.Resource.InstanceType != "" {
+		checkInstanceType(resourcePath.Child("instanceType"), wObj.Resource.InstanceType)
+	}
+	for i, name := range wObj.Resource.FallbackInstanceTypes {
+		checkInstanceType(resourcePath.Child("fallbackInstanceTypes").Index(i), name)
+	}
+	return errs
+}
+
+func (v *WorkspaceValidator) validateTraining(ctx context.Context, wObj *kdmv1alpha1.Workspace) field.ErrorList {
+	trainingPath := field.NewPath("training")
+	if wObj.Training.Preset.Name == "" {
+		return nil
+	}
+	var errs field.ErrorList
+
+	presetPath := trainingPath.Child("preset", "name")
+	preset, err := inference.ResolvePreset(ctx, wObj.Training.Preset.Name, v.Client)
+	if err != nil {
+		errs = append(errs, field.Invalid(presetPath, wObj.Training.Preset.Name, err.Error()))
+	} else if trainingParam, err := inference.GetTrainingParameters(preset); err != nil {
+		errs = append(errs, field.Invalid(presetPath, wObj.Training.Preset.Name, err.Error()))
+	} else if req, err := inference.TrainingRequirements(preset, trainingParam); err != nil {
+		errs = append(errs, field.Invalid(presetPath, wObj.Training.Preset.Name, err.Error()))
+	} else {
+		errs = append(errs, v.validateInstanceType(ctx, wObj, preset, req)...)
+	}
+
+	if wObj.Training.DataVolume == nil {
+		errs = append(errs, field.Required(trainingPath.Child("dataVolume"), ""))
+	}
+	if wObj.Training.OutputVolume == nil {
+		errs = append(errs, field.Required(trainingPath.Child("outputVolume"), ""))
+	}
+	if wObj.Training.DataVolume != nil && wObj.Training.OutputVolume != nil &&
+		wObj.Training.DataVolume.Name == wObj.Training.OutputVolume.Name {
+		errs = append(errs, field.Duplicate(trainingPath.Child("outputVolume", "name"), wObj.Training.OutputVolume.Name))
+	}
+	// The custom volumes are mounted by their names, next to the shared memory, data and output volumes.
+	volumeNames := []string{inference.SharedMemoryVolumeName}
+	for _, volume := range []*corev1.Volume{wObj.Training.DataVolume, wObj.Training.OutputVolume} {
+		if volume != nil {
+			volumeNames = append(volumeNames, volume.Name)
+		}
+	}
+	for i, volume := range wObj.Training.Preset.Volume {
+		path := trainingPath.Child("preset", "volume").Index(i).Child("name")
+		if volume.Name == inference.SharedMemoryVolumeName {
+			continue
+		}
+		for _, msg := range validation.IsDNS1123Label(volume.Name) {
+			errs = append(errs, field.Invalid(path, volume.Name, msg))
+		}
+		if lo.Contains(volumeNames, volume.Name) {
+			errs = append(errs, field.Duplicate(path, volume.Name))
+		}
+		volumeNames = append(volumeNames, volume.Name)
+	}
+	return errs
+}